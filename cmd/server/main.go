@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"syscall"
 	"time"
+	_ "time/tzdata" // ハウスごとのタイムゾーンをOSのtzdataに依存せず読み込む
 
 	"github.com/joho/godotenv"
 
@@ -55,9 +56,24 @@ func getPort() string {
 	return port
 }
 
-func main() {
-	os.Setenv("TZ", "Asia/Tokyo")
+func getWebhookWorkers() int {
+	n, err := strconv.Atoi(getenv("WEBHOOK_WORKERS", "4"))
+	if err != nil || n < 1 {
+		log.Fatalf("WEBHOOK_WORKERS must be a positive number: %s", os.Getenv("WEBHOOK_WORKERS"))
+	}
+	return n
+}
+
+// getPushFallbackBudget 返信できなかったときにプッシュで送り直す月の上限（0で送り直さない）
+func getPushFallbackBudget() int {
+	n, err := strconv.Atoi(getenv("LINE_PUSH_FALLBACK_BUDGET", "100"))
+	if err != nil || n < 0 {
+		log.Fatalf("LINE_PUSH_FALLBACK_BUDGET must be zero or a positive number: %s", os.Getenv("LINE_PUSH_FALLBACK_BUDGET"))
+	}
+	return n
+}
 
+func main() {
 	dsn := getenv("DATABASE_URL", "")
 	if dsn == "" {
 		log.Fatal("DATABASE_URL is required")
@@ -68,7 +84,14 @@ func main() {
 	rp := repo.New(sqlDB)
 	sv := service.New(rp)
 
-	r := httpapi.Router(sv)
+	// LINE_API_BASE_URL はローカルで偽のLINEサーバーに向けるとき用（空なら本番）
+	lc := httpapi.NewLineClient(os.Getenv("LINE_API_BASE_URL"), os.Getenv("LINE_CHANNEL_ACCESS_TOKEN"), nil)
+
+	// LINE Webhookのイベントは保存してからワーカーで処理する
+	queue := httpapi.NewWebhookQueue(sv, lc, getWebhookWorkers(), getPushFallbackBudget())
+	queue.Start()
+
+	r := httpapi.Router(sv, queue)
 
 	addr := ":" + getPort()
 	srv := &http.Server{
@@ -80,6 +103,13 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	// 週間チャンピオンの記録はLINEに送らないので、トークンがなくても動かす
+	go httpapi.RunHouseJobs(ctx, sv, 5*time.Minute)
+	// 週次まとめ・期限切れ通知のプッシュ（LINEのトークンがない環境では送れないので動かさない）
+	if os.Getenv("LINE_CHANNEL_ACCESS_TOKEN") != "" {
+		go httpapi.RunScheduledJobs(ctx, sv, lc, 5*time.Minute)
+	}
+
 	go func() {
 		log.Printf("listening on %s", addr)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -92,4 +122,8 @@ func main() {
 	shCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	_ = srv.Shutdown(shCtx)
+	// 受け付けを止めてから、処理中のWebhookイベントを終わらせる（未処理の分はDBに残る）
+	if err := queue.Shutdown(shCtx); err != nil {
+		log.Printf("webhook queue shutdown: %v", err)
+	}
 }
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS task_aliases;
+DROP TABLE IF EXISTS tasks;
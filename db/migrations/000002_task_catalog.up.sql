@@ -0,0 +1,63 @@
+-- tasks（ハウスごとの家事タスク辞書）
+CREATE TABLE IF NOT EXISTS tasks(
+  id BIGSERIAL PRIMARY KEY,
+  house_id BIGINT NOT NULL REFERENCES houses(id) ON DELETE CASCADE,
+  task_key TEXT NOT NULL,
+  points NUMERIC(10,1) NOT NULL CHECK (points >= 0),
+  sort_order INT NOT NULL DEFAULT 0,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (house_id, task_key)
+);
+
+-- task_aliases（別名。ハウス内で一意）
+CREATE TABLE IF NOT EXISTS task_aliases(
+  house_id BIGINT NOT NULL REFERENCES houses(id) ON DELETE CASCADE,
+  task_id  BIGINT NOT NULL REFERENCES tasks(id)  ON DELETE CASCADE,
+  alias TEXT NOT NULL,
+  PRIMARY KEY (house_id, alias)
+);
+
+CREATE INDEX IF NOT EXISTS idx_tasks_house_sort ON tasks(house_id, sort_order, id);
+CREATE INDEX IF NOT EXISTS idx_task_aliases_task ON task_aliases(task_id);
+
+-- 既存ハウスへ従来のデフォルト辞書を投入
+WITH defaults(sort_order, task_key, points) AS (
+  VALUES
+    (1,  '皿洗い',           180.0),
+    (2,  'ごはん作り',       300.0),
+    (3,  '洗濯（ドラム式）', 100.0),
+    (4,  'ゴミ出し',         100.0),
+    (5,  '買い出し',         250.0),
+    (6,  '風呂掃除',         150.0),
+    (7,  'トイレ掃除',       400.0),
+    (8,  '床掃除',           200.0),
+    (9,  '洗面台掃除',       200.0),
+    (10, '風呂排水溝',       300.0)
+)
+INSERT INTO tasks(house_id, task_key, points, sort_order)
+SELECT h.id, d.task_key, d.points, d.sort_order
+FROM houses h CROSS JOIN defaults d
+ON CONFLICT (house_id, task_key) DO NOTHING;
+
+WITH defaults(task_key, alias) AS (
+  VALUES
+    ('皿洗い', 'さらあらい'), ('皿洗い', '洗い物'), ('皿洗い', '洗いもの'),
+    ('ごはん作り', 'ごはんづくり'), ('ごはん作り', 'ご飯作り'), ('ごはん作り', 'ご飯づくり'),
+    ('ごはん作り', '料理'), ('ごはん作り', '調理'), ('ごはん作り', '晩ご飯'), ('ごはん作り', '夕飯'),
+    ('洗濯（ドラム式）', '洗濯'), ('洗濯（ドラム式）', 'せんたく'), ('洗濯（ドラム式）', '洗濯物'),
+    ('洗濯（ドラム式）', 'せんたくもの'), ('洗濯（ドラム式）', 'せんたく物'),
+    ('ゴミ出し', 'ごみだし'), ('ゴミ出し', 'ゴミ'), ('ゴミ出し', 'ごみ'),
+    ('買い出し', '買出し'), ('買い出し', '買い物'), ('買い出し', '買いもの'), ('買い出し', 'かいもの'),
+    ('風呂掃除', 'ふろそうじ'), ('風呂掃除', '風呂清掃'), ('風呂掃除', '風呂'), ('風呂掃除', 'ふろ'),
+    ('トイレ掃除', 'トイレそうじ'), ('トイレ掃除', 'トイレ清掃'), ('トイレ掃除', 'トイレ'),
+    ('トイレ掃除', 'といれそうじ'), ('トイレ掃除', 'といれ'),
+    ('床掃除', 'ゆかそうじ'), ('床掃除', '床清掃'), ('床掃除', '床'), ('床掃除', 'ゆか'),
+    ('洗面台掃除', '洗面台清掃'), ('洗面台掃除', 'せんめんだい'), ('洗面台掃除', 'せんめんだいそうじ'),
+    ('洗面台掃除', '洗面台'),
+    ('風呂排水溝', '風呂の排水溝'), ('風呂排水溝', '排水溝風呂')
+)
+INSERT INTO task_aliases(house_id, task_id, alias)
+SELECT t.house_id, t.id, d.alias
+FROM defaults d
+JOIN tasks t ON t.task_key = d.task_key
+ON CONFLICT (house_id, alias) DO NOTHING;
@@ -0,0 +1,2 @@
+-- アーカイブ済みタスクは報告の解決対象から外す（過去のeventsは保持）
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
@@ -0,0 +1,7 @@
+ALTER TABLE tasks
+  DROP CONSTRAINT IF EXISTS tasks_max_units_check,
+  DROP CONSTRAINT IF EXISTS tasks_unit_minutes_check,
+  DROP CONSTRAINT IF EXISTS tasks_scoring_check,
+  DROP COLUMN IF EXISTS max_units,
+  DROP COLUMN IF EXISTS unit_minutes,
+  DROP COLUMN IF EXISTS scoring;
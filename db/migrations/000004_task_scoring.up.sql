@@ -0,0 +1,14 @@
+-- タスクごとの採点ルール
+--   fixed:       1回あたり points（"x2" 等の回数指定は掛け算）
+--   per_minutes: unit_minutes 分ごとに points
+--   per_unit:    1単位ごとに points
+-- max_units: 掛け算する単位数の上限（NULLは無制限）
+ALTER TABLE tasks
+  ADD COLUMN IF NOT EXISTS scoring TEXT NOT NULL DEFAULT 'fixed',
+  ADD COLUMN IF NOT EXISTS unit_minutes INT,
+  ADD COLUMN IF NOT EXISTS max_units INT;
+
+ALTER TABLE tasks
+  ADD CONSTRAINT tasks_scoring_check CHECK (scoring IN ('fixed', 'per_minutes', 'per_unit')),
+  ADD CONSTRAINT tasks_unit_minutes_check CHECK (scoring <> 'per_minutes' OR unit_minutes > 0),
+  ADD CONSTRAINT tasks_max_units_check CHECK (max_units IS NULL OR max_units > 0);
@@ -0,0 +1,3 @@
+ALTER TABLE houses
+  DROP CONSTRAINT IF EXISTS houses_report_reply_check,
+  DROP COLUMN IF EXISTS report_reply;
@@ -0,0 +1,7 @@
+-- ハウスごとの設定
+--   report_reply: 家事報告への返信（always: 常に / corrected: タイプミス補正時のみ / silent: 返信しない）
+ALTER TABLE houses
+  ADD COLUMN IF NOT EXISTS report_reply TEXT NOT NULL DEFAULT 'always';
+
+ALTER TABLE houses
+  ADD CONSTRAINT houses_report_reply_check CHECK (report_reply IN ('always', 'corrected', 'silent'));
@@ -0,0 +1,6 @@
+ALTER TABLE houses
+  DROP CONSTRAINT IF EXISTS houses_backdate_limit_days_check,
+  DROP COLUMN IF EXISTS backdate_limit_days;
+
+DROP INDEX IF EXISTS idx_events_house_performed_cover;
+ALTER TABLE events DROP COLUMN IF EXISTS performed_at;
@@ -0,0 +1,17 @@
+-- 家事を実施した日時（created_at は報告日時）。週次集計は performed_at 基準
+ALTER TABLE events ADD COLUMN IF NOT EXISTS performed_at TIMESTAMPTZ;
+UPDATE events SET performed_at = created_at WHERE performed_at IS NULL;
+ALTER TABLE events
+  ALTER COLUMN performed_at SET DEFAULT now(),
+  ALTER COLUMN performed_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_events_house_performed_cover
+  ON events(house_id, performed_at)
+  INCLUDE (points, user_id);
+
+-- 何日前までさかのぼって報告できるか（0は当日のみ）
+ALTER TABLE houses
+  ADD COLUMN IF NOT EXISTS backdate_limit_days INT NOT NULL DEFAULT 7;
+
+ALTER TABLE houses
+  ADD CONSTRAINT houses_backdate_limit_days_check CHECK (backdate_limit_days BETWEEN 0 AND 365);
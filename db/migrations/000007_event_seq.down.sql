@@ -0,0 +1,4 @@
+ALTER TABLE events
+  DROP CONSTRAINT IF EXISTS events_house_seq_key,
+  DROP COLUMN IF EXISTS seq;
+ALTER TABLE houses DROP COLUMN IF EXISTS event_seq;
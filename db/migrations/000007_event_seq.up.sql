@@ -0,0 +1,16 @@
+-- ハウス内の通し番号（LINEの「@bot 取消 3」などで使う短いID）
+ALTER TABLE houses ADD COLUMN IF NOT EXISTS event_seq BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS seq BIGINT;
+
+UPDATE events e SET seq = s.rn
+FROM (
+  SELECT id, row_number() OVER (PARTITION BY house_id ORDER BY created_at, id) AS rn
+  FROM events
+) s
+WHERE s.id = e.id AND e.seq IS NULL;
+
+UPDATE houses h
+SET event_seq = COALESCE((SELECT MAX(e.seq) FROM events e WHERE e.house_id = h.id), 0);
+
+ALTER TABLE events ALTER COLUMN seq SET NOT NULL;
+ALTER TABLE events ADD CONSTRAINT events_house_seq_key UNIQUE (house_id, seq);
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS event_audit;
+
+-- 論理削除済みの行は元の挙動（物理削除）に合わせて消す
+DELETE FROM events WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS idx_events_house_performed_live;
+ALTER TABLE events
+  DROP COLUMN IF EXISTS deleted_by,
+  DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,25 @@
+-- 取り消しは論理削除（deleted_at が入った行は集計から除外）
+ALTER TABLE events
+  ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS deleted_by TEXT;          -- 取り消した人の ext_user_id（HTTPでは NULL）
+
+CREATE INDEX IF NOT EXISTS idx_events_house_performed_live
+  ON events(house_id, performed_at)
+  INCLUDE (points, user_id)
+  WHERE deleted_at IS NULL;
+
+-- 記録の作成・修正・取り消し・復元の履歴（操作後の内容を残す）
+CREATE TABLE IF NOT EXISTS event_audit(
+  id BIGSERIAL PRIMARY KEY,
+  event_id BIGINT NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+  house_id BIGINT NOT NULL REFERENCES houses(id) ON DELETE CASCADE,
+  action TEXT NOT NULL CHECK (action IN ('create', 'edit', 'cancel', 'restore')),
+  actor TEXT,                                        -- 操作した人の ext_user_id（HTTPでは NULL）
+  source TEXT NOT NULL CHECK (source IN ('line', 'http')),
+  task_key TEXT NOT NULL,
+  task_option TEXT,
+  points NUMERIC(10,1) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_audit_event ON event_audit(event_id, created_at);
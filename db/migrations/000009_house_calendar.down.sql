@@ -0,0 +1,5 @@
+ALTER TABLE houses
+  DROP CONSTRAINT IF EXISTS houses_day_rollover_hour_check,
+  DROP CONSTRAINT IF EXISTS houses_week_start_check,
+  DROP COLUMN IF EXISTS day_rollover_hour,
+  DROP COLUMN IF EXISTS week_start;
@@ -0,0 +1,8 @@
+-- 週の開始曜日（0=日曜 … 6=土曜、既定は月曜）と日付が切り替わる時刻（例: 4なら午前4時まで前日扱い）
+ALTER TABLE houses
+  ADD COLUMN IF NOT EXISTS week_start SMALLINT NOT NULL DEFAULT 1,
+  ADD COLUMN IF NOT EXISTS day_rollover_hour SMALLINT NOT NULL DEFAULT 0;
+
+ALTER TABLE houses
+  ADD CONSTRAINT houses_week_start_check CHECK (week_start BETWEEN 0 AND 6),
+  ADD CONSTRAINT houses_day_rollover_hour_check CHECK (day_rollover_hour BETWEEN 0 AND 11);
@@ -0,0 +1 @@
+ALTER TABLE houses DROP COLUMN IF EXISTS timezone;
@@ -0,0 +1,2 @@
+-- ハウスのタイムゾーン（IANA名）。週・日の区切りと日付の表示に使う
+ALTER TABLE houses ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'Asia/Tokyo';
@@ -0,0 +1,14 @@
+-- 定期ジョブの実行記録（ハウス×期間ごとに1行。複数インスタンスでも1回だけ実行する）
+CREATE TABLE IF NOT EXISTS job_runs(
+  id BIGSERIAL PRIMARY KEY,
+  job TEXT NOT NULL,                                  -- weekly_recap など
+  house_id BIGINT NOT NULL REFERENCES houses(id) ON DELETE CASCADE,
+  period_start TIMESTAMPTZ NOT NULL,                  -- 対象期間の開始時刻
+  status TEXT NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'done', 'skipped', 'failed')),
+  attempts INT NOT NULL DEFAULT 1,
+  retry_key UUID NOT NULL DEFAULT gen_random_uuid(),  -- LINEの X-Line-Retry-Key（再送しても二重に届かない）
+  last_error TEXT,
+  claimed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  finished_at TIMESTAMPTZ,
+  UNIQUE (job, house_id, period_start)
+);
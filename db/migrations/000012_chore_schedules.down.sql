@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_events_house_task_performed_live;
+DROP TABLE IF EXISTS chore_schedules;
@@ -0,0 +1,22 @@
+-- 定期的にやるべき家事。次の期日は保存せず、同じタスクの最後の記録から計算する
+-- （取り消し・さかのぼり報告もそのまま反映される）
+CREATE TABLE IF NOT EXISTS chore_schedules(
+  id BIGSERIAL PRIMARY KEY,
+  house_id BIGINT NOT NULL REFERENCES houses(id) ON DELETE CASCADE,
+  task_id  BIGINT NOT NULL REFERENCES tasks(id)  ON DELETE CASCADE,
+  rule TEXT NOT NULL CHECK (rule IN ('daily', 'every', 'weekly')),
+  interval_days INT NOT NULL DEFAULT 1 CHECK (interval_days BETWEEN 1 AND 365),  -- every: N日ごと
+  weekday SMALLINT CHECK (weekday BETWEEN 0 AND 6),                             -- weekly: 0=日曜 … 6=土曜
+  assignee_user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+  start_date DATE NOT NULL,                                                     -- 最初の期日の基準日（ハウスの日付）
+  reminded_due DATE,                                                            -- 期限切れを通知済みの期日
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CONSTRAINT chore_schedules_weekday_check CHECK ((rule = 'weekly') = (weekday IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_chore_schedules_house ON chore_schedules(house_id, id);
+
+-- タスクごとの最後の記録を引くため
+CREATE INDEX IF NOT EXISTS idx_events_house_task_performed_live
+  ON events(house_id, task_key, performed_at)
+  WHERE deleted_at IS NULL;
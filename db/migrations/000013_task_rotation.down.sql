@@ -0,0 +1,4 @@
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_rotation_check;
+ALTER TABLE tasks
+  DROP COLUMN IF EXISTS owner_user_id,
+  DROP COLUMN IF EXISTS rotation;
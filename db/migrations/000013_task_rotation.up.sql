@@ -0,0 +1,10 @@
+-- 次の担当の決め方
+--   least_points: 直近の合計が目安（均等割り）より最も少ない人
+--   round_robin:  このタスクを最後にやったのが最も前の人（順番）
+--   fixed:        owner_user_id の人（メンバーでなければ least_points で選ぶ）
+ALTER TABLE tasks
+  ADD COLUMN IF NOT EXISTS rotation TEXT NOT NULL DEFAULT 'least_points',
+  ADD COLUMN IF NOT EXISTS owner_user_id BIGINT REFERENCES users(id) ON DELETE SET NULL;
+
+ALTER TABLE tasks
+  ADD CONSTRAINT tasks_rotation_check CHECK (rotation IN ('least_points', 'round_robin', 'fixed'));
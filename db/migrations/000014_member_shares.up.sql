@@ -0,0 +1,14 @@
+-- メンバーごとの目標の分担（例: 60 と 40）。同じ週に有効な分担の比で按分する
+-- valid_from/valid_to はハウスの日付で、週の初日がこの範囲に入る週に使う（NULLは無期限）
+CREATE TABLE IF NOT EXISTS member_shares(
+  id BIGSERIAL PRIMARY KEY,
+  house_id BIGINT NOT NULL REFERENCES houses(id) ON DELETE CASCADE,
+  user_id  BIGINT NOT NULL REFERENCES users(id)  ON DELETE CASCADE,
+  share NUMERIC(6,2) NOT NULL CHECK (share > 0 AND share <= 1000),
+  valid_from DATE,
+  valid_to   DATE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CONSTRAINT member_shares_range_check CHECK (valid_from IS NULL OR valid_to IS NULL OR valid_from <= valid_to)
+);
+
+CREATE INDEX IF NOT EXISTS idx_member_shares_house ON member_shares(house_id, user_id);
@@ -0,0 +1,13 @@
+-- 獲得したバッジ。codeでメンバーごとに1回だけ（例: streak:7, task:皿洗い:100, champion:2025-11-03）
+CREATE TABLE IF NOT EXISTS achievements(
+  id BIGSERIAL PRIMARY KEY,
+  house_id BIGINT NOT NULL REFERENCES houses(id) ON DELETE CASCADE,
+  user_id  BIGINT NOT NULL REFERENCES users(id)  ON DELETE CASCADE,
+  kind TEXT NOT NULL CHECK (kind IN ('first_report', 'streak', 'task_milestone', 'weekly_champion')),
+  code TEXT NOT NULL,
+  task_key TEXT,            -- task_milestone のみ
+  threshold INT,            -- streak: 日数 / task_milestone: 回数
+  period_start DATE,        -- weekly_champion: 週の初日（ハウスの日付）
+  earned_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CONSTRAINT achievements_code_key UNIQUE (house_id, user_id, code)
+);
@@ -0,0 +1,30 @@
+-- ごほうび（ポイントで交換できるもの）の一覧
+CREATE TABLE IF NOT EXISTS rewards(
+  id BIGSERIAL PRIMARY KEY,
+  house_id BIGINT NOT NULL REFERENCES houses(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  cost NUMERIC(10,1) NOT NULL CHECK (cost > 0),
+  archived_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- アーカイブ済みの名前は再利用できる
+CREATE UNIQUE INDEX IF NOT EXISTS idx_rewards_house_name_live
+  ON rewards(house_id, name)
+  WHERE archived_at IS NULL;
+
+-- 交換の記録。使えるポイント = 記録の合計 - (申請中 + 承認済み) の cost
+-- （ランキングの合計は変わらない）
+CREATE TABLE IF NOT EXISTS redemptions(
+  id BIGSERIAL PRIMARY KEY,
+  house_id  BIGINT NOT NULL REFERENCES houses(id)  ON DELETE CASCADE,
+  reward_id BIGINT NOT NULL REFERENCES rewards(id) ON DELETE CASCADE,
+  user_id   BIGINT NOT NULL REFERENCES users(id)   ON DELETE CASCADE,
+  cost NUMERIC(10,1) NOT NULL CHECK (cost > 0),            -- 申請時点の cost
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+  decided_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+  requested_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  decided_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_redemptions_house_user ON redemptions(house_id, user_id);
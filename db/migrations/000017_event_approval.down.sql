@@ -0,0 +1,17 @@
+DELETE FROM event_audit WHERE action = 'approve';
+ALTER TABLE event_audit
+  DROP CONSTRAINT IF EXISTS event_audit_action_check,
+  ADD CONSTRAINT event_audit_action_check CHECK (action IN ('create', 'edit', 'cancel', 'restore'));
+
+DROP INDEX IF EXISTS idx_events_house_pending;
+ALTER TABLE events
+  DROP CONSTRAINT IF EXISTS events_status_check,
+  DROP COLUMN IF EXISTS auto_approve_at,
+  DROP COLUMN IF EXISTS approved_at,
+  DROP COLUMN IF EXISTS approved_by,
+  DROP COLUMN IF EXISTS status;
+
+ALTER TABLE houses
+  DROP CONSTRAINT IF EXISTS houses_approval_timeout_hours_check,
+  DROP COLUMN IF EXISTS approval_timeout_hours,
+  DROP COLUMN IF EXISTS require_approval;
@@ -0,0 +1,27 @@
+-- 報告の承認制（ハウスごとに任意）
+--   require_approval: 報告はほかのメンバーが承認するまで pending（集計に入らない）
+--   approval_timeout_hours: 承認されないまま経過したら自動で承認扱いにする時間
+ALTER TABLE houses
+  ADD COLUMN IF NOT EXISTS require_approval BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS approval_timeout_hours INT NOT NULL DEFAULT 24;
+
+ALTER TABLE houses
+  ADD CONSTRAINT houses_approval_timeout_hours_check CHECK (approval_timeout_hours BETWEEN 1 AND 168);
+
+-- 既存の記録は承認済み。auto_approve_at を過ぎた pending は承認済みとして集計する
+ALTER TABLE events
+  ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'approved',
+  ADD COLUMN IF NOT EXISTS approved_by BIGINT REFERENCES users(id),
+  ADD COLUMN IF NOT EXISTS approved_at TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS auto_approve_at TIMESTAMPTZ;
+
+ALTER TABLE events
+  ADD CONSTRAINT events_status_check CHECK (status IN ('pending', 'approved'));
+
+CREATE INDEX IF NOT EXISTS idx_events_house_pending
+  ON events(house_id, created_at)
+  WHERE status = 'pending' AND deleted_at IS NULL;
+
+ALTER TABLE event_audit
+  DROP CONSTRAINT IF EXISTS event_audit_action_check,
+  ADD CONSTRAINT event_audit_action_check CHECK (action IN ('create', 'edit', 'cancel', 'restore', 'approve'));
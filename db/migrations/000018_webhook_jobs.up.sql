@@ -0,0 +1,24 @@
+-- 署名を確認したLINE Webhookのイベント（1イベント1行）。204を返す前に保存し、ワーカーが処理する
+--   queued: 処理待ち（run_at 以降に取り出す） / running: 処理中 / done: 処理済み / dead: 再試行の上限に達した
+CREATE TABLE IF NOT EXISTS webhook_jobs(
+  id BIGSERIAL PRIMARY KEY,
+  event_id TEXT,                                      -- webhookEventId
+  destination TEXT NOT NULL DEFAULT '',               -- ボットのユーザーID
+  payload JSONB NOT NULL,                             -- イベントのJSON
+  status TEXT NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'done', 'dead')),
+  attempts INT NOT NULL DEFAULT 0,
+  run_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  locked_at TIMESTAMPTZ,
+  last_error TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_jobs_queued
+  ON webhook_jobs(run_at, id)
+  WHERE status = 'queued';
+
+-- 落ちたインスタンスが処理中のまま残した行の取り直し用
+CREATE INDEX IF NOT EXISTS idx_webhook_jobs_running
+  ON webhook_jobs(locked_at)
+  WHERE status = 'running';
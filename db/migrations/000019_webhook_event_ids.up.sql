@@ -0,0 +1,8 @@
+-- 受け付けたLINE Webhookの webhookEventId（再送の重複を防ぐ。expires_at を過ぎた行は削除してよい）
+CREATE TABLE IF NOT EXISTS webhook_event_ids(
+  event_id TEXT PRIMARY KEY,
+  received_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_event_ids_expires ON webhook_event_ids(expires_at);
@@ -0,0 +1,6 @@
+-- LINEのプッシュ送信数（月ごと。LINEの月間上限に合わせて日本時間の月で数える）
+CREATE TABLE IF NOT EXISTS line_push_usage(
+  month DATE PRIMARY KEY,
+  messages INT NOT NULL DEFAULT 0,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
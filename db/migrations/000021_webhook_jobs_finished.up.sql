@@ -0,0 +1,4 @@
+-- 処理済み・deadの行を保存期間を過ぎたら削除するための索引
+CREATE INDEX IF NOT EXISTS idx_webhook_jobs_finished
+  ON webhook_jobs(status, finished_at)
+  WHERE status IN ('done', 'dead');
@@ -0,0 +1,3 @@
+ALTER TABLE chore_schedules
+  DROP COLUMN IF EXISTS reminder_claimed_at,
+  DROP COLUMN IF EXISTS reminding_due;
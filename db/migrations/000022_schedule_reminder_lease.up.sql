@@ -0,0 +1,5 @@
+-- 期限切れ通知の送信権（リース）。送っている途中で落ちたインスタンスの分は、古くなったら取り直して送る
+-- （reminded_due は送れた期日だけを記録する）
+ALTER TABLE chore_schedules
+  ADD COLUMN IF NOT EXISTS reminding_due DATE,               -- 送信中の期日
+  ADD COLUMN IF NOT EXISTS reminder_claimed_at TIMESTAMPTZ;  -- 送信権を取った時刻
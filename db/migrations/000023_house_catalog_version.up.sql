@@ -0,0 +1,2 @@
+-- タスク辞書の版。辞書（タスク・別名）を書き換えるたびに上げ、各インスタンスは版が変わったらキャッシュを読み直す
+ALTER TABLE houses ADD COLUMN IF NOT EXISTS catalog_version BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,240 @@
+// Package flex LINEのFlex Message（カード型の返信）の中身を組み立てる。
+// ポイントの表記などの整形は呼び出し側で済ませた文字列を受け取る
+package flex
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	colorAccent = "#1DB446"
+	colorText   = "#333333"
+	colorSub    = "#999999"
+	colorTrack  = "#EEEEEE"
+
+	maxCarouselBubbles = 12 // LINEのカルーセルに並べられるカードの上限
+	tasksPerBubble     = 6
+	maxRankingRows     = 10
+)
+
+var medals = []string{"🥇", "🥈", "🥉"}
+
+// Component ボックス・テキストなどの部品（使う項目だけ出力する）
+type Component struct {
+	Type            string      `json:"type"`
+	Layout          string      `json:"layout,omitempty"`
+	Contents        []Component `json:"contents,omitempty"`
+	Text            string      `json:"text,omitempty"`
+	Size            string      `json:"size,omitempty"`
+	Weight          string      `json:"weight,omitempty"`
+	Color           string      `json:"color,omitempty"`
+	Align           string      `json:"align,omitempty"`
+	Flex            *int        `json:"flex,omitempty"`
+	Margin          string      `json:"margin,omitempty"`
+	Spacing         string      `json:"spacing,omitempty"`
+	Wrap            bool        `json:"wrap,omitempty"`
+	Width           string      `json:"width,omitempty"`
+	Height          string      `json:"height,omitempty"`
+	BackgroundColor string      `json:"backgroundColor,omitempty"`
+	CornerRadius    string      `json:"cornerRadius,omitempty"`
+}
+
+// Bubble カード1枚
+type Bubble struct {
+	Type   string     `json:"type"`
+	Size   string     `json:"size,omitempty"`
+	Header *Component `json:"header,omitempty"`
+	Body   *Component `json:"body,omitempty"`
+	Footer *Component `json:"footer,omitempty"`
+}
+
+// Carousel 横にスクロールするカードの並び
+type Carousel struct {
+	Type     string   `json:"type"`
+	Contents []Bubble `json:"contents"`
+}
+
+// Row 棒グラフの1行。棒の長さはValueを行の最大値に対する割合で決め、Labelを右に出す
+type Row struct {
+	Name  string
+	Value float64
+	Label string
+}
+
+// Task タスク一覧の1件（Rule は「50pt/10分」などの採点ルール、Aliases は別名の表記）
+type Task struct {
+	Name    string
+	Rule    string
+	Aliases string
+}
+
+// RankingCard 順位表（rowsは多い順）。上位3人にはメダルを付け、載せきれない人数は「ほか N 人」と添える
+func RankingCard(title string, rows []Row) Bubble {
+	hidden := len(rows) - maxRankingRows
+	if hidden > 0 {
+		rows = rows[:maxRankingRows]
+	}
+	peak := maxValue(rows)
+	contents := make([]Component, 0, len(rows))
+	for i, row := range rows {
+		rank := fmt.Sprintf("%d位", i+1)
+		if i < len(medals) {
+			rank = medals[i]
+		}
+		contents = append(contents, barRow(rank, row, peak))
+	}
+	return Bubble{
+		Type:   "bubble",
+		Header: header(title, ""),
+		Body:   &Component{Type: "box", Layout: "vertical", Spacing: "md", Contents: contents},
+		Footer: moreFooter(hidden, "人"),
+	}
+}
+
+// BreakdownCard 1人分の合計（total）と内訳
+func BreakdownCard(title, total string, rows []Row) Bubble {
+	peak := maxValue(rows)
+	contents := make([]Component, 0, len(rows))
+	for _, row := range rows {
+		contents = append(contents, barRow("", row, peak))
+	}
+	b := Bubble{Type: "bubble", Header: header(title, total)}
+	if len(contents) > 0 {
+		b.Body = &Component{Type: "box", Layout: "vertical", Spacing: "md", Contents: contents}
+	}
+	return b
+}
+
+// TaskCarousel タスク一覧。tasksPerBubble件ずつのカードを横に並べる
+// （上限を超えた分は載せず、最後のカードに「ほか N 件」と添える）
+func TaskCarousel(title string, tasks []Task) Carousel {
+	pages := (len(tasks) + tasksPerBubble - 1) / tasksPerBubble
+	hidden := 0
+	if pages > maxCarouselBubbles {
+		pages = maxCarouselBubbles
+		hidden = len(tasks) - pages*tasksPerBubble
+	}
+	out := Carousel{Type: "carousel", Contents: make([]Bubble, 0, pages)}
+	for p := 0; p < pages; p++ {
+		page := tasks[p*tasksPerBubble : min((p+1)*tasksPerBubble, len(tasks))]
+		contents := make([]Component, 0, len(page))
+		for i, t := range page {
+			contents = append(contents, taskRow(t, i > 0))
+		}
+		sub := ""
+		if pages > 1 {
+			sub = fmt.Sprintf("%d/%d", p+1, pages)
+		}
+		b := Bubble{
+			Type:   "bubble",
+			Size:   "kilo",
+			Header: header(title, sub),
+			Body:   &Component{Type: "box", Layout: "vertical", Spacing: "md", Contents: contents},
+		}
+		if p == pages-1 {
+			b.Footer = moreFooter(hidden, "件")
+		}
+		out.Contents = append(out.Contents, b)
+	}
+	return out
+}
+
+// header タイトルと、あれば大きめの補足（合計ポイント・ページ番号）
+func header(title, sub string) *Component {
+	contents := []Component{{Type: "text", Text: title, Size: "sm", Weight: "bold", Color: colorAccent}}
+	if sub != "" {
+		contents = append(contents, Component{Type: "text", Text: sub, Size: "xl", Weight: "bold", Color: colorText, Margin: "sm"})
+	}
+	return &Component{Type: "box", Layout: "vertical", Contents: contents}
+}
+
+// moreFooter 載せきれなかった数の注記（hiddenが0以下ならnil）
+func moreFooter(hidden int, unit string) *Component {
+	if hidden <= 0 {
+		return nil
+	}
+	return &Component{Type: "box", Layout: "vertical", Contents: []Component{
+		{Type: "text", Text: fmt.Sprintf("ほか %d %s", hidden, unit), Size: "xs", Color: colorSub, Align: "center"},
+	}}
+}
+
+// barRow 「順位 名前 値」の行と、その下の棒
+func barRow(rank string, row Row, peak float64) Component {
+	line := make([]Component, 0, 3)
+	if rank != "" {
+		line = append(line, Component{Type: "text", Text: rank, Size: "sm", Flex: flexRatio(0), Color: colorText})
+	}
+	line = append(line,
+		Component{Type: "text", Text: row.Name, Size: "sm", Flex: flexRatio(1), Color: colorText, Margin: marginIf(rank != "")},
+		Component{Type: "text", Text: row.Label, Size: "sm", Flex: flexRatio(0), Align: "end", Weight: "bold", Color: colorText},
+	)
+	bar := Component{
+		Type:            "box",
+		Layout:          "vertical",
+		Height:          "6px",
+		Margin:          "sm",
+		BackgroundColor: colorTrack,
+		CornerRadius:    "3px",
+		Contents: []Component{{
+			Type:            "box",
+			Layout:          "vertical",
+			Width:           fmt.Sprintf("%d%%", percent(row.Value, peak)),
+			Height:          "6px",
+			BackgroundColor: colorAccent,
+			CornerRadius:    "3px",
+			Contents:        []Component{{Type: "filler"}},
+		}},
+	}
+	return Component{
+		Type:     "box",
+		Layout:   "vertical",
+		Contents: []Component{{Type: "box", Layout: "horizontal", Contents: line}, bar},
+	}
+}
+
+// taskRow タスク名と採点ルール、下に別名
+func taskRow(t Task, separated bool) Component {
+	contents := []Component{{
+		Type:   "box",
+		Layout: "horizontal",
+		Contents: []Component{
+			{Type: "text", Text: t.Name, Size: "sm", Weight: "bold", Flex: flexRatio(1), Color: colorText, Wrap: true},
+			{Type: "text", Text: t.Rule, Size: "sm", Flex: flexRatio(0), Align: "end", Color: colorText},
+		},
+	}}
+	if t.Aliases != "" {
+		contents = append(contents, Component{Type: "text", Text: "別名: " + t.Aliases, Size: "xs", Color: colorSub, Wrap: true})
+	}
+	row := Component{Type: "box", Layout: "vertical", Contents: contents}
+	if separated {
+		return Component{Type: "box", Layout: "vertical", Spacing: "md", Contents: []Component{{Type: "separator"}, row}}
+	}
+	return row
+}
+
+func maxValue(rows []Row) float64 {
+	peak := 0.0
+	for _, r := range rows {
+		peak = math.Max(peak, r.Value)
+	}
+	return peak
+}
+
+// percent 棒の長さ（0〜100）。最大値が0以下なら棒を出さない
+func percent(v, peak float64) int {
+	if peak <= 0 || v <= 0 {
+		return 0
+	}
+	return int(math.Round(math.Min(v/peak, 1) * 100))
+}
+
+func marginIf(ok bool) string {
+	if ok {
+		return "md"
+	}
+	return ""
+}
+
+// flexRatio 横並びの部品の幅の比（Component.Flex）。0なら中身の幅に合わせる
+func flexRatio(n int) *int { return &n }
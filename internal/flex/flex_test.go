@@ -0,0 +1,95 @@
+package flex
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "testdata の golden ファイルを書き直す")
+
+// assertGolden vをJSONにしてtestdata/name と比べる（go test ./internal/flex -update で更新）
+func assertGolden(t *testing.T, name string, v any) {
+	t.Helper()
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	got = append(got, '\n')
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("write golden: %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s mismatch:\n%s", name, got)
+	}
+}
+
+func TestRankingCard(t *testing.T) {
+	assertGolden(t, "ranking.golden.json", RankingCard("今週のランキング", []Row{
+		{Name: "Bob", Value: 460, Label: "460pt"},
+		{Name: "Alice", Value: 280, Label: "280pt"},
+		{Name: "Carol", Value: 75.5, Label: "75.5pt"},
+		{Name: "Dave", Value: 0, Label: "0pt"},
+	}))
+}
+
+func TestBreakdownCard(t *testing.T) {
+	assertGolden(t, "breakdown.golden.json", BreakdownCard("今月のポイント", "280pt", []Row{
+		{Name: "皿洗い", Value: 200, Label: "200pt"},
+		{Name: "ゴミ出し", Value: 80, Label: "80pt"},
+	}))
+}
+
+func TestTaskCarousel(t *testing.T) {
+	tasks := []Task{
+		{Name: "皿洗い", Rule: "100pt", Aliases: "さらあらい, dishes"},
+		{Name: "散歩", Rule: "50pt/10分 上限6"},
+		{Name: "洗濯", Rule: "80pt"},
+		{Name: "ゴミ出し", Rule: "30pt", Aliases: "ごみ"},
+		{Name: "掃除機", Rule: "60pt"},
+		{Name: "風呂掃除", Rule: "90pt"},
+		{Name: "窓拭き", Rule: "150pt", Aliases: "まど, 窓"},
+	}
+	c := TaskCarousel("登録タスク", tasks)
+	if len(c.Contents) != 2 {
+		t.Fatalf("expected 2 bubbles for %d tasks, got %d", len(tasks), len(c.Contents))
+	}
+	assertGolden(t, "tasks.golden.json", c)
+
+	if c.Contents[1].Footer != nil {
+		t.Fatalf("expected no footer when every task fits, got %+v", c.Contents[1].Footer)
+	}
+
+	many := make([]Task, (maxCarouselBubbles+1)*tasksPerBubble)
+	capped := TaskCarousel("登録タスク", many)
+	if got := len(capped.Contents); got != maxCarouselBubbles {
+		t.Fatalf("expected carousel capped at %d bubbles, got %d", maxCarouselBubbles, got)
+	}
+	// 載せきれなかった分は最後のカードに件数を出す
+	last := capped.Contents[len(capped.Contents)-1]
+	if last.Footer == nil || last.Footer.Contents[0].Text != "ほか 6 件" {
+		t.Fatalf("unexpected footer on the last bubble: %+v", last.Footer)
+	}
+}
+
+func TestRankingCardTruncated(t *testing.T) {
+	rows := make([]Row, maxRankingRows+2)
+	b := RankingCard("今週のランキング", rows)
+	if got := len(b.Body.Contents); got != maxRankingRows {
+		t.Fatalf("expected %d rows, got %d", maxRankingRows, got)
+	}
+	if b.Footer == nil || b.Footer.Contents[0].Text != "ほか 2 人" {
+		t.Fatalf("unexpected footer: %+v", b.Footer)
+	}
+}
@@ -0,0 +1,132 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"chores_contributor/internal/repo"
+	"chores_contributor/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type achievementResp struct {
+	Kind        string    `json:"kind"`
+	Code        string    `json:"code"`
+	Title       string    `json:"title"`
+	Task        string    `json:"task,omitempty"`
+	Threshold   int       `json:"threshold,omitempty"`
+	PeriodStart string    `json:"period_start,omitempty"`
+	EarnedAt    time.Time `json:"earned_at"`
+}
+
+type achievementReportResp struct {
+	UserID        string            `json:"user_id"`
+	CurrentStreak int               `json:"current_streak"`
+	BestStreak    int               `json:"best_streak"`
+	Achievements  []achievementResp `json:"achievements"`
+}
+
+func toAchievementReportResp(report service.AchievementReport) achievementReportResp {
+	out := achievementReportResp{
+		UserID:        report.UserID,
+		CurrentStreak: report.CurrentStreak,
+		BestStreak:    report.BestStreak,
+		Achievements:  make([]achievementResp, 0, len(report.Achievements)),
+	}
+	for _, a := range report.Achievements {
+		ar := achievementResp{
+			Kind:      a.Kind,
+			Code:      a.Code,
+			Title:     formatAchievementTitle(a),
+			Task:      a.TaskKey,
+			Threshold: a.Threshold,
+			EarnedAt:  a.EarnedAt,
+		}
+		if a.PeriodStart != nil {
+			ar.PeriodStart = a.PeriodStart.Format("2006-01-02")
+		}
+		out.Achievements = append(out.Achievements, ar)
+	}
+	return out
+}
+
+// mountAchievementRoutes バッジの一覧API
+func mountAchievementRoutes(r chi.Router, sv *service.Service) {
+	// GET /houses/{group}/users/{user}/achievements
+	r.Get("/houses/{group}/users/{user}/achievements", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		user := chi.URLParam(r, "user")
+		report, err := sv.UserAchievements(r.Context(), group, user)
+		if err != nil {
+			if errors.Is(err, repo.ErrNoMemberFound) {
+				writeErr(w, 404, "member not found")
+				return
+			}
+			log.Printf("achievements error: group=%s user=%s err=%v", group, user, err)
+			writeErr(w, 500, "internal error")
+			return
+		}
+		writeJSON(w, 200, toAchievementReportResp(report))
+	})
+}
+
+// formatAchievementTitle バッジの名前（例: 7日連続、皿洗い 100回）
+func formatAchievementTitle(a service.Achievement) string {
+	switch a.Kind {
+	case service.AchievementFirstReport:
+		return "はじめての報告"
+	case service.AchievementStreak:
+		return fmt.Sprintf("%d日連続", a.Threshold)
+	case service.AchievementTaskMilestone:
+		return fmt.Sprintf("%s %d回", a.TaskKey, a.Threshold)
+	case service.AchievementWeeklyChampion:
+		if a.PeriodStart != nil {
+			return fmt.Sprintf("週間チャンピオン（%s〜の週）", a.PeriodStart.Format("1/2"))
+		}
+		return "週間チャンピオン"
+	}
+	return a.Code
+}
+
+// formatAchievementNotice 報告で新しく獲得したバッジの知らせ
+func formatAchievementNotice(achievements []service.Achievement) string {
+	titles := make([]string, 0, len(achievements))
+	for _, a := range achievements {
+		titles = append(titles, "「"+formatAchievementTitle(a)+"」")
+	}
+	return "バッジ獲得: " + strings.Join(titles, "")
+}
+
+// lineAchievementsReply "@bot バッジ" の返信文
+func lineAchievementsReply(ctx context.Context, sv *service.Service, groupID, userID string) string {
+	report, err := sv.UserAchievements(ctx, groupID, userID)
+	if err != nil {
+		if errors.Is(err, repo.ErrNoMemberFound) {
+			return "まだバッジはないよ。家事を報告して集めよう！"
+		}
+		log.Printf("LINE achievements error: group=%s user=%s err=%v", groupID, userID, err)
+		noteLineFailure(ctx, err)
+		return "取得失敗: 少し待ってから試してね"
+	}
+	return formatAchievements(report)
+}
+
+func formatAchievements(report service.AchievementReport) string {
+	lines := make([]string, 0, len(report.Achievements)+2)
+	lines = append(lines, fmt.Sprintf("連続 %d日（最長 %d日）", report.CurrentStreak, report.BestStreak))
+	if len(report.Achievements) == 0 {
+		lines = append(lines, "まだバッジはないよ。家事を報告して集めよう！")
+		return strings.Join(lines, "\n")
+	}
+	lines = append(lines, fmt.Sprintf("バッジ %d個:", len(report.Achievements)))
+	for _, a := range report.Achievements {
+		lines = append(lines, fmt.Sprintf("・%s（%s）", formatAchievementTitle(a), a.EarnedAt.Format("1/2")))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,149 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"chores_contributor/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type assignmentCandidateResp struct {
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Points     float64    `json:"points"`
+	Deficit    float64    `json:"deficit"`
+	LastDoneAt *time.Time `json:"last_done_at,omitempty"`
+}
+
+type assignmentResp struct {
+	Task       string                    `json:"task"`
+	Rotation   string                    `json:"rotation"`
+	UserID     string                    `json:"user_id"`
+	Name       string                    `json:"name"`
+	Fallback   bool                      `json:"fallback"`
+	Candidates []assignmentCandidateResp `json:"candidates"`
+}
+
+type assignmentPlanResp struct {
+	Since       time.Time        `json:"since"`
+	FairShare   float64          `json:"fair_share"`
+	Assignments []assignmentResp `json:"assignments"`
+}
+
+func toAssignmentPlanResp(plan service.AssignmentPlan) assignmentPlanResp {
+	out := assignmentPlanResp{
+		Since:       plan.Since,
+		FairShare:   plan.FairShare,
+		Assignments: make([]assignmentResp, 0, len(plan.Assignments)),
+	}
+	for _, a := range plan.Assignments {
+		ar := assignmentResp{
+			Task:       a.TaskKey,
+			Rotation:   a.Rotation,
+			UserID:     a.UserID,
+			Name:       a.Name,
+			Fallback:   a.Fallback,
+			Candidates: make([]assignmentCandidateResp, 0, len(a.Candidates)),
+		}
+		for _, c := range a.Candidates {
+			ar.Candidates = append(ar.Candidates, assignmentCandidateResp{
+				UserID:     c.UserID,
+				Name:       c.Name,
+				Points:     c.Points,
+				Deficit:    c.Deficit,
+				LastDoneAt: c.LastDoneAt,
+			})
+		}
+		out.Assignments = append(out.Assignments, ar)
+	}
+	return out
+}
+
+// mountAssignmentRoutes 次の担当を提案するAPI
+func mountAssignmentRoutes(r chi.Router, sv *service.Service) {
+	// GET /houses/{group}/assignments/next[?task=皿洗い]
+	r.Get("/houses/{group}/assignments/next", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		plan, err := sv.NextAssignments(r.Context(), group, strings.TrimSpace(r.URL.Query().Get("task")))
+		if err != nil {
+			var amb *service.TaskAmbiguousError
+			switch {
+			case errors.Is(err, service.ErrTaskNotFound):
+				writeErr(w, 400, "unknown task")
+			case errors.As(err, &amb):
+				writeErr(w, 400, "ambiguous task: "+strings.Join(amb.Candidates, ", "))
+			case errors.Is(err, service.ErrNoHouseMembers):
+				writeErr(w, 404, "house has no members")
+			default:
+				log.Printf("assignment error: group=%s err=%v", group, err)
+				writeErr(w, 500, "internal error")
+			}
+			return
+		}
+		writeJSON(w, 200, toAssignmentPlanResp(plan))
+	})
+}
+
+// lineNextAssigneeReply "@bot 次誰 [タスク]" の返信文
+func lineNextAssigneeReply(ctx context.Context, sv *service.Service, groupID string, args []string) string {
+	task := strings.Join(args, " ")
+	plan, err := sv.NextAssignments(ctx, groupID, task)
+	if err != nil {
+		var amb *service.TaskAmbiguousError
+		switch {
+		case errors.Is(err, service.ErrTaskNotFound):
+			return "不明なタスクだよ。@bot task で一覧を確認してね"
+		case errors.As(err, &amb):
+			return fmt.Sprintf("候補が複数あるよ: %s", strings.Join(amb.Candidates, "/"))
+		case errors.Is(err, service.ErrNoHouseMembers):
+			return "まだメンバーがいないよ。まずは家事を報告してね。"
+		}
+		log.Printf("LINE assignment error: group=%s task=%s err=%v", groupID, task, err)
+		noteLineFailure(ctx, err)
+		return "取得失敗: 少し待ってから試してね"
+	}
+	if task != "" && len(plan.Assignments) == 1 {
+		return formatNextAssignee(plan)
+	}
+	return formatAssignmentList(plan)
+}
+
+// formatNextAssignee 1タスク分の提案（理由つき）
+func formatNextAssignee(plan service.AssignmentPlan) string {
+	a := plan.Assignments[0]
+	lines := []string{fmt.Sprintf("次の%sは %s さんの番だよ。", a.TaskKey, a.Name)}
+	if len(a.Candidates) > 0 {
+		c := a.Candidates[0]
+		switch {
+		case a.Rotation == service.RotationFixed && !a.Fallback:
+			lines = append(lines, "（担当者が決まっているタスク）")
+		case a.Rotation == service.RotationRoundRobin && c.LastDoneAt != nil:
+			lines = append(lines, fmt.Sprintf("（順番制・前回は%s）", c.LastDoneAt.Format("1/2")))
+		case a.Rotation == service.RotationRoundRobin:
+			lines = append(lines, "（順番制・まだやったことがない）")
+		default:
+			lines = append(lines, fmt.Sprintf("（直近%d週 %s・目安 %s）", service.AssignmentWindowDays/7, formatPoints(c.Points), formatPoints(plan.FairShare)))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatAssignmentList 全タスクの次の担当
+func formatAssignmentList(plan service.AssignmentPlan) string {
+	if len(plan.Assignments) == 0 {
+		return "タスクが登録されていないよ。"
+	}
+	lines := make([]string, 0, len(plan.Assignments)+1)
+	lines = append(lines, "次の担当:")
+	for _, a := range plan.Assignments {
+		lines = append(lines, fmt.Sprintf("・%s → %s", a.TaskKey, a.Name))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,248 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"chores_contributor/internal/repo"
+	"chores_contributor/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type shareResp struct {
+	ID        int64   `json:"id"`
+	UserID    string  `json:"user_id"`
+	Name      string  `json:"name,omitempty"`
+	Share     float64 `json:"share"`
+	ValidFrom string  `json:"valid_from,omitempty"`
+	ValidTo   string  `json:"valid_to,omitempty"`
+}
+
+func toShareResp(sh service.MemberShare) shareResp {
+	out := shareResp{ID: sh.ID, UserID: sh.UserID, Name: sh.Name, Share: sh.Share}
+	if sh.ValidFrom != nil {
+		out.ValidFrom = sh.ValidFrom.Format("2006-01-02")
+	}
+	if sh.ValidTo != nil {
+		out.ValidTo = sh.ValidTo.Format("2006-01-02")
+	}
+	return out
+}
+
+type balanceMemberResp struct {
+	UserID      string  `json:"user_id"`
+	Name        string  `json:"name"`
+	Points      float64 `json:"points"`
+	Share       float64 `json:"share"`
+	TargetShare float64 `json:"target_share"`
+	Expected    float64 `json:"expected"`
+	Debt        float64 `json:"debt"`
+}
+
+type balanceWeekResp struct {
+	Start   string              `json:"start"`
+	End     string              `json:"end"`
+	Total   float64             `json:"total"`
+	Members []balanceMemberResp `json:"members"`
+}
+
+type balanceResp struct {
+	From     string              `json:"from"`
+	To       string              `json:"to"`
+	Total    float64             `json:"total"`
+	Targeted bool                `json:"targeted"`
+	Members  []balanceMemberResp `json:"members"`
+	Weeks    []balanceWeekResp   `json:"weeks"`
+}
+
+func toBalanceMembers(members []service.BalanceMember) []balanceMemberResp {
+	out := make([]balanceMemberResp, 0, len(members))
+	for _, m := range members {
+		out = append(out, balanceMemberResp{
+			UserID:      m.UserID,
+			Name:        m.Name,
+			Points:      m.Points,
+			Share:       m.Share,
+			TargetShare: m.TargetShare,
+			Expected:    m.Expected,
+			Debt:        m.Debt,
+		})
+	}
+	return out
+}
+
+// toBalanceResp 日付は両端を含む形（to は最終日）で返す
+func toBalanceResp(bal service.Balance) balanceResp {
+	const day = "2006-01-02"
+	out := balanceResp{
+		From:     bal.Start.Format(day),
+		To:       bal.End.AddDate(0, 0, -1).Format(day),
+		Total:    bal.Total,
+		Targeted: bal.Targeted,
+		Members:  toBalanceMembers(bal.Members),
+		Weeks:    make([]balanceWeekResp, 0, len(bal.Weeks)),
+	}
+	for _, w := range bal.Weeks {
+		out.Weeks = append(out.Weeks, balanceWeekResp{
+			Start:   w.Start.Format(day),
+			End:     w.End.AddDate(0, 0, -1).Format(day),
+			Total:   w.Total,
+			Members: toBalanceMembers(w.Members),
+		})
+	}
+	return out
+}
+
+// writeShareErr 目標の分担・バランスのエラーをHTTPステータスに変換する
+func writeShareErr(w http.ResponseWriter, group string, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidShare), errors.Is(err, service.ErrInvalidSummary):
+		writeErr(w, 400, err.Error())
+	case errors.Is(err, repo.ErrNoShareFound):
+		writeErr(w, 404, "share not found")
+	default:
+		log.Printf("balance error: group=%s err=%v", group, err)
+		writeErr(w, 500, "internal error")
+	}
+}
+
+// mountBalanceRoutes 目標の分担の登録とバランスAPI
+func mountBalanceRoutes(r chi.Router, sv *service.Service) {
+	// GET /houses/{group}/shares
+	r.Get("/houses/{group}/shares", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		shares, err := sv.ListShares(r.Context(), group)
+		if err != nil {
+			writeShareErr(w, group, err)
+			return
+		}
+		out := make([]shareResp, 0, len(shares))
+		for _, sh := range shares {
+			out = append(out, toShareResp(sh))
+		}
+		writeJSON(w, 200, map[string]any{"shares": out})
+	})
+
+	// POST /houses/{group}/shares
+	// { "user": "U123", "share": 60, "valid_from": "2025-11-03" }
+	r.Post("/houses/{group}/shares", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		var in service.ShareInput
+		if !decodeJSON(w, r, &in) {
+			return
+		}
+		sh, err := sv.CreateShare(r.Context(), group, in)
+		if err != nil {
+			writeShareErr(w, group, err)
+			return
+		}
+		writeJSON(w, 201, toShareResp(sh))
+	})
+
+	// DELETE /houses/{group}/shares/{id}
+	r.Delete("/houses/{group}/shares/{id}", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil || id <= 0 {
+			writeErr(w, 400, "invalid share id")
+			return
+		}
+		if err := sv.DeleteShare(r.Context(), group, id); err != nil {
+			writeShareErr(w, group, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// GET /houses/{group}/balance?from=2025-11-03&to=2025-11-30
+	// from/to は両端を含むハウスの日付（週単位に広げる）。両方省略すると直近4週
+	r.Get("/houses/{group}/balance", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		q := r.URL.Query()
+		if (q.Get("from") == "") != (q.Get("to") == "") {
+			writeErr(w, 400, "from and to must be given together")
+			return
+		}
+		var from, to time.Time
+		for name, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+			raw := q.Get(name)
+			if raw == "" {
+				continue
+			}
+			t, err := time.Parse("2006-01-02", raw)
+			if err != nil {
+				writeErr(w, 400, name+" must be YYYY-MM-DD")
+				return
+			}
+			*dst = t
+		}
+		bal, err := sv.Balance(r.Context(), group, from, to)
+		if err != nil {
+			writeShareErr(w, group, err)
+			return
+		}
+		writeJSON(w, 200, toBalanceResp(bal))
+	})
+}
+
+// lineBalanceReply "@bot バランス [週|月|年]" の返信文（省略時は直近4週）
+func lineBalanceReply(ctx context.Context, sv *service.Service, groupID string, args []string) string {
+	var (
+		bal service.Balance
+		err error
+	)
+	if len(args) == 0 {
+		bal, err = sv.Balance(ctx, groupID, time.Time{}, time.Time{})
+	} else {
+		unit, _, ok := linePeriod(args)
+		if !ok || unit == service.GranularityDay {
+			return "期間は 週/月/年 で指定してね（例: @bot バランス 月）"
+		}
+		bal, err = sv.PeriodBalance(ctx, groupID, unit, time.Now())
+	}
+	if err != nil {
+		log.Printf("LINE balance error: group=%s err=%v", groupID, err)
+		noteLineFailure(ctx, err)
+		return "取得失敗: 少し待ってから試してね"
+	}
+	return formatBalance(bal)
+}
+
+// formatPercent 割合（0〜1）を整数の%で表す
+func formatPercent(ratio float64) string {
+	return fmt.Sprintf("%.0f%%", math.Round(ratio*100))
+}
+
+// formatBalance 各メンバーの実績の割合と目標、持ち越した差
+func formatBalance(bal service.Balance) string {
+	if len(bal.Members) == 0 {
+		return "まだメンバーがいないよ。まずは家事を報告してね。"
+	}
+	lines := make([]string, 0, len(bal.Members)+3)
+	lines = append(lines, fmt.Sprintf("バランス（%s〜%s）", bal.Start.Format("1/2"), bal.End.AddDate(0, 0, -1).Format("1/2")))
+	for _, m := range bal.Members {
+		var debt string
+		switch {
+		case m.Debt >= 0.05:
+			debt = "あと" + formatPoints(m.Debt)
+		case m.Debt <= -0.05:
+			debt = formatPoints(-m.Debt) + " 多め"
+		default:
+			debt = "目標どおり"
+		}
+		lines = append(lines, fmt.Sprintf("・%s %s %s（目標 %s）%s", m.Name, formatPoints(m.Points), formatPercent(m.Share), formatPercent(m.TargetShare), debt))
+	}
+	lines = append(lines, "合計 "+formatPoints(bal.Total))
+	if !bal.Targeted {
+		lines = append(lines, "目標の分担が未設定のため、均等で計算しているよ。")
+	}
+	return strings.Join(lines, "\n")
+}
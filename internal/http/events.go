@@ -0,0 +1,409 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"chores_contributor/internal/repo"
+	"chores_contributor/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/text/width"
+)
+
+const lineHistoryLimit = 10
+
+type eventResp struct {
+	ID          int64     `json:"id"`
+	Seq         int64     `json:"seq"`
+	GroupID     string    `json:"group_id"`
+	UserID      string    `json:"user_id"`
+	Task        string    `json:"task"`
+	Option      *string   `json:"option,omitempty"`
+	Points      float64   `json:"points"`
+	PerformedAt time.Time `json:"performed_at"`
+	CreatedAt   time.Time `json:"created_at"`
+	Status      string    `json:"status"`
+}
+
+func toEventResp(ev service.Event) eventResp {
+	status := repo.EventApproved
+	if ev.Pending {
+		status = repo.EventPending
+	}
+	return eventResp{
+		ID:          ev.ID,
+		Seq:         ev.Seq,
+		GroupID:     ev.GroupID,
+		UserID:      ev.UserID,
+		Task:        ev.TaskKey,
+		Option:      ev.Option,
+		Points:      ev.Points,
+		PerformedAt: ev.PerformedAt,
+		CreatedAt:   ev.CreatedAt,
+		Status:      status,
+	}
+}
+
+func eventIDParam(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeErr(w, 400, "invalid event id")
+		return 0, false
+	}
+	return id, true
+}
+
+// writeEventErr イベント操作のエラーをHTTPステータスに変換する
+func writeEventErr(w http.ResponseWriter, id int64, err error) {
+	var amb *service.TaskAmbiguousError
+	switch {
+	case errors.Is(err, repo.ErrNoEventFound):
+		writeErr(w, 404, "event not found")
+	case errors.Is(err, service.ErrTaskNotFound):
+		writeErr(w, 400, "unknown task")
+	case errors.As(err, &amb):
+		writeErr(w, 400, "ambiguous task: "+strings.Join(amb.Candidates, ", "))
+	case errors.Is(err, service.ErrInvalidEventPatch), errors.Is(err, service.ErrInvalidOption), errors.Is(err, service.ErrInvalidApproval):
+		writeErr(w, 400, err.Error())
+	case errors.Is(err, service.ErrSelfApproval):
+		writeErr(w, 403, "cannot approve own report")
+	case errors.Is(err, repo.ErrNoMemberFound):
+		writeErr(w, 404, "member not found")
+	case errors.Is(err, repo.ErrNotPending):
+		writeErr(w, 409, "event is not pending")
+	default:
+		log.Printf("event error: id=%d err=%v", id, err)
+		writeErr(w, 500, "internal error")
+	}
+}
+
+type auditResp struct {
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor,omitempty"`
+	Source    string    `json:"source"`
+	Task      string    `json:"task"`
+	Option    *string   `json:"option,omitempty"`
+	Points    float64   `json:"points"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// httpActor HTTP APIは利用者を識別しないため、経路だけを履歴に残す
+var httpActor = repo.Actor{Source: repo.SourceHTTP}
+
+// mountEventRoutes 記録済みイベントの取り消し・修正API
+func mountEventRoutes(r chi.Router, sv *service.Service) {
+	// DELETE /events/{id}
+	r.Delete("/events/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := eventIDParam(w, r)
+		if !ok {
+			return
+		}
+		ev, err := sv.GetEvent(r.Context(), id)
+		if err != nil {
+			writeEventErr(w, id, err)
+			return
+		}
+		if _, err := sv.CancelEvent(r.Context(), id, httpActor); err != nil {
+			writeEventErr(w, id, err)
+			return
+		}
+		writeJSON(w, 200, toEventResp(ev))
+	})
+
+	// PATCH /events/{id}
+	// { "task": "ゴミ出し" } / { "option": "30分" } / { "option": "" } → オプションを外す
+	r.Patch("/events/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := eventIDParam(w, r)
+		if !ok {
+			return
+		}
+		var patch service.EventPatch
+		if !decodeJSON(w, r, &patch) {
+			return
+		}
+		ev, err := sv.EditEvent(r.Context(), id, patch, httpActor)
+		if err != nil {
+			writeEventErr(w, id, err)
+			return
+		}
+		writeJSON(w, 200, toEventResp(ev))
+	})
+
+	// POST /events/{id}/approve  { "user": "U456" } → 承認待ちの報告を本人以外のメンバーが承認する
+	r.Post("/events/{id}/approve", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := eventIDParam(w, r)
+		if !ok {
+			return
+		}
+		var in struct {
+			User string `json:"user"`
+		}
+		if !decodeJSON(w, r, &in) {
+			return
+		}
+		ev, err := sv.ApproveEvent(r.Context(), id, repo.Actor{ExtUserID: strings.TrimSpace(in.User), Source: repo.SourceHTTP})
+		if err != nil {
+			writeEventErr(w, id, err)
+			return
+		}
+		if _, err := sv.CheckAchievements(r.Context(), ev.GroupID, ev.UserID); err != nil {
+			log.Printf("achievements error: group=%s user=%s err=%v", ev.GroupID, ev.UserID, err)
+		}
+		writeJSON(w, 200, toEventResp(ev))
+	})
+
+	// GET /houses/{group}/events/pending → 承認待ちの報告（古い順）
+	r.Get("/houses/{group}/events/pending", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		events, err := sv.PendingEvents(r.Context(), group, "")
+		if err != nil {
+			log.Printf("pending events error: group=%s err=%v", group, err)
+			writeErr(w, 500, "internal error")
+			return
+		}
+		out := make([]eventResp, 0, len(events))
+		for _, ev := range events {
+			out = append(out, toEventResp(ev))
+		}
+		writeJSON(w, 200, map[string]any{"events": out})
+	})
+
+	// GET /events/{id}/audit → 作成・修正・取り消し・復元・承認の履歴
+	r.Get("/events/{id}/audit", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := eventIDParam(w, r)
+		if !ok {
+			return
+		}
+		entries, err := sv.EventAudit(r.Context(), id)
+		if err != nil {
+			writeEventErr(w, id, err)
+			return
+		}
+		out := make([]auditResp, 0, len(entries))
+		for _, a := range entries {
+			out = append(out, auditResp{
+				Action:    a.Action,
+				Actor:     a.Actor,
+				Source:    a.Source,
+				Task:      a.TaskKey,
+				Option:    a.Option,
+				Points:    a.Points,
+				CreatedAt: a.CreatedAt,
+			})
+		}
+		writeJSON(w, 200, map[string]any{"audit": out})
+	})
+}
+
+// parseEventSeq "3" / "#3" / "３" をハウス内の通し番号として読む
+func parseEventSeq(s string) (int64, bool) {
+	s = strings.TrimLeft(width.Narrow.String(strings.TrimSpace(s)), "#")
+	seq, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || seq <= 0 {
+		return 0, false
+	}
+	return seq, true
+}
+
+// formatEventEntry LINE返信用のイベント表記（例: #12 11/3 散歩 30分 50pt）
+func formatEventEntry(ev service.Event) string {
+	task := ev.TaskKey
+	if ev.Option != nil {
+		task += " " + *ev.Option
+	}
+	entry := fmt.Sprintf("#%d %s %s %s", ev.Seq, ev.PerformedAt.Format("1/2"), task, formatPoints(ev.Points))
+	if ev.Pending {
+		entry += "（承認待ち）"
+	}
+	return entry
+}
+
+// lineHistoryReply "@bot 履歴" の返信文
+func lineHistoryReply(ctx context.Context, sv *service.Service, groupID, userID string) string {
+	events, err := sv.RecentEvents(ctx, groupID, userID, lineHistoryLimit)
+	if err != nil {
+		log.Printf("LINE history error: group=%s user=%s err=%v", groupID, userID, err)
+		noteLineFailure(ctx, err)
+		return "取得失敗: 少し待ってから試してね"
+	}
+	if len(events) == 0 {
+		return "まだ記録がないよ。"
+	}
+	lines := make([]string, 0, len(events)+2)
+	lines = append(lines, "最近の記録:")
+	for _, ev := range events {
+		lines = append(lines, formatEventEntry(ev))
+	}
+	lines = append(lines, "「@bot 取消 番号」「@bot 修正 番号 タスク」で直せるよ。")
+	return strings.Join(lines, "\n")
+}
+
+// lineEventErrReply 番号指定の取消/修正が失敗したときの返信文
+func lineEventErrReply(ctx context.Context, groupID, userID, arg string, err error) string {
+	var amb *service.TaskAmbiguousError
+	var optErr *service.OptionError
+	switch {
+	case errors.Is(err, repo.ErrNoEventFound):
+		return fmt.Sprintf("#%s の記録が見つからないよ。", strings.TrimLeft(arg, "#"))
+	case errors.Is(err, service.ErrNotEventOwner):
+		return "権限なし: 他の人の記録は本人かハウス管理者だけが直せるよ。"
+	case errors.Is(err, service.ErrTaskNotFound):
+		return "不明なタスクだよ。@bot task で一覧を確認してね"
+	case errors.As(err, &amb):
+		return fmt.Sprintf("候補が複数あるよ: %s", strings.Join(amb.Candidates, "/"))
+	case errors.As(err, &optErr):
+		return fmt.Sprintf("入力エラー: \"%s\" は読み取れなかったよ（例: 15分, 1時間, x2, 2回）", optErr.Input)
+	}
+	log.Printf("LINE event command error: group=%s user=%s arg=%s err=%v", groupID, userID, arg, err)
+	noteLineFailure(ctx, err)
+	return "失敗: 少し待ってから試してね"
+}
+
+// lineCancelEventReply "@bot 取消 3" 番号を指定して取り消す
+func lineCancelEventReply(ctx context.Context, sv *service.Service, groupID, userID, arg string) string {
+	seq, ok := parseEventSeq(arg)
+	if !ok {
+		return "使い方: @bot 取消 3（番号は @bot 履歴 で確認できるよ）"
+	}
+	ev, err := sv.MemberEvent(ctx, groupID, userID, seq)
+	if err == nil {
+		_, err = sv.CancelEvent(ctx, ev.ID, repo.Actor{ExtUserID: userID, Source: repo.SourceLINE})
+	}
+	if err != nil {
+		return lineEventErrReply(ctx, groupID, userID, arg, err)
+	}
+	return fmt.Sprintf("取り消したよ: %s\n間違えたときは @bot 復元 で戻せるよ。", formatEventEntry(ev))
+}
+
+// lineRestoreReply "@bot 復元" 自分で最後に取り消した記録を戻す。
+// "@bot 復元 3" は番号を指定して誰の記録でも戻す（ハウス管理者のみ）
+func lineRestoreReply(ctx context.Context, sv *service.Service, groupID, userID string, args []string) string {
+	var ev service.Event
+	var err error
+	if len(args) > 0 {
+		seq, ok := parseEventSeq(args[0])
+		if !ok {
+			return "使い方: @bot 復元 / @bot 復元 3（番号指定は管理者だけ）"
+		}
+		ev, err = sv.RestoreEvent(ctx, groupID, userID, seq, repo.SourceLINE)
+	} else {
+		ev, err = sv.RestoreLastCancelled(ctx, groupID, userID, repo.SourceLINE)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, repo.ErrNoEventFound):
+			return "復元できる記録がないよ。"
+		case errors.Is(err, repo.ErrCancelledByOther):
+			return "最後の記録はほかの人が取り消したので、ハウス管理者に @bot 復元 番号 で戻してもらってね。"
+		case errors.Is(err, service.ErrNotHouseAdmin):
+			return "権限なし: 番号を指定した復元はハウス管理者だけができるよ。"
+		}
+		log.Printf("LINE restore error: group=%s user=%s err=%v", groupID, userID, err)
+		noteLineFailure(ctx, err)
+		return "復元失敗: 少し待ってから試してね"
+	}
+	return fmt.Sprintf("復元したよ: %s", formatEventEntry(ev))
+}
+
+// lineEditEventReply "@bot 修正 3 ゴミ出し [オプション]" 番号を指定してタスクを付け替える
+func lineEditEventReply(ctx context.Context, sv *service.Service, groupID, userID string, args []string) string {
+	const usage = "使い方: @bot 修正 3 ゴミ出し（番号は @bot 履歴 で確認できるよ）"
+	if len(args) < 2 {
+		return usage
+	}
+	seq, ok := parseEventSeq(args[0])
+	if !ok {
+		return usage
+	}
+	items := parseReportItems(args[1:])
+	if len(items) != 1 {
+		return usage
+	}
+
+	before, err := sv.MemberEvent(ctx, groupID, userID, seq)
+	var after service.Event
+	if err == nil {
+		after, err = sv.EditEvent(ctx, before.ID, service.EventPatch{Task: &items[0].Task, Option: items[0].Option},
+			repo.Actor{ExtUserID: userID, Source: repo.SourceLINE})
+	}
+	if err != nil {
+		return lineEventErrReply(ctx, groupID, userID, args[0], err)
+	}
+	return fmt.Sprintf("修正したよ: %s\n（修正前: %s %s）", formatEventEntry(after), before.TaskKey, formatPoints(before.Points))
+}
+
+// lineApproveReply "@bot 承認 [番号…]" 番号を省略するとほかのメンバーの承認待ちをすべて承認する
+func lineApproveReply(ctx context.Context, sv *service.Service, groupID, userID string, args []string) string {
+	seqs := make([]int64, 0, len(args))
+	for _, arg := range args {
+		seq, ok := parseEventSeq(arg)
+		if !ok {
+			return "使い方: @bot 承認 / @bot 承認 3（番号を省略すると承認待ちをすべて承認）"
+		}
+		seqs = append(seqs, seq)
+	}
+	if len(seqs) == 0 {
+		pending, err := sv.PendingEvents(ctx, groupID, userID)
+		if err != nil {
+			log.Printf("LINE pending events error: group=%s user=%s err=%v", groupID, userID, err)
+			noteLineFailure(ctx, err)
+			return "取得失敗: 少し待ってから試してね"
+		}
+		if len(pending) == 0 {
+			return "承認待ちの報告はないよ。"
+		}
+		for _, ev := range pending {
+			seqs = append(seqs, ev.Seq)
+		}
+	}
+	return lineApproveEventsReply(ctx, sv, groupID, userID, seqs)
+}
+
+// lineApproveEventsReply 通し番号の報告を順に承認し、結果の返信文を返す
+func lineApproveEventsReply(ctx context.Context, sv *service.Service, groupID, userID string, seqs []int64) string {
+	actor := repo.Actor{ExtUserID: userID, Source: repo.SourceLINE}
+	var approved, failed, notices []string
+	for _, seq := range seqs {
+		ev, err := sv.ApproveEventBySeq(ctx, groupID, seq, actor)
+		switch {
+		case err == nil:
+			approved = append(approved, fmt.Sprintf("%s（%s）", formatEventEntry(ev), ev.UserName))
+			// 承認で集計に入ったので、報告した人のバッジをあらためて判定する
+			achievements, err := sv.CheckAchievements(ctx, groupID, ev.UserID)
+			if err != nil {
+				log.Printf("LINE achievements error: group=%s user=%s err=%v", groupID, ev.UserID, err)
+			} else if len(achievements) > 0 {
+				notices = append(notices, ev.UserName+" "+formatAchievementNotice(achievements))
+			}
+		case errors.Is(err, service.ErrSelfApproval):
+			failed = append(failed, fmt.Sprintf("#%d は自分の報告なので承認できないよ。ほかのメンバーに頼んでね", seq))
+		case errors.Is(err, repo.ErrNotPending):
+			failed = append(failed, fmt.Sprintf("#%d は承認済みだよ", seq))
+		case errors.Is(err, repo.ErrNoEventFound):
+			failed = append(failed, fmt.Sprintf("#%d の記録が見つからないよ", seq))
+		case errors.Is(err, repo.ErrNoMemberFound):
+			failed = append(failed, "ハウスのメンバーだけが承認できるよ")
+		default:
+			log.Printf("LINE approve error: group=%s user=%s seq=%d err=%v", groupID, userID, seq, err)
+			if len(approved) == 0 {
+				// 一部を承認済みなら再試行せず、承認できた分と失敗した分をそのまま返信する
+				noteLineFailure(ctx, err)
+			}
+			failed = append(failed, fmt.Sprintf("#%d の承認に失敗: 少し待ってから試してね", seq))
+		}
+	}
+	lines := make([]string, 0, len(approved)+len(failed)+1)
+	if len(approved) > 0 {
+		lines = append(lines, "承認したよ:")
+		lines = append(lines, approved...)
+	}
+	lines = append(lines, failed...)
+	return strings.Join(append(lines, notices...), "\n")
+}
@@ -0,0 +1,81 @@
+package httpapi
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"chores_contributor/internal/service"
+)
+
+// RunScheduledJobs ctxが終わるまで interval ごとに定期のプッシュ（週次まとめ・期限切れ通知）を確認する。
+// 送信済みかどうかはDBで管理するので、複数インスタンスで動かしてもよい。
+// 送った数は返信の送り直しと同じ月の予算に送り先の人数で数える（定期のプッシュは予算を超えても送る）
+func RunScheduledJobs(ctx context.Context, sv *service.Service, lc LineClient, interval time.Duration) {
+	pushRecap := func(ctx context.Context, recap service.WeeklyRecap, retryKey string) error {
+		sent, err := pushWeeklyRecap(ctx, lc, recap, retryKey)
+		if err != nil {
+			return err
+		}
+		if sent {
+			recordLinePush(ctx, sv, lc, recap.GroupID)
+		}
+		return nil
+	}
+	pushReminder := func(ctx context.Context, reminder service.OverdueReminder, retryKey string) error {
+		sent, err := pushOverdueReminder(ctx, lc, reminder, retryKey)
+		if err != nil {
+			return err
+		}
+		if sent {
+			recordLinePush(ctx, sv, lc, reminder.GroupID)
+		}
+		return nil
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		if err := sv.SendWeeklyRecaps(ctx, now, pushRecap); err != nil {
+			log.Printf("weekly recap error: %v", err)
+		}
+		if err := sv.SendOverdueReminders(ctx, now, pushReminder); err != nil {
+			log.Printf("overdue reminder error: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recordLinePush 定期のプッシュ1回分を送り先の人数だけ今月の送信数に足す（失敗しても送信はやり直さない）。
+// 人数が取れなければ1人として数える
+func recordLinePush(ctx context.Context, sv *service.Service, lc LineClient, to string) {
+	n, err := lc.Recipients(ctx, to)
+	if err != nil {
+		log.Printf("LINE push recipients error: to=%s err=%v", to, err)
+		n = 1
+	}
+	if err := sv.Rp().RecordLinePushes(ctx, n); err != nil {
+		log.Printf("LINE push usage record error: %v", err)
+	}
+}
+
+// RunHouseJobs ctxが終わるまで interval ごとに、LINEに送らないハウスの定期処理（週間チャンピオンの記録）を行う。
+// LINEのトークンがない環境でも動かす
+func RunHouseJobs(ctx context.Context, sv *service.Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := sv.AwardWeeklyChampions(ctx, time.Now()); err != nil {
+			log.Printf("weekly champion error: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// lineAPIBase 本番のLINE Messaging API
+const lineAPIBase = "https://api.line.me/v2/bot"
+
+// LineClient LINE Messaging APIのうち、このサービスが使う操作。
+// クイックリプライはメッセージの QuickReply で付ける
+type LineClient interface {
+	// Reply リプライトークンで返信する（メッセージがなければ何もしない）
+	Reply(ctx context.Context, replyToken string, msgs ...lineReplyMessage) error
+	// Push グループ/ルーム/ユーザーにプッシュ送信する。同じretryKeyの再送はLINE側で
+	// 受け付け済み（409）になるので成功として扱い、sent=false を返す（今回は送っていない）
+	Push(ctx context.Context, to, retryKey string, msgs ...lineReplyMessage) (sent bool, err error)
+	// Recipients プッシュ先の人数（LINEはプッシュ1回を受け取った人数で数える）。
+	// グループ/ルームはボットを除くメンバー数、ユーザーは1
+	Recipients(ctx context.Context, to string) (int, error)
+	// Profile 送信者の表示名（空ならnil）。グループ/ルームではメンバーのプロフィールを使う
+	Profile(ctx context.Context, src lineSource) (*string, error)
+	// GroupSummary グループ名などの概要
+	GroupSummary(ctx context.Context, groupID string) (LineGroupSummary, error)
+}
+
+// LineGroupSummary グループの概要
+type LineGroupSummary struct {
+	GroupID    string `json:"groupId"`
+	GroupName  string `json:"groupName"`
+	PictureURL string `json:"pictureUrl"`
+}
+
+type lineReplyRequest struct {
+	ReplyToken string             `json:"replyToken"`
+	Messages   []lineReplyMessage `json:"messages"`
+}
+
+type linePushRequest struct {
+	To       string             `json:"to"`
+	Messages []lineReplyMessage `json:"messages"`
+}
+
+type lineProfileResponse struct {
+	DisplayName string `json:"displayName"`
+}
+
+type lineMemberCountResponse struct {
+	Count int `json:"count"`
+}
+
+// lineAPIError LINE APIが2xx以外を返した
+type lineAPIError struct {
+	Op     string
+	Status int
+	Body   string
+}
+
+func (e *lineAPIError) Error() string {
+	return fmt.Sprintf("line %s failed: status=%d body=%s", e.Op, e.Status, e.Body)
+}
+
+// lineAPIClient HTTPでLINE APIを呼ぶ LineClient
+type lineAPIClient struct {
+	base  string
+	token string
+	hc    *http.Client
+}
+
+// NewLineClient tokenで認証するクライアントを作る。
+// baseURLが空なら本番のAPI、hcがnilなら http.DefaultClient を使う
+func NewLineClient(baseURL, token string, hc *http.Client) LineClient {
+	if baseURL == "" {
+		baseURL = lineAPIBase
+	}
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &lineAPIClient{base: strings.TrimRight(baseURL, "/"), token: token, hc: hc}
+}
+
+func (c *lineAPIClient) Reply(ctx context.Context, replyToken string, msgs ...lineReplyMessage) error {
+	if replyToken == "" {
+		return errors.New("empty reply token")
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return c.post(ctx, "reply", "/message/reply", "", lineReplyRequest{ReplyToken: replyToken, Messages: msgs})
+}
+
+func (c *lineAPIClient) Push(ctx context.Context, to, retryKey string, msgs ...lineReplyMessage) (bool, error) {
+	if to == "" {
+		return false, errors.New("empty push destination")
+	}
+	if len(msgs) == 0 {
+		return false, nil
+	}
+	err := c.post(ctx, "push", "/message/push", retryKey, linePushRequest{To: to, Messages: msgs})
+	var apiErr *lineAPIError
+	if retryKey != "" && errors.As(err, &apiErr) && apiErr.Status == http.StatusConflict {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Recipients 送り先のIDの先頭（C: グループ、R: ルーム、U: ユーザー）で人数の数え方を決める
+func (c *lineAPIClient) Recipients(ctx context.Context, to string) (int, error) {
+	var path string
+	switch {
+	case to == "":
+		return 0, errors.New("empty push destination")
+	case strings.HasPrefix(to, "C"):
+		path = "/group/" + url.PathEscape(to) + "/members/count"
+	case strings.HasPrefix(to, "R"):
+		path = "/room/" + url.PathEscape(to) + "/members/count"
+	default:
+		return 1, nil
+	}
+	var resp lineMemberCountResponse
+	if err := c.get(ctx, "member count", path, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Count, nil
+}
+
+func (c *lineAPIClient) Profile(ctx context.Context, src lineSource) (*string, error) {
+	if src.UserID == "" {
+		return nil, errors.New("line source user id is empty")
+	}
+
+	var path string
+	switch {
+	case src.GroupID != "":
+		path = fmt.Sprintf("/group/%s/member/%s", url.PathEscape(src.GroupID), url.PathEscape(src.UserID))
+	case src.RoomID != "":
+		path = fmt.Sprintf("/room/%s/member/%s", url.PathEscape(src.RoomID), url.PathEscape(src.UserID))
+	default:
+		path = fmt.Sprintf("/profile/%s", url.PathEscape(src.UserID))
+	}
+
+	var profile lineProfileResponse
+	if err := c.get(ctx, "profile", path, &profile); err != nil {
+		var apiErr *lineAPIError
+		if errors.As(err, &apiErr) && (apiErr.Status == http.StatusNotFound || apiErr.Status == http.StatusForbidden) {
+			return nil, fmt.Errorf("line profile not accessible: status=%d", apiErr.Status)
+		}
+		return nil, err
+	}
+
+	name := strings.TrimSpace(profile.DisplayName)
+	if name == "" {
+		return nil, nil
+	}
+	return &name, nil
+}
+
+func (c *lineAPIClient) GroupSummary(ctx context.Context, groupID string) (LineGroupSummary, error) {
+	if groupID == "" {
+		return LineGroupSummary{}, errors.New("empty group id")
+	}
+	var summary LineGroupSummary
+	if err := c.get(ctx, "group summary", "/group/"+url.PathEscape(groupID)+"/summary", &summary); err != nil {
+		return LineGroupSummary{}, err
+	}
+	return summary, nil
+}
+
+// post メッセージ送信APIを呼ぶ（retryKeyが空でなければ X-Line-Retry-Key を付ける）
+func (c *lineAPIClient) post(ctx context.Context, op, path, retryKey string, body any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := c.newRequest(ctx, http.MethodPost, path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if retryKey != "" {
+		req.Header.Set("X-Line-Retry-Key", retryKey)
+	}
+	return c.do(op, req, nil)
+}
+
+// get 取得APIを呼び、レスポンスをoutに読み込む
+func (c *lineAPIClient) get(ctx context.Context, op, path string, out any) error {
+	req, err := c.newRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	return c.do(op, req, out)
+}
+
+func (c *lineAPIClient) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	if c.token == "" {
+		return nil, errors.New("LINE_CHANNEL_ACCESS_TOKEN not set")
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	return req, nil
+}
+
+func (c *lineAPIClient) do(op string, req *http.Request, out any) error {
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return &lineAPIError{Op: op, Status: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
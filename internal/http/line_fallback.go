@@ -0,0 +1,100 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"chores_contributor/internal/repo"
+	"chores_contributor/internal/service"
+)
+
+// lineReplyDeadline 受信からこれだけ経ったらリプライトークンは切れたとみなして最初からプッシュする
+// （トークンの有効期限は約1分。処理の遅れやキューの再試行で過ぎることがある）
+const lineReplyDeadline = 50 * time.Second
+
+// errPushBudgetExceeded 今月のプッシュ予算を使い切ったので送り直さない
+var errPushBudgetExceeded = errors.New("line push budget exceeded")
+
+// replyFallbackClient 返信できないとき（トークン切れ・期限超過）に、
+// 送り元のグループ/ルーム/ユーザーへのプッシュで送り直す LineClient
+type replyFallbackClient struct {
+	LineClient
+	rp       *repo.Repo
+	budget   int
+	to       string
+	eventID  string
+	deadline time.Time
+	pushes   int
+}
+
+// withReplyFallback イベント1件の処理に使うクライアント（budgetが0以下なら送り直さない）
+func withReplyFallback(lc LineClient, rp *repo.Repo, budget int, e lineEvent) LineClient {
+	if budget <= 0 {
+		return lc
+	}
+	c := &replyFallbackClient{LineClient: lc, rp: rp, budget: budget, to: lineGroupID(e.Source), eventID: e.WebhookEventID}
+	if e.Timestamp > 0 {
+		c.deadline = time.UnixMilli(e.Timestamp).Add(lineReplyDeadline)
+	}
+	return c
+}
+
+func (c *replyFallbackClient) Reply(ctx context.Context, replyToken string, msgs ...lineReplyMessage) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	if c.deadline.IsZero() || time.Now().Before(c.deadline) {
+		err := c.LineClient.Reply(ctx, replyToken, msgs...)
+		if err == nil || !isInvalidReplyToken(err) {
+			return err
+		}
+		log.Printf("LINE reply token rejected, falling back to push: to=%s err=%v", c.to, err)
+	} else {
+		log.Printf("LINE reply token expired, falling back to push: to=%s received=%s", c.to, c.deadline.Add(-lineReplyDeadline).Format(time.RFC3339))
+	}
+	return c.push(ctx, msgs)
+}
+
+// push 予算の範囲で送り直す。予算はLINEの数え方に合わせて送り先の人数で使う（吹き出しの数ではない）。
+// 同じイベントの再試行では同じretryKeyになるので二重に届かず、受け付け済み（409）なら予約を戻す
+func (c *replyFallbackClient) push(ctx context.Context, msgs []lineReplyMessage) error {
+	if c.to == "" {
+		return errors.New("no push destination for reply fallback")
+	}
+	n, err := c.LineClient.Recipients(ctx, c.to)
+	if err != nil {
+		return err
+	}
+	ok, err := c.rp.ReserveLinePushes(ctx, n, c.budget)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		log.Printf("LINE push budget exhausted: budget=%d to=%s recipients=%d", c.budget, c.to, n)
+		return errPushBudgetExceeded
+	}
+	c.pushes++
+	retryKey := ""
+	if c.eventID != "" {
+		retryKey = service.RetryKeyFor("reply-fallback", c.eventID, strconv.Itoa(c.pushes))
+	}
+	sent, err := c.LineClient.Push(ctx, c.to, retryKey, msgs...)
+	if err != nil || !sent {
+		if relErr := c.rp.RecordLinePushes(ctx, -n); relErr != nil {
+			log.Printf("LINE push usage release error: %v", relErr)
+		}
+	}
+	return err
+}
+
+// isInvalidReplyToken 使用済み・期限切れのリプライトークンでLINEが返信を断った
+func isInvalidReplyToken(err error) bool {
+	var apiErr *lineAPIError
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusBadRequest &&
+		strings.Contains(strings.ToLower(apiErr.Body), "invalid reply token")
+}
@@ -0,0 +1,234 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+	"unicode/utf8"
+
+	"chores_contributor/internal/repo"
+	"chores_contributor/internal/service"
+)
+
+const (
+	postbackActionReport  = "report"
+	postbackActionApprove = "approve"
+
+	// LINE Messaging API の上限
+	maxPostbackDataLen     = 300
+	maxQuickReplyItems     = 13
+	maxQuickReplyLabel     = 20
+	maxPostbackDisplayText = 300
+)
+
+// reportPostback 曖昧なタスクの候補ボタンに載せる元の報告内容
+type reportPostback struct {
+	Action string               `json:"a"`
+	MsgID  string               `json:"m"`
+	UserID string               `json:"u"`
+	Items  []reportPostbackItem `json:"i"`
+	// PerformedAt さかのぼり報告の実施日時（unix秒、0なら報告時刻）
+	PerformedAt int64 `json:"d,omitempty"`
+}
+
+type reportPostbackItem struct {
+	Task   string `json:"t"`
+	Option string `json:"o,omitempty"`
+}
+
+func encodeReportPostback(msgID, userID string, performedAt *time.Time, items []service.ReportItem) (string, bool) {
+	data := reportPostback{Action: postbackActionReport, MsgID: msgID, UserID: userID}
+	if performedAt != nil {
+		data.PerformedAt = performedAt.Unix()
+	}
+	for _, item := range items {
+		pi := reportPostbackItem{Task: item.Task}
+		if item.Option != nil {
+			pi.Option = *item.Option
+		}
+		data.Items = append(data.Items, pi)
+	}
+	b, err := json.Marshal(data)
+	if err != nil || utf8.RuneCount(b) > maxPostbackDataLen {
+		return "", false
+	}
+	return string(b), true
+}
+
+func decodeReportPostback(raw string) (reportPostback, []service.ReportItem, bool) {
+	var data reportPostback
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		return reportPostback{}, nil, false
+	}
+	if data.Action != postbackActionReport || data.MsgID == "" || len(data.Items) == 0 {
+		return reportPostback{}, nil, false
+	}
+	items := make([]service.ReportItem, 0, len(data.Items))
+	for _, pi := range data.Items {
+		item := service.ReportItem{Task: pi.Task}
+		if pi.Option != "" {
+			opt := pi.Option
+			item.Option = &opt
+		}
+		items = append(items, item)
+	}
+	return data, items, true
+}
+
+// approvePostback 承認待ちの報告を承認するボタンに載せる通し番号
+type approvePostback struct {
+	Action string  `json:"a"`
+	Seqs   []int64 `json:"s"`
+}
+
+func encodeApprovePostback(seqs []int64) (string, bool) {
+	b, err := json.Marshal(approvePostback{Action: postbackActionApprove, Seqs: seqs})
+	if err != nil || utf8.RuneCount(b) > maxPostbackDataLen {
+		return "", false
+	}
+	return string(b), true
+}
+
+func decodeApprovePostback(raw string) ([]int64, bool) {
+	var data approvePostback
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		return nil, false
+	}
+	if data.Action != postbackActionApprove || len(data.Seqs) == 0 {
+		return nil, false
+	}
+	return data.Seqs, true
+}
+
+// postbackAction postbackデータの種類（"a"）を読む
+func postbackAction(raw string) string {
+	var data struct {
+		Action string `json:"a"`
+	}
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		return ""
+	}
+	return data.Action
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+// ambiguityQuickReply 候補ごとに「元の報告をこのタスクで記録する」ボタンを作る。
+// index番目の報告を候補に置き換えた内容をpostbackに載せ、元のメッセージIDで冪等化する
+func ambiguityQuickReply(msgID, userID string, performedAt *time.Time, items []service.ReportItem, index int, candidates []string) (*lineQuickReply, bool) {
+	if index < 0 || index >= len(items) || len(candidates) == 0 {
+		return nil, false
+	}
+	qr := &lineQuickReply{}
+	for _, candidate := range candidates {
+		if len(qr.Items) == maxQuickReplyItems {
+			break
+		}
+		replaced := make([]service.ReportItem, len(items))
+		copy(replaced, items)
+		replaced[index].Task = candidate
+
+		data, ok := encodeReportPostback(msgID, userID, performedAt, replaced)
+		if !ok {
+			return nil, false
+		}
+		qr.Items = append(qr.Items, lineQuickReplyItem{
+			Type: "action",
+			Action: lineAction{
+				Type:        "postback",
+				Label:       truncateRunes(candidate, maxQuickReplyLabel),
+				Data:        data,
+				DisplayText: truncateRunes(candidate, maxPostbackDisplayText),
+			},
+		})
+	}
+	return qr, true
+}
+
+// approvalQuickReply 承認待ちになった報告をほかのメンバーが承認するボタンを作る
+func approvalQuickReply(results []service.ReportResult) (*lineQuickReply, bool) {
+	var seqs []int64
+	for _, r := range results {
+		if r.Pending {
+			seqs = append(seqs, r.Seq)
+		}
+	}
+	if len(seqs) == 0 {
+		return nil, false
+	}
+	data, ok := encodeApprovePostback(seqs)
+	if !ok {
+		return nil, false
+	}
+	return &lineQuickReply{Items: []lineQuickReplyItem{{
+		Type: "action",
+		Action: lineAction{
+			Type:        "postback",
+			Label:       "承認する",
+			Data:        data,
+			DisplayText: "承認",
+		},
+	}}}, true
+}
+
+// handleLinePostback クイックリプライのボタン（タスク候補の選択・報告の承認）を処理する
+func handleLinePostback(ctx context.Context, sv *service.Service, lc LineClient, e lineEvent) error {
+	if postbackAction(e.Postback.Data) == postbackActionApprove {
+		return handleApprovePostback(ctx, sv, lc, e)
+	}
+	data, items, ok := decodeReportPostback(e.Postback.Data)
+	if !ok {
+		log.Printf("LINE postback ignored: event_id=%s data=%q", e.WebhookEventID, e.Postback.Data)
+		return nil
+	}
+	if data.UserID != e.Source.UserID {
+		if err := sendLineReply(ctx, lc, e.ReplyToken, "報告した本人だけが選べるよ。"); err != nil {
+			log.Printf("LINE reply error (postback other user): %v", err)
+		}
+		return nil
+	}
+
+	msgID := data.MsgID
+	payload := service.ReportPayload{
+		GroupID:     lineGroupID(e.Source),
+		UserID:      e.Source.UserID,
+		SourceMsgID: &msgID,
+		Source:      repo.SourceLINE,
+	}
+	if data.PerformedAt != 0 {
+		performedAt := time.Unix(data.PerformedAt, 0)
+		payload.PerformedAt = &performedAt
+	}
+	return submitLineReport(ctx, sv, lc, e, payload, items)
+}
+
+// handleApprovePostback 「承認する」ボタンを押したメンバーとして承認待ちの報告を承認する
+func handleApprovePostback(ctx context.Context, sv *service.Service, lc LineClient, e lineEvent) error {
+	seqs, ok := decodeApprovePostback(e.Postback.Data)
+	if !ok {
+		log.Printf("LINE postback ignored: event_id=%s data=%q", e.WebhookEventID, e.Postback.Data)
+		return nil
+	}
+	groupID := lineGroupID(e.Source)
+	// ボタンを押しただけのメンバーもハウスに登録してから承認する
+	displayName, err := lc.Profile(ctx, e.Source)
+	if err != nil {
+		log.Printf("LINE profile fetch failed: group=%s user=%s err=%v", groupID, e.Source.UserID, err)
+	}
+	if err := sv.Rp().UpsertHouseUser(ctx, repo.UpsertHouseUserParams{
+		ExtGroupID:  groupID,
+		ExtUserID:   e.Source.UserID,
+		DisplayName: displayName,
+	}); err != nil {
+		log.Printf("LINE user upsert failed: group=%s user=%s err=%v", groupID, e.Source.UserID, err)
+	}
+	msg := lineApproveEventsReply(ctx, sv, groupID, e.Source.UserID, seqs)
+	return replyLineCommand(ctx, lc, e.ReplyToken, "approve postback", lineTextMessages(msg)...)
+}
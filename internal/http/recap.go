@@ -0,0 +1,41 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"chores_contributor/internal/service"
+)
+
+func pushWeeklyRecap(ctx context.Context, lc LineClient, recap service.WeeklyRecap, retryKey string) (bool, error) {
+	return lc.Push(ctx, recap.GroupID, retryKey, lineTextMessage(formatWeeklyRecap(recap)))
+}
+
+// formatWeeklyRecap 週次まとめのプッシュ本文（最終順位・各自のいちばんのタスク・先週比）
+func formatWeeklyRecap(recap service.WeeklyRecap) string {
+	lines := make([]string, 0, len(recap.Members)*2+3)
+	lines = append(lines, fmt.Sprintf("先週のまとめ（%s〜%s）", recap.Start.Format("1/2"), recap.End.Format("1/2")))
+	for _, m := range recap.Members {
+		lines = append(lines, fmt.Sprintf("%d位 %s %s（先週比 %s）", m.Rank, m.Name, formatPoints(m.Points), formatPointsDiff(m.Points-m.PrevPoints)))
+		if m.TopTask != "" {
+			lines = append(lines, fmt.Sprintf("　いちばん: %s %s", m.TopTask, formatPoints(m.TopTaskPoints)))
+		}
+	}
+	lines = append(lines, fmt.Sprintf("合計 %s（先週比 %s）", formatPoints(recap.Total), formatPointsDiff(recap.Total-recap.PrevTotal)))
+	lines = append(lines, "今週もよろしくね！")
+	return strings.Join(lines, "\n")
+}
+
+// formatPointsDiff 増減を符号つきで表す（例: +300pt, -50pt, ±0pt）
+func formatPointsDiff(d float64) string {
+	switch {
+	case math.Abs(d) < 0.05:
+		return "±0pt"
+	case d > 0:
+		return "+" + formatPoints(d)
+	default:
+		return "-" + formatPoints(-d)
+	}
+}
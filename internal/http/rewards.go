@@ -0,0 +1,341 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"chores_contributor/internal/repo"
+	"chores_contributor/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type rewardResp struct {
+	ID       int64   `json:"id"`
+	Name     string  `json:"name"`
+	Cost     float64 `json:"cost"`
+	Archived bool    `json:"archived"`
+}
+
+func toRewardResp(rw service.Reward) rewardResp {
+	return rewardResp{ID: rw.ID, Name: rw.Name, Cost: rw.Cost, Archived: rw.Archived}
+}
+
+type walletResp struct {
+	UserID   string  `json:"user_id"`
+	Earned   float64 `json:"earned"`
+	Spent    float64 `json:"spent"`
+	Reserved float64 `json:"reserved"`
+	Balance  float64 `json:"balance"`
+}
+
+type redemptionResp struct {
+	ID            int64      `json:"id"`
+	RewardID      int64      `json:"reward_id"`
+	Reward        string     `json:"reward"`
+	Cost          float64    `json:"cost"`
+	UserID        string     `json:"user_id"`
+	Name          string     `json:"name"`
+	Status        string     `json:"status"`
+	DecidedBy     string     `json:"decided_by,omitempty"`
+	DecidedByName string     `json:"decided_by_name,omitempty"`
+	RequestedAt   time.Time  `json:"requested_at"`
+	DecidedAt     *time.Time `json:"decided_at,omitempty"`
+}
+
+func toRedemptionResp(rd service.Redemption) redemptionResp {
+	return redemptionResp{
+		ID:            rd.ID,
+		RewardID:      rd.RewardID,
+		Reward:        rd.Reward,
+		Cost:          rd.Cost,
+		UserID:        rd.UserID,
+		Name:          rd.Name,
+		Status:        rd.Status,
+		DecidedBy:     rd.DecidedBy,
+		DecidedByName: rd.DecidedByName,
+		RequestedAt:   rd.RequestedAt,
+		DecidedAt:     rd.DecidedAt,
+	}
+}
+
+// writeRewardErr ごほうび・交換の操作のエラーをHTTPステータスに変換する
+func writeRewardErr(w http.ResponseWriter, group string, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidReward), errors.Is(err, service.ErrInvalidRedemption):
+		writeErr(w, 400, err.Error())
+	case errors.Is(err, service.ErrRewardNotFound):
+		writeErr(w, 400, "unknown reward")
+	case errors.Is(err, service.ErrSelfApproval):
+		writeErr(w, 403, "cannot approve own redemption")
+	case errors.Is(err, repo.ErrNoMemberFound):
+		writeErr(w, 404, "member not found")
+	case errors.Is(err, repo.ErrNoRewardFound):
+		writeErr(w, 404, "reward not found")
+	case errors.Is(err, repo.ErrNoRedemptionFound):
+		writeErr(w, 404, "redemption not found")
+	case errors.Is(err, repo.ErrRewardConflict):
+		writeErr(w, 409, "reward name is already used")
+	case errors.Is(err, repo.ErrInsufficientPoints):
+		writeErr(w, 409, "insufficient points")
+	case errors.Is(err, repo.ErrRedemptionDecided):
+		writeErr(w, 409, "redemption already decided")
+	default:
+		log.Printf("reward error: group=%s err=%v", group, err)
+		writeErr(w, 500, "internal error")
+	}
+}
+
+func idParam(w http.ResponseWriter, r *http.Request, what string) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeErr(w, 400, "invalid "+what+" id")
+		return 0, false
+	}
+	return id, true
+}
+
+// mountRewardRoutes ごほうびの一覧と交換（承認つき）のAPI
+func mountRewardRoutes(r chi.Router, sv *service.Service) {
+	// GET /houses/{group}/rewards?include_archived=true
+	r.Get("/houses/{group}/rewards", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		includeArchived, _ := strconv.ParseBool(r.URL.Query().Get("include_archived"))
+		rewards, err := sv.ListRewards(r.Context(), group, includeArchived)
+		if err != nil {
+			writeRewardErr(w, group, err)
+			return
+		}
+		out := make([]rewardResp, 0, len(rewards))
+		for _, rw := range rewards {
+			out = append(out, toRewardResp(rw))
+		}
+		writeJSON(w, 200, map[string]any{"rewards": out})
+	})
+
+	// POST /houses/{group}/rewards
+	// { "name": "マッサージ券", "cost": 2000 }
+	r.Post("/houses/{group}/rewards", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		var in service.RewardInput
+		if !decodeJSON(w, r, &in) {
+			return
+		}
+		rw, err := sv.CreateReward(r.Context(), group, in)
+		if err != nil {
+			writeRewardErr(w, group, err)
+			return
+		}
+		writeJSON(w, 201, toRewardResp(rw))
+	})
+
+	// PATCH /houses/{group}/rewards/{id}
+	// { "cost": 2500 } / { "archived": true }
+	r.Patch("/houses/{group}/rewards/{id}", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		id, ok := idParam(w, r, "reward")
+		if !ok {
+			return
+		}
+		var patch service.RewardPatch
+		if !decodeJSON(w, r, &patch) {
+			return
+		}
+		rw, err := sv.UpdateReward(r.Context(), group, id, patch)
+		if err != nil {
+			writeRewardErr(w, group, err)
+			return
+		}
+		writeJSON(w, 200, toRewardResp(rw))
+	})
+
+	// GET /houses/{group}/users/{user}/wallet → 使えるポイント
+	r.Get("/houses/{group}/users/{user}/wallet", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		wallet, err := sv.UserWallet(r.Context(), group, chi.URLParam(r, "user"))
+		if err != nil {
+			writeRewardErr(w, group, err)
+			return
+		}
+		writeJSON(w, 200, walletResp{
+			UserID:   wallet.UserID,
+			Earned:   wallet.Earned,
+			Spent:    wallet.Spent,
+			Reserved: wallet.Reserved,
+			Balance:  wallet.Balance,
+		})
+	})
+
+	// GET /houses/{group}/redemptions?status=pending&user=U123&limit=20
+	r.Get("/houses/{group}/redemptions", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		q := r.URL.Query()
+		limit := 0
+		if raw := q.Get("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n <= 0 {
+				writeErr(w, 400, "limit must be a positive integer")
+				return
+			}
+			limit = n
+		}
+		redemptions, err := sv.ListRedemptions(r.Context(), group, q.Get("status"), q.Get("user"), limit)
+		if err != nil {
+			writeRewardErr(w, group, err)
+			return
+		}
+		out := make([]redemptionResp, 0, len(redemptions))
+		for _, rd := range redemptions {
+			out = append(out, toRedemptionResp(rd))
+		}
+		writeJSON(w, 200, map[string]any{"redemptions": out})
+	})
+
+	// POST /houses/{group}/redemptions
+	// { "user": "U123", "reward": "マッサージ券" } → 申請中（ほかのメンバーの承認待ち）
+	r.Post("/houses/{group}/redemptions", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		var in service.RedemptionInput
+		if !decodeJSON(w, r, &in) {
+			return
+		}
+		rd, err := sv.RequestRedemption(r.Context(), group, in)
+		if err != nil {
+			writeRewardErr(w, group, err)
+			return
+		}
+		writeJSON(w, 201, toRedemptionResp(rd))
+	})
+
+	// POST /houses/{group}/redemptions/{id}/approve  { "user": "U456" }
+	// POST /houses/{group}/redemptions/{id}/reject   { "user": "U456" }
+	for action, approve := range map[string]bool{"approve": true, "reject": false} {
+		r.Post("/houses/{group}/redemptions/{id}/"+action, func(w http.ResponseWriter, r *http.Request) {
+			group := chi.URLParam(r, "group")
+			id, ok := idParam(w, r, "redemption")
+			if !ok {
+				return
+			}
+			var in struct {
+				User string `json:"user"`
+			}
+			if !decodeJSON(w, r, &in) {
+				return
+			}
+			rd, err := sv.DecideRedemption(r.Context(), group, id, in.User, approve)
+			if err != nil {
+				writeRewardErr(w, group, err)
+				return
+			}
+			writeJSON(w, 200, toRedemptionResp(rd))
+		})
+	}
+}
+
+// lineRewardsReply "@bot ごほうび" の返信文（ごほうび一覧・自分の使えるポイント・承認待ち）
+func lineRewardsReply(ctx context.Context, sv *service.Service, groupID, userID string) string {
+	rewards, err := sv.ListRewards(ctx, groupID, false)
+	if err != nil {
+		log.Printf("LINE rewards error: group=%s err=%v", groupID, err)
+		noteLineFailure(ctx, err)
+		return "取得失敗: 少し待ってから試してね"
+	}
+	var wallet *service.Wallet
+	if wl, err := sv.UserWallet(ctx, groupID, userID); err == nil {
+		wallet = &wl
+	} else if !errors.Is(err, repo.ErrNoMemberFound) {
+		log.Printf("LINE wallet error: group=%s user=%s err=%v", groupID, userID, err)
+	}
+	pending, err := sv.ListRedemptions(ctx, groupID, repo.RedemptionPending, "", 0)
+	if err != nil {
+		log.Printf("LINE redemptions error: group=%s err=%v", groupID, err)
+	}
+	return formatRewards(rewards, wallet, pending)
+}
+
+func formatRewards(rewards []service.Reward, wallet *service.Wallet, pending []service.Redemption) string {
+	if len(rewards) == 0 {
+		return "ごほうびはまだ登録されていないよ。"
+	}
+	lines := make([]string, 0, len(rewards)+len(pending)+4)
+	if wallet != nil {
+		lines = append(lines, fmt.Sprintf("ごほうび（使えるポイント %s）:", formatPoints(wallet.Balance)))
+	} else {
+		lines = append(lines, "ごほうび:")
+	}
+	for _, rw := range rewards {
+		lines = append(lines, fmt.Sprintf("・%s %s", rw.Name, formatPoints(rw.Cost)))
+	}
+	if len(pending) > 0 {
+		lines = append(lines, "承認待ち:")
+		for _, rd := range pending {
+			lines = append(lines, fmt.Sprintf("・#%d %s %s", rd.ID, rd.Name, rd.Reward))
+		}
+	}
+	lines = append(lines, "交換は「@bot 交換 名前」、承認は「@bot 交換承認 番号」で。")
+	return strings.Join(lines, "\n")
+}
+
+// lineRedeemReply "@bot 交換 マッサージ券" の返信文
+func lineRedeemReply(ctx context.Context, sv *service.Service, groupID, userID string, args []string) string {
+	name := strings.Join(args, " ")
+	if name == "" {
+		return "使い方: @bot 交換 マッサージ券"
+	}
+	rd, err := sv.RequestRedemption(ctx, groupID, service.RedemptionInput{User: userID, Reward: name})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrRewardNotFound):
+			return fmt.Sprintf("不明なごほうびだよ: \"%s\"（@bot ごほうび で一覧を確認してね）", name)
+		case errors.Is(err, repo.ErrInsufficientPoints):
+			return "ポイントが足りないよ。@bot ごほうび で使えるポイントを確認してね"
+		case errors.Is(err, repo.ErrNoMemberFound):
+			return "まずは家事を報告してね。"
+		}
+		log.Printf("LINE redeem error: group=%s user=%s reward=%s err=%v", groupID, userID, name, err)
+		noteLineFailure(ctx, err)
+		return "申請失敗: 少し待ってから試してね"
+	}
+	return fmt.Sprintf("交換を申請したよ: #%d %s %s\nほかのメンバーが「@bot 交換承認 %d」で承認してね。", rd.ID, rd.Reward, formatPoints(rd.Cost), rd.ID)
+}
+
+// lineDecideRedemptionReply "@bot 交換承認 12" / "@bot 交換却下 12" の返信文
+func lineDecideRedemptionReply(ctx context.Context, sv *service.Service, groupID, userID string, args []string, approve bool) string {
+	command := "交換却下"
+	if approve {
+		command = "交換承認"
+	}
+	if len(args) != 1 {
+		return fmt.Sprintf("使い方: @bot %s 12（番号は @bot ごほうび で確認）", command)
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+	if err != nil || id <= 0 {
+		return fmt.Sprintf("使い方: @bot %s 12（番号は @bot ごほうび で確認）", command)
+	}
+	rd, err := sv.DecideRedemption(ctx, groupID, id, userID, approve)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrSelfApproval):
+			return "自分の申請は承認できないよ。ほかのメンバーに頼んでね"
+		case errors.Is(err, repo.ErrNoRedemptionFound):
+			return fmt.Sprintf("#%d の申請は見つからないよ", id)
+		case errors.Is(err, repo.ErrRedemptionDecided):
+			return fmt.Sprintf("#%d はもう承認/却下されているよ", id)
+		case errors.Is(err, repo.ErrNoMemberFound):
+			return "まずは家事を報告してね。"
+		}
+		log.Printf("LINE redemption decide error: group=%s user=%s id=%d err=%v", groupID, userID, id, err)
+		noteLineFailure(ctx, err)
+		return "失敗: 少し待ってから試してね"
+	}
+	if approve {
+		return fmt.Sprintf("承認したよ: #%d %s さんの %s（%s）", rd.ID, rd.Name, rd.Reward, formatPoints(rd.Cost))
+	}
+	return fmt.Sprintf("却下したよ: #%d %s さんの %s（ポイントは戻ったよ）", rd.ID, rd.Name, rd.Reward)
+}
@@ -1,7 +1,6 @@
 package httpapi
 
 import (
-	"bytes"
 	"context"
 	"crypto/hmac"
 	"crypto/sha256"
@@ -15,7 +14,6 @@ import (
 	"log"
 	"math"
 	"net/http"
-	"net/url"
 	"os"
 	"sort"
 	"strings"
@@ -41,11 +39,17 @@ type lineEvent struct {
 	Type            string               `json:"type"`
 	ReplyToken      string               `json:"replyToken"`
 	Source          lineSource           `json:"source"`
+	Timestamp       int64                `json:"timestamp"` // 受信時刻（UNIXミリ秒）
 	Message         lineMessage          `json:"message"`
+	Postback        *linePostback        `json:"postback,omitempty"`
 	DeliveryContext *lineDeliveryContext `json:"deliveryContext,omitempty"`
 	WebhookEventID  string               `json:"webhookEventId"`
 }
 
+type linePostback struct {
+	Data string `json:"data"`
+}
+
 type lineDeliveryContext struct {
 	IsRedelivery bool `json:"isRedelivery"`
 }
@@ -75,69 +79,76 @@ type lineMention struct {
 	} `json:"mentionees"`
 }
 
+// lineReplyMessage 送るメッセージ。テキストはText、Flex MessageはAltTextとContentsを使う
 type lineReplyMessage struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type       string          `json:"type"`
+	Text       string          `json:"text,omitempty"`
+	AltText    string          `json:"altText,omitempty"`
+	Contents   any             `json:"contents,omitempty"`
+	QuickReply *lineQuickReply `json:"quickReply,omitempty"`
 }
 
-type lineReplyRequest struct {
-	ReplyToken string             `json:"replyToken"`
-	Messages   []lineReplyMessage `json:"messages"`
+type lineQuickReply struct {
+	Items []lineQuickReplyItem `json:"items"`
 }
 
-const lineReplyEndpoint = "https://api.line.me/v2/bot/message/reply"
-const lineAPIBase = "https://api.line.me/v2/bot"
+type lineQuickReplyItem struct {
+	Type   string     `json:"type"`
+	Action lineAction `json:"action"`
+}
 
-type lineProfileResponse struct {
-	DisplayName string `json:"displayName"`
+type lineAction struct {
+	Type        string `json:"type"`
+	Label       string `json:"label"`
+	Data        string `json:"data,omitempty"`
+	DisplayText string `json:"displayText,omitempty"`
 }
 
-func sendLineReply(ctx context.Context, replyToken string, texts ...string) error {
-	if replyToken == "" {
-		return errors.New("empty reply token")
-	}
-	token := os.Getenv("LINE_CHANNEL_ACCESS_TOKEN")
-	if token == "" {
-		return errors.New("LINE_CHANNEL_ACCESS_TOKEN not set")
-	}
+// sendLineReply 空のテキストを除いて返信する
+func sendLineReply(ctx context.Context, lc LineClient, replyToken string, texts ...string) error {
+	return lc.Reply(ctx, replyToken, lineTextMessages(texts...)...)
+}
 
+// lineTextMessages 空でないテキストをメッセージにする
+func lineTextMessages(texts ...string) []lineReplyMessage {
 	msgs := make([]lineReplyMessage, 0, len(texts))
 	for _, t := range texts {
 		if strings.TrimSpace(t) == "" {
 			continue
 		}
-		r := []rune(t)
-		if len(r) > 1000 {
-			r = r[:1000]
-		}
-		msgs = append(msgs, lineReplyMessage{Type: "text", Text: string(r)})
-	}
-	if len(msgs) == 0 {
-		return nil
+		msgs = append(msgs, lineTextMessage(t))
 	}
+	return msgs
+}
 
-	payload, err := json.Marshal(lineReplyRequest{ReplyToken: replyToken, Messages: msgs})
-	if err != nil {
-		return err
+// replyLineCommand コマンドの結果を返信する。処理中に一時的なエラーがあり、キューが再試行するなら
+// 失敗の返信はせずにエラーを返す（最後の試行では失敗の返信を送る）。返信自体の失敗はログだけ残す
+func replyLineCommand(ctx context.Context, lc LineClient, replyToken, op string, msgs ...lineReplyMessage) error {
+	if err := retryableFailure(ctx); err != nil {
+		return fmt.Errorf("line %s: %w", op, err)
 	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, lineReplyEndpoint, bytes.NewReader(payload))
-	if err != nil {
-		return err
+	if err := lc.Reply(ctx, replyToken, msgs...); err != nil {
+		log.Printf("LINE reply error (%s): %v", op, err)
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
+// lineTextMessage 1000文字で切り詰めたテキストメッセージ
+func lineTextMessage(text string) lineReplyMessage {
+	r := []rune(text)
+	if len(r) > 1000 {
+		r = r[:1000]
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("line reply failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(body)))
+	return lineReplyMessage{Type: "text", Text: string(r)}
+}
+
+// lineFlexMessage Flex Message。通知や非対応の端末ではaltText（400文字で切り詰めたプレーンテキスト版）が出る
+func lineFlexMessage(altText string, contents any) lineReplyMessage {
+	r := []rune(altText)
+	if len(r) > 400 {
+		r = r[:400]
 	}
-	return nil
+	return lineReplyMessage{Type: "flex", AltText: string(r), Contents: contents}
 }
 
 func writeErr(w http.ResponseWriter, code int, msg string) {
@@ -155,7 +166,7 @@ func verifyLINE(sig string, body []byte, secret string) bool {
 }
 
 // handleLineMessage LINEメッセージを家事報告に変換
-func handleLineMessage(ctx context.Context, sv *service.Service, botID string, e lineEvent) {
+func handleLineMessage(ctx context.Context, sv *service.Service, lc LineClient, botID string, e lineEvent) error {
 	isGroupContext := e.Source.GroupID != "" || e.Source.RoomID != ""
 	if isGroupContext {
 		mentioned := false
@@ -168,7 +179,7 @@ func handleLineMessage(ctx context.Context, sv *service.Service, botID string, e
 			}
 		}
 		if !mentioned {
-			return
+			return nil
 		}
 	}
 
@@ -182,19 +193,12 @@ func handleLineMessage(ctx context.Context, sv *service.Service, botID string, e
 		fields = append(fields, f)
 	}
 	if len(fields) == 0 {
-		return
+		return nil
 	}
 
-	groupID := e.Source.GroupID
-	if groupID == "" {
-		if e.Source.RoomID != "" {
-			groupID = e.Source.RoomID
-		} else {
-			groupID = e.Source.UserID
-		}
-	}
+	groupID := lineGroupID(e.Source)
 
-	displayName, fetchErr := fetchLineDisplayName(ctx, e.Source)
+	displayName, fetchErr := lc.Profile(ctx, e.Source)
 	if fetchErr != nil {
 		log.Printf("LINE profile fetch failed: group=%s room=%s user=%s err=%v", e.Source.GroupID, e.Source.RoomID, e.Source.UserID, fetchErr)
 	}
@@ -209,137 +213,328 @@ func handleLineMessage(ctx context.Context, sv *service.Service, botID string, e
 	cmd := strings.ToLower(fields[0])
 	switch cmd {
 	case "me":
-		summary, err := sv.WeeklyUserSummary(ctx, groupID, e.Source.UserID, time.Now())
-		if err != nil {
-			if replyErr := sendLineReply(ctx, e.ReplyToken, "取得失敗: 少し待ってから試してね"); replyErr != nil {
-				log.Printf("LINE reply error (me command failure): %v", replyErr)
-			}
-			log.Printf("LINE summary error: group=%s user=%s error=%v", groupID, e.Source.UserID, err)
-			return
-		}
-		if len(summary.TaskList) == 0 {
-			if err := sendLineReply(ctx, e.ReplyToken, "今週のポイントはまだ0ptだよ。"); err != nil {
-				log.Printf("LINE reply error (me command zero): %v", err)
-			}
-			return
-		}
-		lines := make([]string, 0, len(summary.TaskList)+2)
-		lines = append(lines, fmt.Sprintf("今週: %s", formatPoints(summary.Total)))
-		lines = append(lines, "内訳:")
-		for _, item := range summary.TaskList {
-			lines = append(lines, fmt.Sprintf("・%s %s", item.TaskKey, formatPoints(item.Points)))
-		}
-		if err := sendLineReply(ctx, e.ReplyToken, strings.Join(lines, "\n")); err != nil {
-			log.Printf("LINE reply error (me command): %v", err)
-		}
-		return
+		return replyLineCommand(ctx, lc, e.ReplyToken, "me command", lineMeReply(ctx, sv, groupID, e.Source.UserID, fields[1:]))
+	case "次誰", "next":
+		return replyLineCommand(ctx, lc, e.ReplyToken, "next assignee command", lineTextMessages(lineNextAssigneeReply(ctx, sv, groupID, fields[1:]))...)
+	case "バランス", "balance":
+		return replyLineCommand(ctx, lc, e.ReplyToken, "balance command", lineTextMessages(lineBalanceReply(ctx, sv, groupID, fields[1:]))...)
+	case "バッジ", "badge":
+		return replyLineCommand(ctx, lc, e.ReplyToken, "achievements command", lineTextMessages(lineAchievementsReply(ctx, sv, groupID, e.Source.UserID))...)
+	case "ごほうび", "rewards":
+		return replyLineCommand(ctx, lc, e.ReplyToken, "rewards command", lineTextMessages(lineRewardsReply(ctx, sv, groupID, e.Source.UserID))...)
+	case "交換", "redeem":
+		return replyLineCommand(ctx, lc, e.ReplyToken, "redeem command", lineTextMessages(lineRedeemReply(ctx, sv, groupID, e.Source.UserID, fields[1:]))...)
+	case "交換承認", "交換却下":
+		msg := lineDecideRedemptionReply(ctx, sv, groupID, e.Source.UserID, fields[1:], fields[0] == "交換承認")
+		return replyLineCommand(ctx, lc, e.ReplyToken, "redemption decision command", lineTextMessages(msg)...)
+	case "承認", "approve":
+		return replyLineCommand(ctx, lc, e.ReplyToken, "approve command", lineTextMessages(lineApproveReply(ctx, sv, groupID, e.Source.UserID, fields[1:]))...)
+	case "予定", "schedule":
+		return replyLineCommand(ctx, lc, e.ReplyToken, "schedule command", lineTextMessages(lineScheduleReply(ctx, sv, groupID))...)
+	case "履歴", "history":
+		return replyLineCommand(ctx, lc, e.ReplyToken, "history command", lineTextMessages(lineHistoryReply(ctx, sv, groupID, e.Source.UserID))...)
+	case "修正", "edit":
+		msg := lineEditEventReply(ctx, sv, groupID, e.Source.UserID, fields[1:])
+		return replyLineCommand(ctx, lc, e.ReplyToken, "edit command", lineTextMessages(msg)...)
+	case "復元", "restore":
+		return replyLineCommand(ctx, lc, e.ReplyToken, "restore command", lineTextMessages(lineRestoreReply(ctx, sv, groupID, e.Source.UserID, fields[1:]))...)
 	case "取消", "取り消し", "キャンセル", "cancel":
-		result, err := sv.CancelLatestEvent(ctx, groupID, e.Source.UserID)
+		if len(fields) > 1 {
+			msg := lineCancelEventReply(ctx, sv, groupID, e.Source.UserID, fields[1])
+			return replyLineCommand(ctx, lc, e.ReplyToken, "cancel by number", lineTextMessages(msg)...)
+		}
+		result, err := sv.CancelLatestEvent(ctx, groupID, e.Source.UserID, repo.SourceLINE)
 		if err != nil {
 			msg := "取り消し失敗: 少し待ってね"
 			if errors.Is(err, repo.ErrNoEventFound) {
 				msg = "取り消す記録がないよ。"
+			} else {
+				log.Printf("LINE cancel error: group=%s user=%s err=%v", groupID, e.Source.UserID, err)
+				noteLineFailure(ctx, err)
 			}
-			if replyErr := sendLineReply(ctx, e.ReplyToken, msg); replyErr != nil {
-				log.Printf("LINE reply error (cancel failure): %v", replyErr)
-			}
-			return
-		}
-		if err := sendLineReply(ctx, e.ReplyToken, fmt.Sprintf("直前の「%s」を取り消したよ。", result.TaskKey)); err != nil {
-			log.Printf("LINE reply error (cancel success): %v", err)
+			return replyLineCommand(ctx, lc, e.ReplyToken, "cancel failure", lineTextMessages(msg)...)
 		}
-		return
+		return replyLineCommand(ctx, lc, e.ReplyToken, "cancel success", lineTextMessages(fmt.Sprintf("直前の「%s」を取り消したよ。\n間違えたときは @bot 復元 で戻せるよ。", result.TaskKey))...)
 	case "top":
-		ranking, err := sv.WeeklyGroupRanking(ctx, groupID, time.Now())
-		if err != nil {
-			if replyErr := sendLineReply(ctx, e.ReplyToken, "ランキング取得失敗: 少し待ってね"); replyErr != nil {
-				log.Printf("LINE reply error (top command failure): %v", replyErr)
-			}
-			log.Printf("LINE ranking error: group=%s error=%v", groupID, err)
-			return
-		}
-		if len(ranking) == 0 {
-			if err := sendLineReply(ctx, e.ReplyToken, "今週はまだ誰も報告していないみたい。"); err != nil {
-				log.Printf("LINE reply error (top command empty): %v", err)
-			}
-			return
-		}
-		lines := make([]string, 0, len(ranking)+1)
-		lines = append(lines, "今週のポイント:")
-		for i, row := range ranking {
-			lines = append(lines, fmt.Sprintf("%d位 %s %s", i+1, row.Name, formatPoints(row.Points)))
-		}
-		if err := sendLineReply(ctx, e.ReplyToken, strings.Join(lines, "\n")); err != nil {
-			log.Printf("LINE reply error (top command): %v", err)
-		}
-		return
+		return replyLineCommand(ctx, lc, e.ReplyToken, "top command", lineTopReply(ctx, sv, groupID, fields[1:]))
 	case "task", "tasks":
-		defs := sv.TaskDefinitions()
-		lines := make([]string, 0, len(defs)+1)
-		lines = append(lines, "登録タスクとポイント:")
-		for _, def := range defs {
-			lines = append(lines, fmt.Sprintf("・%s: %s", def.Key, formatPoints(def.Points)))
+		if len(fields) > 1 {
+			msg := lineTaskAdminReply(ctx, sv, groupID, e.Source.UserID, fields[1:])
+			return replyLineCommand(ctx, lc, e.ReplyToken, "task admin command", lineTextMessages(msg)...)
 		}
-		if err := sendLineReply(ctx, e.ReplyToken, strings.Join(lines, "\n")); err != nil {
-			log.Printf("LINE reply error (task command): %v", err)
-		}
-		return
+		defs, err := sv.TaskDefinitions(ctx, groupID)
+		if err != nil {
+			log.Printf("LINE task list error: group=%s error=%v", groupID, err)
+			noteLineFailure(ctx, err)
+			return replyLineCommand(ctx, lc, e.ReplyToken, "task command failure", lineTextMessages("タスク取得失敗: 少し待ってね")...)
+		}
+		return replyLineCommand(ctx, lc, e.ReplyToken, "task command", taskCatalogMessage(defs))
+	case "返信", "reply":
+		msg := lineReportReplySettingReply(ctx, sv, groupID, e.Source.UserID, fields[1:])
+		return replyLineCommand(ctx, lc, e.ReplyToken, "reply setting command", lineTextMessages(msg)...)
 	case "help":
 		helpText := strings.Join([]string{
 			"使い方:",
 			"・@bot 皿洗い → 家事報告",
-			"・@bot me → 今週の自分のポイント",
-			"・@bot top → 今週のポイント一覧",
+			"・@bot 皿洗い ゴミ出し 洗濯 → まとめて報告",
+			"・@bot 皿洗い x2 → 回数つきで報告（時間で採点するタスクは 30分 のように時間も添えられる）",
+			"・@bot 昨日 皿洗い / @bot 11/3 洗濯 → 過去の日付で報告",
+			"・@bot me → 今週の自分のポイント（@bot me 月 / 年 で期間指定）",
+			"・@bot top → 今週のポイント一覧（@bot top 月 / 年 で期間指定）",
 			"・@bot 取消 → 直前の報告を取り消す",
+			"・@bot 復元 → 自分で最後に取り消した記録を戻す（@bot 復元 3 で番号指定。管理者）",
+			"・@bot 履歴 → 最近の記録と番号",
+			"・@bot 予定 → 定期の家事の期日（期限切れを含む）",
+			"・@bot 次誰 / @bot 次誰 皿洗い → 次の担当の提案",
+			"・@bot バランス / @bot バランス 月 → 目標の分担との差",
+			"・@bot バッジ → 連続日数と獲得したバッジ",
+			"・@bot ごほうび → ごほうび一覧と使えるポイント",
+			"・@bot 交換 マッサージ券 → ポイントで交換を申請（ほかのメンバーが @bot 交換承認 番号 で承認、@bot 交換却下 番号 で却下）",
+			"・@bot 取消 3 / @bot 修正 3 ゴミ出し → 番号を指定して取消・修正",
+			"・@bot 承認 / @bot 承認 3 → ほかのメンバーの承認待ちの報告を承認（承認制のハウス）",
 			"・@bot task → タスク一覧とポイント",
+			"・@bot task add 窓拭き 150 まど,窓 → タスク追加（管理者）",
+			"・@bot task set 皿洗い 200 → ポイント変更（管理者）",
+			"・@bot task rm 窓拭き → タスク削除（管理者）",
+			"・@bot 返信 常に/補正時/なし → 報告への返信設定（管理者）",
 			"・@bot help → このメッセージ",
 			"タスク名はかな/英語/タイプミス1文字まで自動補正するよ。",
 		}, "\n")
-		if err := sendLineReply(ctx, e.ReplyToken, helpText); err != nil {
-			log.Printf("LINE reply error (help command): %v", err)
-		}
-		return
+		return replyLineCommand(ctx, lc, e.ReplyToken, "help command", lineTextMessages(helpText)...)
 	}
 
-	task := fields[0]
-	var option *string
-	if len(fields) > 1 {
-		opt := fields[1]
-		option = &opt
+	// 「昨日」「11/3」などの日付はハウスのタイムゾーンで解釈する
+	cal, err := sv.HouseCalendar(ctx, groupID)
+	if err != nil {
+		log.Printf("LINE calendar error: group=%s error=%v", groupID, err)
+		cal = service.DefaultCalendar()
+	}
+	fields, performedAt := extractPerformedAt(fields, cal.Now())
+	items := parseReportItems(fields)
+	if len(items) == 0 {
+		return nil
 	}
 
 	payload := service.ReportPayload{
 		GroupID:     groupID,
 		UserID:      e.Source.UserID,
 		DisplayName: displayName,
-		Task:        task,
-		Option:      option,
 		SourceMsgID: &e.Message.ID,
+		PerformedAt: performedAt,
+		Source:      repo.SourceLINE,
+	}
+
+	return submitLineReport(ctx, sv, lc, e, payload, items)
+}
+
+// lineGroupID 集計単位のID（グループ > ルーム > 個人チャット）
+func lineGroupID(src lineSource) string {
+	switch {
+	case src.GroupID != "":
+		return src.GroupID
+	case src.RoomID != "":
+		return src.RoomID
+	default:
+		return src.UserID
 	}
+}
 
-	if err := sv.Report(ctx, payload); err != nil {
+// submitLineReport 報告を記録し、結果を返信する（タスクが曖昧な場合は候補をクイックリプライで提示）
+func submitLineReport(ctx context.Context, sv *service.Service, lc LineClient, e lineEvent, payload service.ReportPayload, items []service.ReportItem) error {
+	results, err := sv.ReportMany(ctx, payload, items)
+	if err != nil {
+		task := items[0].Task
+		itemIndex := 0
+		var itemErr *service.ReportItemError
+		if errors.As(err, &itemErr) {
+			task = itemErr.Task
+			itemIndex = itemErr.Index
+		}
 		var amb *service.TaskAmbiguousError
+		var optErr *service.OptionError
+		var dateErr *service.PerformedAtError
 		var msg string
 		switch {
 		case errors.Is(err, repo.ErrDuplicateEvent):
 			if e.isRedelivery() {
-				log.Printf("LINE redelivery duplicate ignored: event_id=%s group=%s user=%s msg_id=%s", e.WebhookEventID, groupID, e.Source.UserID, e.Message.ID)
-				return
+				log.Printf("LINE redelivery duplicate ignored: event_id=%s group=%s user=%s msg_id=%s", e.WebhookEventID, payload.GroupID, payload.UserID, *payload.SourceMsgID)
+				return nil
 			}
 			msg = "重複: この報告は登録済みだよ"
 		case errors.Is(err, service.ErrTaskNotFound):
 			msg = fmt.Sprintf("不明: \"%s\"", task)
 		case errors.As(err, &amb):
 			msg = fmt.Sprintf("不明: \"%s\" 候補: %s", task, strings.Join(amb.Candidates, "/"))
+			if qr, ok := ambiguityQuickReply(*payload.SourceMsgID, payload.UserID, payload.PerformedAt, items, itemIndex, amb.Candidates); ok {
+				reply := lineTextMessage(fmt.Sprintf("\"%s\" はどれのこと？", task))
+				reply.QuickReply = qr
+				if replyErr := lc.Reply(ctx, e.ReplyToken, reply); replyErr != nil {
+					log.Printf("LINE reply error (ambiguous quick reply): %v", replyErr)
+				}
+				return nil
+			}
+		case errors.As(err, &optErr):
+			msg = fmt.Sprintf("入力エラー: \"%s\" は読み取れなかったよ（例: 15分, 1時間, x2, 2回）", optErr.Input)
+		case errors.As(err, &dateErr):
+			if dateErr.Future {
+				msg = "入力エラー: 未来の日付では記録できないよ"
+			} else {
+				msg = fmt.Sprintf("入力エラー: さかのぼって記録できるのは%d日前までだよ", dateErr.LimitDays)
+			}
 		default:
-			log.Printf("LINE webhook error: group=%s user=%s msg_id=%s error=%v", groupID, e.Source.UserID, e.Message.ID, err)
+			// 記録は冪等キーで重複しないので、一時的な失敗は返信せずにキューの再試行に任せる
+			if willRetry(ctx) {
+				return err
+			}
+			log.Printf("LINE webhook error: group=%s user=%s msg_id=%s error=%v", payload.GroupID, payload.UserID, *payload.SourceMsgID, err)
 			msg = "失敗: 少し待ってから試してね"
 		}
-		if replyErr := sendLineReply(ctx, e.ReplyToken, msg); replyErr != nil {
+		if len(items) > 1 {
+			msg += "\n（まとめて送った報告はどれも記録していないよ）"
+		}
+		if replyErr := sendLineReply(ctx, lc, e.ReplyToken, msg); replyErr != nil {
 			log.Printf("LINE reply error (failure notice): %v", replyErr)
 		}
-		return
+		return nil
+	}
+
+	achievements, err := sv.CheckAchievements(ctx, payload.GroupID, payload.UserID)
+	if err != nil {
+		log.Printf("LINE achievements error: group=%s user=%s error=%v", payload.GroupID, payload.UserID, err)
+	}
+	settings, err := sv.HouseSettings(ctx, payload.GroupID)
+	if err != nil {
+		log.Printf("LINE settings error: group=%s error=%v", payload.GroupID, err)
+		settings.ReportReply = service.ReportReplyAlways
+	}
+	approval, pending := approvalQuickReply(results)
+	if !service.ShouldConfirmReport(settings.ReportReply, results) {
+		// 確認の返信をしない設定でも、承認待ちとバッジの獲得は知らせる
+		var notices []string
+		if pending {
+			notices = append(notices, formatPendingNotice(results))
+		}
+		if len(achievements) > 0 {
+			notices = append(notices, formatAchievementNotice(achievements))
+		}
+		if len(notices) == 0 {
+			return nil
+		}
+		reply := lineTextMessage(strings.Join(notices, "\n"))
+		reply.QuickReply = approval
+		if err := lc.Reply(ctx, e.ReplyToken, reply); err != nil {
+			log.Printf("LINE reply error (report notice): %v", err)
+		}
+		return nil
 	}
+	standing, err := sv.WeeklyStanding(ctx, payload.GroupID, payload.UserID, results[0].PerformedAt)
+	var standingPtr *service.WeeklyStanding
+	if err != nil {
+		log.Printf("LINE standing error: group=%s user=%s error=%v", payload.GroupID, payload.UserID, err)
+	} else {
+		standingPtr = &standing
+	}
+	msg := formatReportConfirmation(results, standingPtr)
+	if len(achievements) > 0 {
+		msg += "\n" + formatAchievementNotice(achievements)
+	}
+	reply := lineTextMessage(msg)
+	reply.QuickReply = approval
+	if err := lc.Reply(ctx, e.ReplyToken, reply); err != nil {
+		log.Printf("LINE reply error (report confirmation): %v", err)
+	}
+	return nil
+}
+
+// extractPerformedAt "昨日 皿洗い" / "皿洗い 11/3" の日付語を取り除き、実施日時として返す（最初の1語のみ）
+func extractPerformedAt(fields []string, now time.Time) ([]string, *time.Time) {
+	for i, field := range fields {
+		t, ok := service.ParseDateToken(field, now)
+		if !ok {
+			continue
+		}
+		rest := make([]string, 0, len(fields)-1)
+		rest = append(rest, fields[:i]...)
+		rest = append(rest, fields[i+1:]...)
+		return rest, &t
+	}
+	return fields, nil
+}
+
+// parseReportItems "皿洗い 15分 ゴミ出し、洗濯" を報告単位に分解する。
+// オプションとして解釈できる語（15分/x2 など）は直前のタスクに付ける
+func parseReportItems(fields []string) []service.ReportItem {
+	var items []service.ReportItem
+	for _, field := range fields {
+		for _, token := range strings.Split(field, "、") {
+			token = strings.TrimSpace(token)
+			if token == "" {
+				continue
+			}
+			if n := len(items); n > 0 && items[n-1].Option == nil && service.LooksLikeOption(token) {
+				opt := token
+				items[n-1].Option = &opt
+				continue
+			}
+			items = append(items, service.ReportItem{Task: token})
+		}
+	}
+	return items
+}
+
+// formatReportConfirmation 記録したタスク・ポイントと今週の合計/順位をまとめた返信文
+func formatReportConfirmation(results []service.ReportResult, standing *service.WeeklyStanding) string {
+	lines := make([]string, 0, len(results)+4)
+	if len(results) == 1 {
+		r := results[0]
+		lines = append(lines, fmt.Sprintf("記録したよ: %s %s", r.TaskKey, formatPoints(r.Points)))
+		if r.Corrected {
+			lines = append(lines, fmt.Sprintf("（「%s」→「%s」として記録）", r.Input, r.TaskKey))
+		}
+		if r.Backdated {
+			lines = append(lines, fmt.Sprintf("（%sの分として記録）", r.PerformedAt.Format("1/2")))
+		}
+	} else {
+		lines = append(lines, fmt.Sprintf("%d件記録したよ:", len(results)))
+		var total float64
+		for _, r := range results {
+			line := fmt.Sprintf("・%s %s", r.TaskKey, formatPoints(r.Points))
+			if r.Corrected {
+				line += fmt.Sprintf("（「%s」を補正）", r.Input)
+			}
+			lines = append(lines, line)
+			total += r.Points
+		}
+		lines = append(lines, fmt.Sprintf("合計 %s", formatPoints(total)))
+		if results[0].Backdated {
+			lines = append(lines, fmt.Sprintf("（%sの分として記録）", results[0].PerformedAt.Format("1/2")))
+		}
+	}
+	for _, r := range results {
+		if r.Pending {
+			lines = append(lines, formatPendingNotice(results))
+			break
+		}
+	}
+	if standing != nil && standing.Rank > 0 {
+		week := "今週"
+		if standing.Past {
+			week = standing.Start.Format("1/2") + "〜の週"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s（%d位/%d人中）", week, formatPoints(standing.Total), standing.Rank, standing.Members))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatPendingNotice 承認待ちになった報告の案内（例: 承認待ち: #12 #13 …）
+func formatPendingNotice(results []service.ReportResult) string {
+	seqs := make([]string, 0, len(results))
+	for _, r := range results {
+		if r.Pending {
+			seqs = append(seqs, fmt.Sprintf("#%d", r.Seq))
+		}
+	}
+	return fmt.Sprintf("承認待ち: %s（ほかのメンバーが承認すると集計に入るよ）", strings.Join(seqs, " "))
 }
 
 func formatPoints(pt float64) string {
@@ -363,14 +558,19 @@ func readableAliases(key string, aliases []string) string {
 	return strings.Join(out, ", ")
 }
 
-func weekRange(ref time.Time) (time.Time, time.Time) {
-	wd := int(ref.Weekday())
-	if wd == 0 {
-		wd = 7
+// houseCalendarRef ハウスの暦と基準時刻（date=YYYY-MM-DD はハウスの日付として読む。省略時は現在）
+func houseCalendarRef(ctx context.Context, sv *service.Service, group, dateStr string) (service.Calendar, time.Time, error) {
+	cal, err := sv.HouseCalendar(ctx, group)
+	if err != nil {
+		return service.Calendar{}, time.Time{}, err
 	}
-	start := time.Date(ref.Year(), ref.Month(), ref.Day(), 0, 0, 0, 0, ref.Location()).AddDate(0, 0, -(wd - 1))
-	end := start.AddDate(0, 0, 7)
-	return start, end
+	ref := time.Now()
+	if dateStr != "" {
+		if t, err := time.Parse("2006-01-02", dateStr); err == nil {
+			ref = cal.At(t)
+		}
+	}
+	return cal, ref, nil
 }
 
 var tasksPageTmpl = template.Must(template.New("tasks").
@@ -477,59 +677,8 @@ var weeklyTopTmpl = template.Must(template.New("weeklyTop").
 </body>
 </html>`))
 
-func fetchLineDisplayName(ctx context.Context, src lineSource) (*string, error) {
-	if src.UserID == "" {
-		return nil, errors.New("line source user id is empty")
-	}
-
-	token := os.Getenv("LINE_CHANNEL_ACCESS_TOKEN")
-	if token == "" {
-		return nil, errors.New("LINE_CHANNEL_ACCESS_TOKEN not set")
-	}
-
-	var endpoint string
-	switch {
-	case src.GroupID != "":
-		endpoint = fmt.Sprintf("%s/group/%s/member/%s", lineAPIBase, url.PathEscape(src.GroupID), url.PathEscape(src.UserID))
-	case src.RoomID != "":
-		endpoint = fmt.Sprintf("%s/room/%s/member/%s", lineAPIBase, url.PathEscape(src.RoomID), url.PathEscape(src.UserID))
-	default:
-		endpoint = fmt.Sprintf("%s/profile/%s", lineAPIBase, url.PathEscape(src.UserID))
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden {
-		return nil, fmt.Errorf("line profile not accessible: status=%d", resp.StatusCode)
-	}
-	if resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("line profile fetch failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(body)))
-	}
-
-	var profile lineProfileResponse
-	if err := json.NewDecoder(resp.Body).Decode(&profile); err != nil {
-		return nil, err
-	}
-
-	name := strings.TrimSpace(profile.DisplayName)
-	if name == "" {
-		return nil, nil
-	}
-	return &name, nil
-}
-
-func Router(sv *service.Service) http.Handler {
+// Router queueは /webhook で受けたイベントを処理するキュー（StartとShutdownは呼び出し側で行う）
+func Router(sv *service.Service, queue *WebhookQueue) http.Handler {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
@@ -605,15 +754,12 @@ func Router(sv *service.Service) http.Handler {
 			return
 		}
 
-		for _, e := range payload.Events {
-			if e.Type == "message" && e.Message.Type == "text" {
-				eventCopy := e
-				go func(ev lineEvent) {
-					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-					defer cancel()
-					handleLineMessage(ctx, sv, payload.Destination, ev)
-				}(eventCopy)
-			}
+		// 処理する前にDBへ保存してから204を返す（落ちても再起動後にワーカーが処理する）
+		if err := queue.Enqueue(r.Context(), payload.Destination, payload.Events); err != nil {
+			// 500を返すとLINEが再送する（再送設定が有効な場合）。再送は webhookEventId で重複を除く
+			log.Printf("LINE webhook enqueue error: events=%d err=%v", len(payload.Events), err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
 		}
 
 		w.WriteHeader(http.StatusNoContent)
@@ -636,7 +782,7 @@ func Router(sv *service.Service) http.Handler {
 			writeErr(w, 400, "invalid json: "+err.Error())
 			return
 		}
-		if err := sv.Report(r.Context(), p); err != nil {
+		if _, err := sv.Report(r.Context(), p); err != nil {
 			if errors.Is(err, repo.ErrDuplicateEvent) {
 				w.Header().Set("Content-Type", "application/json")
 				w.WriteHeader(200)
@@ -661,22 +807,22 @@ func Router(sv *service.Service) http.Handler {
 			writeErr(w, 400, err.Error())
 			return
 		}
+		if _, err := sv.CheckAchievements(r.Context(), p.GroupID, p.UserID); err != nil {
+			log.Printf("achievements error: group=%s user=%s err=%v", p.GroupID, p.UserID, err)
+		}
 		w.WriteHeader(http.StatusNoContent)
 	})
 
 	// 週次集計（JSON）
-	// GET /houses/{group}/weekly?date=2025-11-10  ← date含む週(月曜起点)を集計
+	// GET /houses/{group}/weekly?date=2025-11-10  ← date含む週(ハウスの週の開始曜日起点)を集計
 	r.Get("/houses/{group}/weekly", func(w http.ResponseWriter, r *http.Request) {
 		group := chi.URLParam(r, "group")
-		dateStr := r.URL.Query().Get("date")
-
-		ref := time.Now()
-		if dateStr != "" {
-			if t, err := time.Parse("2006-01-02", dateStr); err == nil {
-				ref = t
-			}
+		cal, ref, err := houseCalendarRef(r.Context(), sv, group, r.URL.Query().Get("date"))
+		if err != nil {
+			http.Error(w, "query error", 500)
+			return
 		}
-		start, end := weekRange(ref)
+		start, end := cal.WeekRange(ref)
 
 		rows, err := sv.Rp().WeeklyPoints(r.Context(), group, start, end)
 		if err != nil {
@@ -704,8 +850,18 @@ func Router(sv *service.Service) http.Handler {
 	})
 
 	// 家事タスク一覧（HTML）
+	// GET /tasks?group=default-house
 	r.Get("/tasks", func(w http.ResponseWriter, r *http.Request) {
-		defs := sv.TaskDefinitions()
+		group := r.URL.Query().Get("group")
+		if strings.TrimSpace(group) == "" {
+			group = "default-house"
+		}
+		defs, err := sv.TaskDefinitions(r.Context(), group)
+		if err != nil {
+			log.Printf("tasks page error: group=%s err=%v", group, err)
+			http.Error(w, "task fetch failed", http.StatusInternalServerError)
+			return
+		}
 		sort.Slice(defs, func(i, j int) bool {
 			if defs[i].Points == defs[j].Points {
 				return defs[i].Key < defs[j].Key
@@ -743,14 +899,13 @@ func Router(sv *service.Service) http.Handler {
 			http.Error(w, "group is required", http.StatusBadRequest)
 			return
 		}
-		dateStr := r.URL.Query().Get("date")
-		ref := time.Now()
-		if dateStr != "" {
-			if t, err := time.Parse("2006-01-02", dateStr); err == nil {
-				ref = t
-			}
+		cal, ref, err := houseCalendarRef(r.Context(), sv, group, r.URL.Query().Get("date"))
+		if err != nil {
+			log.Printf("weekly top error: group=%s err=%v", group, err)
+			http.Error(w, "ranking fetch failed", http.StatusInternalServerError)
+			return
 		}
-		start, end := weekRange(ref)
+		start, end := cal.WeekRange(ref)
 
 		ranking, err := sv.WeeklyGroupRanking(r.Context(), group, ref)
 		if err != nil {
@@ -789,5 +944,15 @@ func Router(sv *service.Service) http.Handler {
 		}
 	})
 
+	mountTaskRoutes(r, sv)
+	mountSettingsRoutes(r, sv)
+	mountEventRoutes(r, sv)
+	mountSummaryRoutes(r, sv)
+	mountScheduleRoutes(r, sv)
+	mountAssignmentRoutes(r, sv)
+	mountBalanceRoutes(r, sv)
+	mountAchievementRoutes(r, sv)
+	mountRewardRoutes(r, sv)
+
 	return r
 }
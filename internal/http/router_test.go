@@ -2,11 +2,22 @@ package httpapi
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
+
+	"chores_contributor/internal/linefake"
+	"chores_contributor/internal/repo"
+	"chores_contributor/internal/service"
+
+	"github.com/DATA-DOG/go-sqlmock"
 )
 
 func TestFormatPoints(t *testing.T) {
@@ -28,15 +39,8 @@ func TestFormatPoints(t *testing.T) {
 	}
 }
 
-func TestFetchLineDisplayName(t *testing.T) {
+func TestLineClientProfile(t *testing.T) {
 	ctx := context.Background()
-	oldClient := http.DefaultClient
-	defer func() { http.DefaultClient = oldClient }()
-	oldToken := os.Getenv("LINE_CHANNEL_ACCESS_TOKEN")
-	if err := os.Setenv("LINE_CHANNEL_ACCESS_TOKEN", "test-token"); err != nil {
-		t.Fatalf("failed to set env: %v", err)
-	}
-	defer func() { _ = os.Setenv("LINE_CHANNEL_ACCESS_TOKEN", oldToken) }()
 
 	type request struct {
 		method string
@@ -79,7 +83,7 @@ func TestFetchLineDisplayName(t *testing.T) {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
 			var got request
-			http.DefaultClient = &http.Client{
+			lc := NewLineClient("", "test-token", &http.Client{
 				Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
 					got = request{method: req.Method, host: req.URL.Host, path: req.URL.Path}
 					return &http.Response{
@@ -88,11 +92,11 @@ func TestFetchLineDisplayName(t *testing.T) {
 						Header:     make(http.Header),
 					}, nil
 				}),
-			}
+			})
 
-			name, err := fetchLineDisplayName(ctx, tt.src)
+			name, err := lc.Profile(ctx, tt.src)
 			if err != nil {
-				t.Fatalf("fetchLineDisplayName returned error: %v", err)
+				t.Fatalf("Profile returned error: %v", err)
 			}
 			if got.method != http.MethodGet {
 				t.Fatalf("expected GET request, got %s", got.method)
@@ -117,17 +121,10 @@ func TestFetchLineDisplayName(t *testing.T) {
 	}
 }
 
-func TestFetchLineDisplayNameError(t *testing.T) {
+func TestLineClientProfileError(t *testing.T) {
 	ctx := context.Background()
-	oldClient := http.DefaultClient
-	defer func() { http.DefaultClient = oldClient }()
-	oldToken := os.Getenv("LINE_CHANNEL_ACCESS_TOKEN")
-	if err := os.Setenv("LINE_CHANNEL_ACCESS_TOKEN", "test-token"); err != nil {
-		t.Fatalf("failed to set env: %v", err)
-	}
-	defer func() { _ = os.Setenv("LINE_CHANNEL_ACCESS_TOKEN", oldToken) }()
 
-	http.DefaultClient = &http.Client{
+	lc := NewLineClient("", "test-token", &http.Client{
 		Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
 			return &http.Response{
 				StatusCode: http.StatusNotFound,
@@ -135,10 +132,10 @@ func TestFetchLineDisplayNameError(t *testing.T) {
 				Header:     make(http.Header),
 			}, nil
 		}),
-	}
+	})
 
 	src := lineSource{GroupID: "g", UserID: "u"}
-	name, err := fetchLineDisplayName(ctx, src)
+	name, err := lc.Profile(ctx, src)
 	if err == nil {
 		t.Fatalf("expected error, got nil")
 	}
@@ -154,3 +151,711 @@ type roundTripper func(*http.Request) (*http.Response, error)
 func (rt roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	return rt(req)
 }
+
+func TestSplitAliasList(t *testing.T) {
+	got := splitAliasList("まど, 窓、ﾏﾄﾞ,,")
+	want := []string{"まど", "窓", "ﾏﾄﾞ"}
+	if len(got) != len(want) {
+		t.Fatalf("splitAliasList = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("splitAliasList = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestParseTaskPoints(t *testing.T) {
+	for in, want := range map[string]float64{"150": 150, "200pt": 200, "12.5": 12.5} {
+		got, err := parseTaskPoints(in)
+		if err != nil || got != want {
+			t.Fatalf("parseTaskPoints(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := parseTaskPoints("たくさん"); err == nil {
+		t.Fatalf("expected error for non-numeric points")
+	}
+}
+
+func TestParseReportItems(t *testing.T) {
+	items := parseReportItems([]string{"皿洗い", "15分", "ゴミ出し、洗濯", "x2", "風呂", "2階トイレ掃除"})
+	want := []struct {
+		task   string
+		option string
+	}{
+		{"皿洗い", "15分"},
+		{"ゴミ出し", ""},
+		{"洗濯", "x2"},
+		{"風呂", ""},
+		{"2階トイレ掃除", ""},
+	}
+	if len(items) != len(want) {
+		t.Fatalf("expected %d items, got %+v", len(want), items)
+	}
+	for i, w := range want {
+		if items[i].Task != w.task {
+			t.Fatalf("item %d task = %q, want %q", i, items[i].Task, w.task)
+		}
+		got := ""
+		if items[i].Option != nil {
+			got = *items[i].Option
+		}
+		if got != w.option {
+			t.Fatalf("item %d option = %q, want %q", i, got, w.option)
+		}
+	}
+}
+
+func TestFormatReportConfirmation(t *testing.T) {
+	tests := []struct {
+		name     string
+		results  []service.ReportResult
+		standing *service.WeeklyStanding
+		want     string
+	}{
+		{
+			name:     "single with standing",
+			results:  []service.ReportResult{{Input: "皿洗い", TaskKey: "皿洗い", Points: 180}},
+			standing: &service.WeeklyStanding{Total: 540, Rank: 2, Members: 3},
+			want:     "記録したよ: 皿洗い 180pt\n今週 540pt（2位/3人中）",
+		},
+		{
+			name:    "single corrected without standing",
+			results: []service.ReportResult{{Input: "皿荒い", TaskKey: "皿洗い", Points: 180, Corrected: true}},
+			want:    "記録したよ: 皿洗い 180pt\n（「皿荒い」→「皿洗い」として記録）",
+		},
+		{
+			name: "multiple",
+			results: []service.ReportResult{
+				{Input: "皿洗い", TaskKey: "皿洗い", Points: 180},
+				{Input: "ごみだs", TaskKey: "ゴミ出し", Points: 100, Corrected: true},
+			},
+			standing: &service.WeeklyStanding{Total: 280, Rank: 1, Members: 1},
+			want:     "2件記録したよ:\n・皿洗い 180pt\n・ゴミ出し 100pt（「ごみだs」を補正）\n合計 280pt\n今週 280pt（1位/1人中）",
+		},
+		{
+			name: "single backdated",
+			results: []service.ReportResult{{
+				Input: "洗濯", TaskKey: "洗濯", Points: 120,
+				PerformedAt: time.Date(2025, 11, 3, 12, 0, 0, 0, time.UTC), Backdated: true,
+			}},
+			want: "記録したよ: 洗濯 120pt\n（11/3の分として記録）",
+		},
+		{
+			name: "backdated into previous week",
+			results: []service.ReportResult{{
+				Input: "洗濯", TaskKey: "洗濯", Points: 120,
+				PerformedAt: time.Date(2025, 11, 2, 12, 0, 0, 0, time.UTC), Backdated: true,
+			}},
+			standing: &service.WeeklyStanding{
+				Total: 300, Rank: 2, Members: 3,
+				Start: time.Date(2025, 10, 27, 0, 0, 0, 0, time.UTC), Past: true,
+			},
+			want: "記録したよ: 洗濯 120pt\n（11/2の分として記録）\n10/27〜の週 300pt（2位/3人中）",
+		},
+		{
+			name:     "pending approval",
+			results:  []service.ReportResult{{Input: "皿洗い", TaskKey: "皿洗い", Points: 180, Seq: 12, Pending: true}},
+			standing: &service.WeeklyStanding{Total: 360, Rank: 1, Members: 2},
+			want:     "記録したよ: 皿洗い 180pt\n承認待ち: #12（ほかのメンバーが承認すると集計に入るよ）\n今週 360pt（1位/2人中）",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatReportConfirmation(tt.results, tt.standing); got != tt.want {
+				t.Fatalf("formatReportConfirmation = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAmbiguityQuickReplyRoundTrip(t *testing.T) {
+	opt := "15分"
+	items := []service.ReportItem{{Task: "皿洗い"}, {Task: "ふろ", Option: &opt}}
+	qr, ok := ambiguityQuickReply("m123", "Uabc", nil, items, 1, []string{"風呂掃除", "風呂排水溝"})
+	if !ok {
+		t.Fatalf("expected quick reply to be built")
+	}
+	if len(qr.Items) != 2 {
+		t.Fatalf("expected 2 quick reply items, got %d", len(qr.Items))
+	}
+	action := qr.Items[1].Action
+	if action.Type != "postback" || action.Label != "風呂排水溝" {
+		t.Fatalf("unexpected action: %+v", action)
+	}
+
+	data, decoded, ok := decodeReportPostback(action.Data)
+	if !ok {
+		t.Fatalf("failed to decode postback data %q", action.Data)
+	}
+	if data.MsgID != "m123" || data.UserID != "Uabc" {
+		t.Fatalf("unexpected postback data: %+v", data)
+	}
+	if len(decoded) != 2 || decoded[0].Task != "皿洗い" || decoded[1].Task != "風呂排水溝" {
+		t.Fatalf("unexpected decoded items: %+v", decoded)
+	}
+	if decoded[1].Option == nil || *decoded[1].Option != "15分" {
+		t.Fatalf("expected option to be preserved, got %+v", decoded[1].Option)
+	}
+	if items[1].Task != "ふろ" {
+		t.Fatalf("original items must not be modified, got %q", items[1].Task)
+	}
+}
+
+func TestApprovalQuickReplyRoundTrip(t *testing.T) {
+	if _, ok := approvalQuickReply([]service.ReportResult{{TaskKey: "皿洗い", Seq: 3}}); ok {
+		t.Fatalf("expected no approval button without pending reports")
+	}
+	qr, ok := approvalQuickReply([]service.ReportResult{
+		{TaskKey: "皿洗い", Seq: 12, Pending: true},
+		{TaskKey: "ゴミ出し", Seq: 13, Pending: true},
+	})
+	if !ok || len(qr.Items) != 1 {
+		t.Fatalf("expected a single approval button, got %+v", qr)
+	}
+	action := qr.Items[0].Action
+	if action.Type != "postback" || postbackAction(action.Data) != postbackActionApprove {
+		t.Fatalf("unexpected action: %+v", action)
+	}
+	seqs, ok := decodeApprovePostback(action.Data)
+	if !ok || len(seqs) != 2 || seqs[0] != 12 || seqs[1] != 13 {
+		t.Fatalf("unexpected decoded seqs: %v", seqs)
+	}
+	if _, _, ok := decodeReportPostback(action.Data); ok {
+		t.Fatalf("approval postback must not decode as a report")
+	}
+}
+
+func TestAmbiguityQuickReplyTooLong(t *testing.T) {
+	items := []service.ReportItem{{Task: "ふろ"}, {Task: strings.Repeat("あ", 300)}}
+	if _, ok := ambiguityQuickReply("m1", "U1", nil, items, 0, []string{"a", "b"}); ok {
+		t.Fatalf("expected quick reply to be skipped when postback data exceeds the limit")
+	}
+}
+
+func TestDecodeReportPostbackInvalid(t *testing.T) {
+	for _, raw := range []string{"", "not-json", `{"a":"other","m":"1","i":[{"t":"x"}]}`, `{"a":"report","m":"","i":[{"t":"x"}]}`} {
+		if _, _, ok := decodeReportPostback(raw); ok {
+			t.Fatalf("expected decode failure for %q", raw)
+		}
+	}
+}
+
+func TestExtractPerformedAt(t *testing.T) {
+	now := time.Date(2025, 11, 5, 20, 0, 0, 0, time.UTC)
+
+	rest, at := extractPerformedAt([]string{"皿洗い", "昨日", "15分"}, now)
+	if at == nil || !at.Equal(now.AddDate(0, 0, -1)) {
+		t.Fatalf("expected yesterday, got %v", at)
+	}
+	if strings.Join(rest, " ") != "皿洗い 15分" {
+		t.Fatalf("unexpected remaining fields: %v", rest)
+	}
+
+	rest, at = extractPerformedAt([]string{"皿洗い", "15分"}, now)
+	if at != nil || len(rest) != 2 {
+		t.Fatalf("expected no date, got %v %v", rest, at)
+	}
+}
+
+func TestParseEventSeq(t *testing.T) {
+	for in, want := range map[string]int64{"3": 3, "#12": 12, "３": 3, "＃7": 7} {
+		if got, ok := parseEventSeq(in); !ok || got != want {
+			t.Fatalf("parseEventSeq(%q) = %d, %v; want %d", in, got, ok, want)
+		}
+	}
+	for _, in := range []string{"", "0", "-1", "abc"} {
+		if _, ok := parseEventSeq(in); ok {
+			t.Fatalf("expected parseEventSeq(%q) to fail", in)
+		}
+	}
+}
+
+func TestFormatEventEntry(t *testing.T) {
+	opt := "30分"
+	ev := service.Event{Seq: 12, TaskKey: "散歩", Option: &opt, Points: 50, PerformedAt: time.Date(2025, 11, 3, 9, 0, 0, 0, time.UTC)}
+	if got, want := formatEventEntry(ev), "#12 11/3 散歩 30分 50pt"; got != want {
+		t.Fatalf("formatEventEntry = %q, want %q", got, want)
+	}
+}
+
+func TestLinePeriod(t *testing.T) {
+	cases := map[string]string{"月": service.GranularityMonth, "今年": service.PeriodYear, "今日": service.GranularityDay, "週": service.GranularityWeek}
+	for in, want := range cases {
+		if unit, _, ok := linePeriod([]string{in}); !ok || unit != want {
+			t.Fatalf("linePeriod(%q) = %q, %v; want %q", in, unit, ok, want)
+		}
+	}
+	if unit, label, ok := linePeriod(nil); !ok || unit != service.GranularityWeek || label != "今週" {
+		t.Fatalf("expected default to this week, got %q %q %v", unit, label, ok)
+	}
+	if _, _, ok := linePeriod([]string{"来月"}); ok {
+		t.Fatalf("expected unknown period to be rejected")
+	}
+}
+
+func TestFormatTopSummary(t *testing.T) {
+	sum := service.Summary{Users: []service.SummaryUser{{Name: "Bob", Points: 460}, {Name: "Alice", Points: 280}}}
+	if got, want := formatTopSummary("今月", sum), "今月のポイント:\n1位 Bob 460pt\n2位 Alice 280pt"; got != want {
+		t.Fatalf("formatTopSummary = %q, want %q", got, want)
+	}
+	if got, want := formatTopSummary("今年", service.Summary{}), "今年はまだ誰も報告していないみたい。"; got != want {
+		t.Fatalf("formatTopSummary empty = %q, want %q", got, want)
+	}
+}
+
+func TestTopSummaryMessage(t *testing.T) {
+	sum := service.Summary{Users: []service.SummaryUser{{Name: "Bob", Points: 460}, {Name: "Alice", Points: 280}}}
+	msg := topSummaryMessage("今月", sum)
+	if msg.Type != "flex" || msg.AltText != formatTopSummary("今月", sum) {
+		t.Fatalf("expected flex message with text fallback, got %+v", msg)
+	}
+	b, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if strings.Contains(string(b), `"text":""`) || !strings.Contains(string(b), `"contents":{"type":"bubble"`) {
+		t.Fatalf("unexpected flex message JSON: %s", b)
+	}
+	if got := topSummaryMessage("今年", service.Summary{}); got.Type != "text" {
+		t.Fatalf("expected text message when nobody reported, got %+v", got)
+	}
+}
+
+func TestFormatWeeklyRecap(t *testing.T) {
+	recap := service.WeeklyRecap{
+		Start:     time.Date(2025, 11, 10, 0, 0, 0, 0, time.UTC),
+		End:       time.Date(2025, 11, 16, 0, 0, 0, 0, time.UTC),
+		Total:     700,
+		PrevTotal: 800,
+		Members: []service.RecapMember{
+			{Name: "Bob", Rank: 1, Points: 400, PrevPoints: 300, TopTask: "皿洗い", TopTaskPoints: 200},
+			{Name: "Alice", Rank: 2, Points: 300, PrevPoints: 300, TopTask: "洗濯", TopTaskPoints: 300},
+		},
+	}
+	want := strings.Join([]string{
+		"先週のまとめ（11/10〜11/16）",
+		"1位 Bob 400pt（先週比 +100pt）",
+		"　いちばん: 皿洗い 200pt",
+		"2位 Alice 300pt（先週比 ±0pt）",
+		"　いちばん: 洗濯 300pt",
+		"合計 700pt（先週比 -100pt）",
+		"今週もよろしくね！",
+	}, "\n")
+	if got := formatWeeklyRecap(recap); got != want {
+		t.Fatalf("formatWeeklyRecap =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatScheduleList(t *testing.T) {
+	schedules := []service.Schedule{
+		{TaskKey: "ゴミ出し", NextDue: time.Date(2025, 11, 16, 0, 0, 0, 0, time.UTC), DaysLeft: -2, Overdue: true, AssigneeName: "Bob"},
+		{TaskKey: "皿洗い", DaysLeft: 0},
+		{TaskKey: "風呂掃除", NextDue: time.Date(2025, 11, 20, 0, 0, 0, 0, time.UTC), DaysLeft: 2},
+	}
+	want := strings.Join([]string{
+		"家事の予定:",
+		"・ゴミ出し 期限切れ（11/16(日)まで） 担当: Bob",
+		"・皿洗い 今日",
+		"・風呂掃除 11/20(木)",
+	}, "\n")
+	if got := formatScheduleList(schedules); got != want {
+		t.Fatalf("formatScheduleList =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatNextAssignee(t *testing.T) {
+	last := time.Date(2025, 11, 12, 20, 0, 0, 0, time.UTC)
+	plan := service.AssignmentPlan{
+		FairShare: 400,
+		Assignments: []service.Assignment{{
+			TaskKey: "皿洗い", Rotation: service.RotationLeastPoints, UserID: "Ub", Name: "Bob",
+			Candidates: []service.AssignmentCandidate{{UserID: "Ub", Name: "Bob", Points: 200, LastDoneAt: &last}},
+		}},
+	}
+	want := "次の皿洗いは Bob さんの番だよ。\n（直近4週 200pt・目安 400pt）"
+	if got := formatNextAssignee(plan); got != want {
+		t.Fatalf("formatNextAssignee =\n%s\nwant\n%s", got, want)
+	}
+
+	plan.Assignments[0].Rotation = service.RotationRoundRobin
+	want = "次の皿洗いは Bob さんの番だよ。\n（順番制・前回は11/12）"
+	if got := formatNextAssignee(plan); got != want {
+		t.Fatalf("formatNextAssignee =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := formatAssignmentList(plan); got != "次の担当:\n・皿洗い → Bob" {
+		t.Fatalf("formatAssignmentList = %q", got)
+	}
+}
+
+func TestFormatBalance(t *testing.T) {
+	bal := service.Balance{
+		Start:    time.Date(2025, 11, 3, 0, 0, 0, 0, time.UTC),
+		End:      time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC),
+		Total:    1000,
+		Targeted: true,
+		Members: []service.BalanceMember{
+			{Name: "Alice", Points: 700, Share: 0.7, TargetShare: 0.6, Debt: -100},
+			{Name: "Bob", Points: 300, Share: 0.3, TargetShare: 0.4, Debt: 100},
+		},
+	}
+	want := strings.Join([]string{
+		"バランス（11/3〜11/30）",
+		"・Alice 700pt 70%（目標 60%）100pt 多め",
+		"・Bob 300pt 30%（目標 40%）あと100pt",
+		"合計 1000pt",
+	}, "\n")
+	if got := formatBalance(bal); got != want {
+		t.Fatalf("formatBalance =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatAchievements(t *testing.T) {
+	week := time.Date(2025, 11, 10, 0, 0, 0, 0, time.UTC)
+	earned := time.Date(2025, 11, 17, 9, 0, 0, 0, time.UTC)
+	report := service.AchievementReport{
+		CurrentStreak: 3,
+		BestStreak:    7,
+		Achievements: []service.Achievement{
+			{Kind: service.AchievementStreak, Threshold: 7, EarnedAt: earned},
+			{Kind: service.AchievementTaskMilestone, TaskKey: "皿洗い", Threshold: 100, EarnedAt: earned},
+			{Kind: service.AchievementWeeklyChampion, PeriodStart: &week, EarnedAt: earned},
+		},
+	}
+	want := strings.Join([]string{
+		"連続 3日（最長 7日）",
+		"バッジ 3個:",
+		"・7日連続（11/17）",
+		"・皿洗い 100回（11/17）",
+		"・週間チャンピオン（11/10〜の週）（11/17）",
+	}, "\n")
+	if got := formatAchievements(report); got != want {
+		t.Fatalf("formatAchievements =\n%s\nwant\n%s", got, want)
+	}
+	if got := formatAchievementNotice(report.Achievements[:2]); got != "バッジ獲得: 「7日連続」「皿洗い 100回」" {
+		t.Fatalf("formatAchievementNotice = %q", got)
+	}
+}
+
+func TestFormatRewards(t *testing.T) {
+	rewards := []service.Reward{{Name: "マッサージ券", Cost: 2000}, {Name: "映画", Cost: 5000}}
+	wallet := &service.Wallet{Balance: 2300}
+	pending := []service.Redemption{{ID: 12, Name: "Alice", Reward: "マッサージ券"}}
+	want := strings.Join([]string{
+		"ごほうび（使えるポイント 2300pt）:",
+		"・マッサージ券 2000pt",
+		"・映画 5000pt",
+		"承認待ち:",
+		"・#12 Alice マッサージ券",
+		"交換は「@bot 交換 名前」、承認は「@bot 交換承認 番号」で。",
+	}, "\n")
+	if got := formatRewards(rewards, wallet, pending); got != want {
+		t.Fatalf("formatRewards =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	cases := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 2 * time.Second},
+		{2, 4 * time.Second},
+		{4, 16 * time.Second},
+		{5, 30 * time.Second},
+		{10, 30 * time.Second},
+	}
+	for _, tc := range cases {
+		if got := webhookRetryDelay(tc.attempts); got != tc.want {
+			t.Fatalf("webhookRetryDelay(%d) = %s, want %s", tc.attempts, got, tc.want)
+		}
+	}
+}
+
+func TestRunWebhookJobRejectsInvalidPayload(t *testing.T) {
+	for _, payload := range []string{`{`, `{"type":"follow"}`} {
+		err := runWebhookJob(context.Background(), nil, nil, 0, repo.WebhookJob{ID: 1, Payload: []byte(payload), Attempts: 1})
+		if !errors.Is(err, errInvalidWebhookJob) {
+			t.Fatalf("payload %s: expected errInvalidWebhookJob, got %v", payload, err)
+		}
+	}
+}
+
+func TestLineClientPushRetryKey(t *testing.T) {
+	fake := linefake.NewServer("test-token")
+	defer fake.Close()
+	lc := NewLineClient(fake.URL(), "test-token", nil)
+	ctx := context.Background()
+
+	// 同じretryKeyの再送は409になるが、送信済みとして成功にする（今回は送っていないので sent=false）
+	for i, wantSent := range []bool{true, false} {
+		sent, err := lc.Push(ctx, "G1", "key-1", lineTextMessage("まとめ"))
+		if err != nil {
+			t.Fatalf("push %d: %v", i, err)
+		}
+		if sent != wantSent {
+			t.Fatalf("push %d: expected sent=%v, got %v", i, wantSent, sent)
+		}
+	}
+	pushes := fake.Pushes()
+	if len(pushes) != 1 || pushes[0].To != "G1" || pushes[0].RetryKey != "key-1" {
+		t.Fatalf("unexpected pushes: %+v", pushes)
+	}
+
+	if err := lc.Reply(ctx, "token-1", lineTextMessage("a")); err != nil {
+		t.Fatalf("reply: %v", err)
+	}
+	err := lc.Reply(ctx, "token-1", lineTextMessage("b"))
+	var apiErr *lineAPIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a used reply token, got %v", err)
+	}
+}
+
+func TestLineClientGroupSummary(t *testing.T) {
+	fake := linefake.NewServer("test-token")
+	defer fake.Close()
+	fake.SetGroupSummary(linefake.GroupSummary{GroupID: "G1", GroupName: "わが家"})
+	ctx := context.Background()
+
+	summary, err := NewLineClient(fake.URL(), "test-token", nil).GroupSummary(ctx, "G1")
+	if err != nil {
+		t.Fatalf("GroupSummary returned error: %v", err)
+	}
+	if summary.GroupName != "わが家" {
+		t.Fatalf("expected group name わが家, got %q", summary.GroupName)
+	}
+	if _, err := NewLineClient(fake.URL(), "wrong-token", nil).GroupSummary(ctx, "G1"); err == nil {
+		t.Fatalf("expected error with a wrong token")
+	}
+}
+
+// replayLineFixture testdata のWebhook本文をキューと同じ経路で処理し、偽サーバーが受けた返信を返す
+func replayLineFixture(t *testing.T, sv *service.Service, fake *linefake.Server, name string) []linefake.Sent {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("..", "..", "testdata", name))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	var payload lineWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("decode fixture: %v", err)
+	}
+	lc := NewLineClient(fake.URL(), "test-token", nil)
+	for i, e := range payload.Events {
+		b, err := json.Marshal(e)
+		if err != nil {
+			t.Fatalf("encode event: %v", err)
+		}
+		job := repo.WebhookJob{ID: int64(i + 1), EventID: e.WebhookEventID, Destination: payload.Destination, Payload: b, Attempts: 1}
+		if err := runWebhookJob(context.Background(), sv, lc, 0, job); err != nil {
+			t.Fatalf("event %d: %v", i, err)
+		}
+	}
+	return fake.Replies()
+}
+
+func TestLineWebhookFixtureReplies(t *testing.T) {
+	tests := []struct {
+		fixture string
+		replies string // 期待する返信（testdata のファイル。吹き出しは ---、返信どうしは === で区切る。空なら返信しない）
+		upsert  bool
+	}{
+		// グループでメンションされていない発言には反応しない
+		{fixture: "line-help-event.json"},
+		{fixture: "line-help-mention-event.json", replies: "line-help-mention-event.replies.txt", upsert: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock.New failed: %v", err)
+			}
+			defer db.Close()
+			fake := linefake.NewServer("test-token")
+			defer fake.Close()
+			fake.SetProfile("Uyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyy", "Alice")
+
+			if tt.upsert {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO houses(ext_group_id)")).
+					WithArgs("Gxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "inserted"}).AddRow(1, false))
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO users(ext_user_id, display_name)")).
+					WithArgs("Uyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyy", "Alice").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO memberships(house_id,user_id)")).
+					WithArgs(int64(1), int64(2)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			sent := replayLineFixture(t, service.New(repo.New(db)), fake, tt.fixture)
+
+			var got []string
+			for _, s := range sent {
+				got = append(got, strings.Join(s.Texts(), "\n---\n"))
+			}
+			want := ""
+			if tt.replies != "" {
+				b, err := os.ReadFile(filepath.Join("..", "..", "testdata", tt.replies))
+				if err != nil {
+					t.Fatalf("read replies: %v", err)
+				}
+				want = strings.TrimRight(string(b), "\n")
+			}
+			if joined := strings.Join(got, "\n===\n"); joined != want {
+				t.Fatalf("unexpected replies:\n%s\nwant:\n%s", joined, want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestReplyFallbackPushes(t *testing.T) {
+	src := lineSource{Type: "group", GroupID: "G1", UserID: "U1"}
+	tests := []struct {
+		name      string
+		timestamp time.Time
+		usedToken bool
+		reserved  bool
+		wantErr   error
+		wantPush  bool
+	}{
+		{name: "reply succeeds", timestamp: time.Now()},
+		{name: "rejected token", timestamp: time.Now(), usedToken: true, reserved: true, wantPush: true},
+		{name: "deadline passed", timestamp: time.Now().Add(-2 * lineReplyDeadline), reserved: true, wantPush: true},
+		{name: "budget exhausted", timestamp: time.Now(), usedToken: true, wantErr: errPushBudgetExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock.New failed: %v", err)
+			}
+			defer db.Close()
+			fake := linefake.NewServer("test-token")
+			defer fake.Close()
+			base := NewLineClient(fake.URL(), "test-token", nil)
+			ctx := context.Background()
+			if tt.usedToken {
+				// 先に使ったトークンは偽サーバーが400（Invalid reply token）で断る
+				if err := base.Reply(ctx, "token-1", lineTextMessage("先の返信")); err != nil {
+					t.Fatalf("reply: %v", err)
+				}
+			}
+			if tt.usedToken || tt.reserved {
+				rows := sqlmock.NewRows([]string{"messages"})
+				if tt.reserved {
+					rows.AddRow(1)
+				}
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO line_push_usage(month, messages)")).
+					WithArgs(1, 10).
+					WillReturnRows(rows)
+			}
+
+			e := lineEvent{Source: src, Timestamp: tt.timestamp.UnixMilli(), WebhookEventID: "ev-1"}
+			lc := withReplyFallback(base, repo.New(db), 10, e)
+			err = lc.Reply(ctx, "token-1", lineTextMessage("記録したよ"))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			pushes := fake.Pushes()
+			if tt.wantPush {
+				if len(pushes) != 1 || pushes[0].To != "G1" || pushes[0].RetryKey == "" || pushes[0].Texts()[0] != "記録したよ" {
+					t.Fatalf("unexpected pushes: %+v", pushes)
+				}
+			} else if len(pushes) != 0 {
+				t.Fatalf("expected no push, got %+v", pushes)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestRunWebhookJobRetriesCommandFailure(t *testing.T) {
+	payload := []byte(`{"type":"message","replyToken":"token-1",
+		"source":{"type":"group","groupId":"G1","userId":"U1"},
+		"message":{"id":"m1","type":"text","text":"@bot 予定","mention":{"mentionees":[{"userId":"Ubot"}]}}}`)
+	for _, tt := range []struct {
+		name      string
+		attempts  int
+		wantErr   bool
+		wantReply bool
+	}{
+		{name: "retry left", attempts: 1, wantErr: true},
+		{name: "last attempt", attempts: webhookMaxAttempts, wantReply: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// 期待を登録しないので、DBへの問い合わせはすべて失敗する
+			db, _, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock.New failed: %v", err)
+			}
+			defer db.Close()
+			fake := linefake.NewServer("test-token")
+			defer fake.Close()
+
+			job := repo.WebhookJob{ID: 1, Destination: "Ubot", Payload: payload, Attempts: tt.attempts}
+			err = runWebhookJob(context.Background(), service.New(repo.New(db)), NewLineClient(fake.URL(), "test-token", nil), 0, job)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error=%v, got %v", tt.wantErr, err)
+			}
+			replies := fake.Replies()
+			if !tt.wantReply {
+				if len(replies) != 0 {
+					t.Fatalf("expected no reply before the last attempt, got %+v", replies)
+				}
+				return
+			}
+			if len(replies) != 1 || !strings.Contains(replies[0].Texts()[0], "少し待って") {
+				t.Fatalf("unexpected replies: %+v", replies)
+			}
+		})
+	}
+}
+
+func TestReplyFallbackCountsRecipients(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+	fake := linefake.NewServer("test-token")
+	defer fake.Close()
+	fake.SetMemberCount("C1", 3)
+	base := NewLineClient(fake.URL(), "test-token", nil)
+	ctx := context.Background()
+
+	// 予算は吹き出しの数ではなくグループの人数で使い、
+	// 同じイベントの再試行が受け付け済み（409）になったら予約を戻す
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO line_push_usage(month, messages)")).
+		WithArgs(3, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"messages"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO line_push_usage(month, messages)")).
+		WithArgs(3, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"messages"}).AddRow(6))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO line_push_usage(month, messages) VALUES")).
+		WithArgs(-3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	e := lineEvent{Source: lineSource{Type: "group", GroupID: "C1", UserID: "U1"}, Timestamp: time.Now().Add(-2 * lineReplyDeadline).UnixMilli(), WebhookEventID: "ev-1"}
+	for i := 0; i < 2; i++ {
+		lc := withReplyFallback(base, repo.New(db), 10, e)
+		if err := lc.Reply(ctx, "token-1", lineTextMessage("記録したよ"), lineTextMessage("バッジ獲得")); err != nil {
+			t.Fatalf("attempt %d: %v", i, err)
+		}
+	}
+	if pushes := fake.Pushes(); len(pushes) != 1 || pushes[0].To != "C1" {
+		t.Fatalf("unexpected pushes: %+v", pushes)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
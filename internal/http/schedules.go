@@ -0,0 +1,183 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"chores_contributor/internal/repo"
+	"chores_contributor/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type scheduleResp struct {
+	ID           int64      `json:"id"`
+	Task         string     `json:"task"`
+	Rule         string     `json:"rule"`
+	IntervalDays int        `json:"interval_days,omitempty"`
+	Weekday      string     `json:"weekday,omitempty"`
+	Assignee     string     `json:"assignee,omitempty"`
+	AssigneeName string     `json:"assignee_name,omitempty"`
+	StartDate    string     `json:"start_date"`
+	LastDoneAt   *time.Time `json:"last_done_at,omitempty"`
+	NextDue      string     `json:"next_due"`
+	Overdue      bool       `json:"overdue"`
+}
+
+func toScheduleResp(sc service.Schedule) scheduleResp {
+	out := scheduleResp{
+		ID:           sc.ID,
+		Task:         sc.TaskKey,
+		Rule:         sc.Rule,
+		Weekday:      sc.Weekday,
+		Assignee:     sc.AssigneeID,
+		AssigneeName: sc.AssigneeName,
+		StartDate:    sc.StartDate.Format("2006-01-02"),
+		LastDoneAt:   sc.LastDoneAt,
+		NextDue:      sc.NextDue.Format("2006-01-02"),
+		Overdue:      sc.Overdue,
+	}
+	if sc.Rule == service.ScheduleEvery {
+		out.IntervalDays = sc.IntervalDays
+	}
+	return out
+}
+
+// writeScheduleErr 定期家事の操作のエラーをHTTPステータスに変換する
+func writeScheduleErr(w http.ResponseWriter, group string, err error) {
+	var amb *service.TaskAmbiguousError
+	switch {
+	case errors.Is(err, service.ErrInvalidSchedule):
+		writeErr(w, 400, err.Error())
+	case errors.Is(err, service.ErrTaskNotFound), errors.Is(err, repo.ErrNoTaskFound):
+		writeErr(w, 400, "unknown task")
+	case errors.As(err, &amb):
+		writeErr(w, 400, "ambiguous task: "+strings.Join(amb.Candidates, ", "))
+	case errors.Is(err, repo.ErrNoScheduleFound):
+		writeErr(w, 404, "schedule not found")
+	default:
+		log.Printf("schedule error: group=%s err=%v", group, err)
+		writeErr(w, 500, "internal error")
+	}
+}
+
+// mountScheduleRoutes 定期家事の登録・一覧API
+func mountScheduleRoutes(r chi.Router, sv *service.Service) {
+	// GET /houses/{group}/schedules → 期日の近い順
+	r.Get("/houses/{group}/schedules", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		schedules, err := sv.ListSchedules(r.Context(), group)
+		if err != nil {
+			writeScheduleErr(w, group, err)
+			return
+		}
+		out := make([]scheduleResp, 0, len(schedules))
+		for _, sc := range schedules {
+			out = append(out, toScheduleResp(sc))
+		}
+		writeJSON(w, 200, map[string]any{"schedules": out})
+	})
+
+	// POST /houses/{group}/schedules
+	// { "task": "ゴミ出し", "rule": "weekly", "weekday": "tuesday", "assignee": "U123" }
+	// { "task": "風呂掃除", "rule": "every", "interval_days": 3 }
+	r.Post("/houses/{group}/schedules", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		var in service.ScheduleInput
+		if !decodeJSON(w, r, &in) {
+			return
+		}
+		sc, err := sv.CreateSchedule(r.Context(), group, in)
+		if err != nil {
+			writeScheduleErr(w, group, err)
+			return
+		}
+		writeJSON(w, 201, toScheduleResp(sc))
+	})
+
+	// DELETE /houses/{group}/schedules/{id}
+	r.Delete("/houses/{group}/schedules/{id}", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil || id <= 0 {
+			writeErr(w, 400, "invalid schedule id")
+			return
+		}
+		if err := sv.DeleteSchedule(r.Context(), group, id); err != nil {
+			writeScheduleErr(w, group, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+var japaneseWeekdays = [...]string{"日", "月", "火", "水", "木", "金", "土"}
+
+// formatScheduleDate 日付の表記（例: 11/18(火)）
+func formatScheduleDate(d time.Time) string {
+	return fmt.Sprintf("%s(%s)", d.Format("1/2"), japaneseWeekdays[d.Weekday()])
+}
+
+// formatScheduleEntry LINE返信用の定期家事の表記（例: ・ゴミ出し 明日 担当: Bob）
+func formatScheduleEntry(sc service.Schedule) string {
+	var due string
+	switch {
+	case sc.Overdue:
+		due = fmt.Sprintf("期限切れ（%sまで）", formatScheduleDate(sc.NextDue))
+	case sc.DaysLeft == 0:
+		due = "今日"
+	case sc.DaysLeft == 1:
+		due = "明日"
+	default:
+		due = formatScheduleDate(sc.NextDue)
+	}
+	line := fmt.Sprintf("・%s %s", sc.TaskKey, due)
+	if sc.AssigneeName != "" {
+		line += " 担当: " + sc.AssigneeName
+	}
+	return line
+}
+
+// lineScheduleReply "@bot 予定" の返信文（期限切れと今後の予定）
+func lineScheduleReply(ctx context.Context, sv *service.Service, groupID string) string {
+	schedules, err := sv.ListSchedules(ctx, groupID)
+	if err != nil {
+		log.Printf("LINE schedule error: group=%s err=%v", groupID, err)
+		noteLineFailure(ctx, err)
+		return "取得失敗: 少し待ってから試してね"
+	}
+	return formatScheduleList(schedules)
+}
+
+func formatScheduleList(schedules []service.Schedule) string {
+	if len(schedules) == 0 {
+		return "定期の家事はまだ登録されていないよ。"
+	}
+	lines := make([]string, 0, len(schedules)+1)
+	lines = append(lines, "家事の予定:")
+	for _, sc := range schedules {
+		lines = append(lines, formatScheduleEntry(sc))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func pushOverdueReminder(ctx context.Context, lc LineClient, reminder service.OverdueReminder, retryKey string) (bool, error) {
+	return lc.Push(ctx, reminder.GroupID, retryKey, lineTextMessage(formatOverdueReminder(reminder)))
+}
+
+// formatOverdueReminder 期限切れ通知のプッシュ本文
+func formatOverdueReminder(reminder service.OverdueReminder) string {
+	lines := make([]string, 0, len(reminder.Schedules)+2)
+	lines = append(lines, "期限が過ぎた家事があるよ:")
+	for _, sc := range reminder.Schedules {
+		lines = append(lines, formatScheduleEntry(sc))
+	}
+	lines = append(lines, "終わったら「@bot タスク名」で報告してね。")
+	return strings.Join(lines, "\n")
+}
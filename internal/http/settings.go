@@ -0,0 +1,100 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"chores_contributor/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// mountSettingsRoutes ハウス設定のAPI
+func mountSettingsRoutes(r chi.Router, sv *service.Service) {
+	// GET /houses/{group}/settings
+	r.Get("/houses/{group}/settings", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		settings, err := sv.HouseSettings(r.Context(), group)
+		if err != nil {
+			log.Printf("settings fetch error: group=%s err=%v", group, err)
+			writeErr(w, 500, "internal error")
+			return
+		}
+		writeJSON(w, 200, settings)
+	})
+
+	// PATCH /houses/{group}/settings
+	// { "report_reply": "corrected" }
+	r.Patch("/houses/{group}/settings", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		var patch service.HouseSettingsPatch
+		if !decodeJSON(w, r, &patch) {
+			return
+		}
+		settings, err := sv.UpdateHouseSettings(r.Context(), group, patch)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidSettings) {
+				writeErr(w, 400, err.Error())
+				return
+			}
+			log.Printf("settings update error: group=%s err=%v", group, err)
+			writeErr(w, 500, "internal error")
+			return
+		}
+		writeJSON(w, 200, settings)
+	})
+}
+
+var reportReplyLabels = map[string]string{
+	service.ReportReplyAlways:    "常に",
+	service.ReportReplyCorrected: "補正時",
+	service.ReportReplySilent:    "なし",
+}
+
+// parseReportReplyMode "常に" "補正時" "なし"（または always/corrected/silent）を設定値に変換する
+func parseReportReplyMode(s string) (string, bool) {
+	switch s {
+	case "常に", "いつも", service.ReportReplyAlways:
+		return service.ReportReplyAlways, true
+	case "補正時", "補正", service.ReportReplyCorrected:
+		return service.ReportReplyCorrected, true
+	case "なし", "オフ", "off", service.ReportReplySilent:
+		return service.ReportReplySilent, true
+	}
+	return "", false
+}
+
+// lineReportReplySettingReply "@bot 返信 [常に|補正時|なし]" を実行し、返信文を返す
+func lineReportReplySettingReply(ctx context.Context, sv *service.Service, groupID, userID string, args []string) string {
+	if len(args) == 0 {
+		settings, err := sv.HouseSettings(ctx, groupID)
+		if err != nil {
+			log.Printf("LINE settings fetch error: group=%s err=%v", groupID, err)
+			noteLineFailure(ctx, err)
+			return "取得失敗: 少し待ってね"
+		}
+		return "報告への返信: " + reportReplyLabels[settings.ReportReply] + "\n変更: @bot 返信 常に / 補正時 / なし（管理者）"
+	}
+
+	mode, ok := parseReportReplyMode(args[0])
+	if !ok {
+		return "使い方: @bot 返信 常に / 補正時 / なし"
+	}
+	if err := sv.RequireHouseAdmin(ctx, groupID, userID); err != nil {
+		if errors.Is(err, service.ErrNotHouseAdmin) {
+			return "権限なし: 設定の変更はハウス管理者だけができるよ。"
+		}
+		log.Printf("LINE settings admin check error: group=%s user=%s err=%v", groupID, userID, err)
+		noteLineFailure(ctx, err)
+		return "失敗: 少し待ってから試してね"
+	}
+	settings, err := sv.UpdateHouseSettings(ctx, groupID, service.HouseSettingsPatch{ReportReply: &mode})
+	if err != nil {
+		log.Printf("LINE settings update error: group=%s err=%v", groupID, err)
+		noteLineFailure(ctx, err)
+		return "失敗: 少し待ってから試してね"
+	}
+	return "報告への返信を「" + reportReplyLabels[settings.ReportReply] + "」にしたよ。"
+}
@@ -0,0 +1,241 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"chores_contributor/internal/flex"
+	"chores_contributor/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type summaryTaskResp struct {
+	Task   string  `json:"task"`
+	Points float64 `json:"points"`
+	Count  int     `json:"count"`
+}
+
+type summaryUserResp struct {
+	UserID string            `json:"user_id"`
+	Name   string            `json:"name"`
+	Points float64           `json:"points"`
+	Count  int               `json:"count"`
+	Tasks  []summaryTaskResp `json:"tasks"`
+}
+
+type summaryBucketUserResp struct {
+	UserID string  `json:"user_id"`
+	Points float64 `json:"points"`
+}
+
+type summaryBucketResp struct {
+	Start  string                  `json:"start"`
+	End    string                  `json:"end"`
+	Points float64                 `json:"points"`
+	Users  []summaryBucketUserResp `json:"users"`
+}
+
+type summaryResp struct {
+	From        string              `json:"from"`
+	To          string              `json:"to"`
+	Granularity string              `json:"granularity"`
+	Total       float64             `json:"total"`
+	Users       []summaryUserResp   `json:"users"`
+	Tasks       []summaryTaskResp   `json:"tasks"`
+	Buckets     []summaryBucketResp `json:"buckets"`
+}
+
+func toSummaryTasks(tasks []service.SummaryTask) []summaryTaskResp {
+	out := make([]summaryTaskResp, 0, len(tasks))
+	for _, t := range tasks {
+		out = append(out, summaryTaskResp{Task: t.TaskKey, Points: t.Points, Count: t.Count})
+	}
+	return out
+}
+
+// toSummaryResp 日付は両端を含む形（to は最終日）で返す
+func toSummaryResp(sum service.Summary) summaryResp {
+	const day = "2006-01-02"
+	out := summaryResp{
+		From:        sum.Start.Format(day),
+		To:          sum.End.AddDate(0, 0, -1).Format(day),
+		Granularity: sum.Granularity,
+		Total:       sum.Total,
+		Users:       make([]summaryUserResp, 0, len(sum.Users)),
+		Tasks:       toSummaryTasks(sum.Tasks),
+		Buckets:     make([]summaryBucketResp, 0, len(sum.Buckets)),
+	}
+	for _, u := range sum.Users {
+		out.Users = append(out.Users, summaryUserResp{
+			UserID: u.UserID,
+			Name:   u.Name,
+			Points: u.Points,
+			Count:  u.Count,
+			Tasks:  toSummaryTasks(u.Tasks),
+		})
+	}
+	for _, b := range sum.Buckets {
+		br := summaryBucketResp{
+			Start:  b.Start.Format(day),
+			End:    b.End.AddDate(0, 0, -1).Format(day),
+			Points: b.Points,
+			Users:  make([]summaryBucketUserResp, 0, len(b.Users)),
+		}
+		for _, u := range b.Users {
+			br.Users = append(br.Users, summaryBucketUserResp{UserID: u.UserID, Points: u.Points})
+		}
+		out.Buckets = append(out.Buckets, br)
+	}
+	return out
+}
+
+// mountSummaryRoutes 任意期間の集計API
+func mountSummaryRoutes(r chi.Router, sv *service.Service) {
+	// GET /houses/{group}/summary?from=2025-11-01&to=2025-11-30&granularity=week[&user=U123]
+	// from/to は両端を含むハウスの日付。両方省略すると今月
+	r.Get("/houses/{group}/summary", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		q := r.URL.Query()
+
+		query := service.SummaryQuery{
+			Granularity: q.Get("granularity"),
+			UserID:      q.Get("user"),
+		}
+		if (q.Get("from") == "") != (q.Get("to") == "") {
+			writeErr(w, 400, "from and to must be given together")
+			return
+		}
+		for name, dst := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+			raw := q.Get(name)
+			if raw == "" {
+				continue
+			}
+			t, err := time.Parse("2006-01-02", raw)
+			if err != nil {
+				writeErr(w, 400, name+" must be YYYY-MM-DD")
+				return
+			}
+			*dst = t
+		}
+
+		sum, err := sv.Summary(r.Context(), group, query)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidSummary) {
+				writeErr(w, 400, err.Error())
+				return
+			}
+			log.Printf("summary error: group=%s err=%v", group, err)
+			writeErr(w, 500, "internal error")
+			return
+		}
+		writeJSON(w, 200, toSummaryResp(sum))
+	})
+}
+
+// linePeriod "@bot me 月" / "@bot top 年" の期間指定（省略時は今週）
+func linePeriod(args []string) (unit, label string, ok bool) {
+	if len(args) == 0 {
+		return service.GranularityWeek, "今週", true
+	}
+	switch strings.TrimPrefix(strings.TrimPrefix(args[0], "今"), "本") {
+	case "日", "day":
+		return service.GranularityDay, "今日", true
+	case "週", "week":
+		return service.GranularityWeek, "今週", true
+	case "月", "month":
+		return service.GranularityMonth, "今月", true
+	case "年", "year":
+		return service.PeriodYear, "今年", true
+	}
+	return "", "", false
+}
+
+const linePeriodUsage = "期間は 日/週/月/年 で指定してね（例: @bot me 月）"
+
+// lineMeReply "@bot me [日|週|月|年]" の返信（内訳のカード）
+func lineMeReply(ctx context.Context, sv *service.Service, groupID, userID string, args []string) lineReplyMessage {
+	unit, label, ok := linePeriod(args)
+	if !ok {
+		return lineTextMessage(linePeriodUsage)
+	}
+	sum, err := sv.PeriodSummary(ctx, groupID, userID, unit, time.Now())
+	if err != nil {
+		log.Printf("LINE summary error: group=%s user=%s error=%v", groupID, userID, err)
+		noteLineFailure(ctx, err)
+		return lineTextMessage("取得失敗: 少し待ってから試してね")
+	}
+	return meSummaryMessage(label, sum)
+}
+
+// meSummaryMessage 内訳のカード（ポイントがなければテキスト）
+func meSummaryMessage(label string, sum service.Summary) lineReplyMessage {
+	if len(sum.Users) == 0 {
+		return lineTextMessage(formatMeSummary(label, sum))
+	}
+	me := sum.Users[0]
+	rows := make([]flex.Row, 0, len(me.Tasks))
+	for _, t := range me.Tasks {
+		rows = append(rows, flex.Row{Name: t.TaskKey, Value: t.Points, Label: formatPoints(t.Points)})
+	}
+	card := flex.BreakdownCard(label+"のポイント", formatPoints(me.Points), rows)
+	return lineFlexMessage(formatMeSummary(label, sum), card)
+}
+
+func formatMeSummary(label string, sum service.Summary) string {
+	if len(sum.Users) == 0 {
+		return fmt.Sprintf("%sのポイントはまだ0ptだよ。", label)
+	}
+	me := sum.Users[0]
+	lines := make([]string, 0, len(me.Tasks)+2)
+	lines = append(lines, fmt.Sprintf("%s: %s", label, formatPoints(me.Points)))
+	lines = append(lines, "内訳:")
+	for _, t := range me.Tasks {
+		lines = append(lines, fmt.Sprintf("・%s %s", t.TaskKey, formatPoints(t.Points)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// lineTopReply "@bot top [日|週|月|年]" の返信（ランキングのカード）
+func lineTopReply(ctx context.Context, sv *service.Service, groupID string, args []string) lineReplyMessage {
+	unit, label, ok := linePeriod(args)
+	if !ok {
+		return lineTextMessage(linePeriodUsage)
+	}
+	sum, err := sv.PeriodSummary(ctx, groupID, "", unit, time.Now())
+	if err != nil {
+		log.Printf("LINE ranking error: group=%s error=%v", groupID, err)
+		noteLineFailure(ctx, err)
+		return lineTextMessage("ランキング取得失敗: 少し待ってね")
+	}
+	return topSummaryMessage(label, sum)
+}
+
+// topSummaryMessage ランキングのカード（誰も報告していなければテキスト）
+func topSummaryMessage(label string, sum service.Summary) lineReplyMessage {
+	if len(sum.Users) == 0 {
+		return lineTextMessage(formatTopSummary(label, sum))
+	}
+	rows := make([]flex.Row, 0, len(sum.Users))
+	for _, u := range sum.Users {
+		rows = append(rows, flex.Row{Name: u.Name, Value: u.Points, Label: formatPoints(u.Points)})
+	}
+	return lineFlexMessage(formatTopSummary(label, sum), flex.RankingCard(label+"のランキング", rows))
+}
+
+func formatTopSummary(label string, sum service.Summary) string {
+	if len(sum.Users) == 0 {
+		return fmt.Sprintf("%sはまだ誰も報告していないみたい。", label)
+	}
+	lines := make([]string, 0, len(sum.Users)+1)
+	lines = append(lines, fmt.Sprintf("%sのポイント:", label))
+	for i, u := range sum.Users {
+		lines = append(lines, fmt.Sprintf("%d位 %s %s", i+1, u.Name, formatPoints(u.Points)))
+	}
+	return strings.Join(lines, "\n")
+}
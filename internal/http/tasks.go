@@ -0,0 +1,388 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"chores_contributor/internal/flex"
+	"chores_contributor/internal/repo"
+	"chores_contributor/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type taskResp struct {
+	ID          int64    `json:"id"`
+	Key         string   `json:"key"`
+	Points      float64  `json:"points"`
+	Scoring     string   `json:"scoring"`
+	UnitMinutes int      `json:"unit_minutes,omitempty"`
+	MaxUnits    int      `json:"max_units,omitempty"`
+	Aliases     []string `json:"aliases"`
+	Archived    bool     `json:"archived"`
+	Rotation    string   `json:"rotation"`
+	Owner       string   `json:"owner,omitempty"`
+}
+
+func toTaskResp(def service.TaskDefinition) taskResp {
+	aliases := def.Aliases
+	if aliases == nil {
+		aliases = []string{}
+	}
+	return taskResp{
+		ID:          def.ID,
+		Key:         def.Key,
+		Points:      def.Points,
+		Scoring:     def.Scoring,
+		UnitMinutes: def.UnitMinutes,
+		MaxUnits:    def.MaxUnits,
+		Aliases:     aliases,
+		Archived:    def.Archived,
+		Rotation:    def.Rotation,
+		Owner:       def.Owner,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// decodeJSON Content-Typeガード付きで厳密にデコードする
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if ct := r.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		writeErr(w, 400, "content-type must be application/json")
+		return false
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeErr(w, 400, "invalid json: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func taskIDParam(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeErr(w, 400, "invalid task id")
+		return 0, false
+	}
+	return id, true
+}
+
+// aliasParam chiはエスケープ済みのパスでマッチするため、別名をデコードして返す
+func aliasParam(r *http.Request) string {
+	raw := chi.URLParam(r, "alias")
+	if decoded, err := url.PathUnescape(raw); err == nil {
+		return decoded
+	}
+	return raw
+}
+
+// writeTaskErr タスク辞書操作のエラーをHTTPステータスに変換する
+func writeTaskErr(w http.ResponseWriter, group string, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidTask):
+		writeErr(w, 400, err.Error())
+	case errors.Is(err, service.ErrTaskConflict):
+		writeErr(w, 409, err.Error())
+	case errors.Is(err, repo.ErrNoTaskFound):
+		writeErr(w, 404, "task not found")
+	case errors.Is(err, repo.ErrNoAliasFound):
+		writeErr(w, 404, "alias not found")
+	default:
+		log.Printf("task catalog error: group=%s err=%v", group, err)
+		writeErr(w, 500, "internal error")
+	}
+}
+
+// mountTaskRoutes ハウスのタスク辞書を管理するAPI
+func mountTaskRoutes(r chi.Router, sv *service.Service) {
+	// GET /houses/{group}/tasks?include_archived=true
+	r.Get("/houses/{group}/tasks", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		includeArchived, _ := strconv.ParseBool(r.URL.Query().Get("include_archived"))
+		defs, err := sv.ListTasks(r.Context(), group, includeArchived)
+		if err != nil {
+			writeTaskErr(w, group, err)
+			return
+		}
+		out := make([]taskResp, 0, len(defs))
+		for _, def := range defs {
+			out = append(out, toTaskResp(def))
+		}
+		writeJSON(w, 200, map[string]any{"tasks": out})
+	})
+
+	// POST /houses/{group}/tasks
+	// { "key": "窓拭き", "points": 150, "aliases": ["まど", "窓"] }
+	r.Post("/houses/{group}/tasks", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		var in service.TaskInput
+		if !decodeJSON(w, r, &in) {
+			return
+		}
+		def, err := sv.CreateTask(r.Context(), group, in)
+		if err != nil {
+			writeTaskErr(w, group, err)
+			return
+		}
+		writeJSON(w, 201, toTaskResp(def))
+	})
+
+	// PATCH /houses/{group}/tasks/{id}
+	// { "points": 200 } / { "key": "トイレ掃除" } / { "archived": false }
+	r.Patch("/houses/{group}/tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		id, ok := taskIDParam(w, r)
+		if !ok {
+			return
+		}
+		var patch service.TaskPatch
+		if !decodeJSON(w, r, &patch) {
+			return
+		}
+		def, err := sv.UpdateTask(r.Context(), group, id, patch)
+		if err != nil {
+			writeTaskErr(w, group, err)
+			return
+		}
+		writeJSON(w, 200, toTaskResp(def))
+	})
+
+	// DELETE /houses/{group}/tasks/{id} → アーカイブ
+	r.Delete("/houses/{group}/tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		id, ok := taskIDParam(w, r)
+		if !ok {
+			return
+		}
+		def, err := sv.ArchiveTask(r.Context(), group, id)
+		if err != nil {
+			writeTaskErr(w, group, err)
+			return
+		}
+		writeJSON(w, 200, toTaskResp(def))
+	})
+
+	// POST /houses/{group}/tasks/{id}/aliases
+	// { "alias": "まど" }
+	r.Post("/houses/{group}/tasks/{id}/aliases", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		id, ok := taskIDParam(w, r)
+		if !ok {
+			return
+		}
+		var in struct {
+			Alias string `json:"alias"`
+		}
+		if !decodeJSON(w, r, &in) {
+			return
+		}
+		def, err := sv.AddTaskAlias(r.Context(), group, id, in.Alias)
+		if err != nil {
+			writeTaskErr(w, group, err)
+			return
+		}
+		writeJSON(w, 201, toTaskResp(def))
+	})
+
+	// DELETE /houses/{group}/tasks/{id}/aliases/{alias}
+	r.Delete("/houses/{group}/tasks/{id}/aliases/{alias}", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		id, ok := taskIDParam(w, r)
+		if !ok {
+			return
+		}
+		def, err := sv.RemoveTaskAlias(r.Context(), group, id, aliasParam(r))
+		if err != nil {
+			writeTaskErr(w, group, err)
+			return
+		}
+		writeJSON(w, 200, toTaskResp(def))
+	})
+
+	// PUT /houses/{group}/aliases/{alias} → 別名の付け替え
+	// { "task_id": 3 }
+	r.Put("/houses/{group}/aliases/{alias}", func(w http.ResponseWriter, r *http.Request) {
+		group := chi.URLParam(r, "group")
+		var in struct {
+			TaskID int64 `json:"task_id"`
+		}
+		if !decodeJSON(w, r, &in) {
+			return
+		}
+		if in.TaskID <= 0 {
+			writeErr(w, 400, "task_id is required")
+			return
+		}
+		def, err := sv.MoveTaskAlias(r.Context(), group, aliasParam(r), in.TaskID)
+		if err != nil {
+			writeTaskErr(w, group, err)
+			return
+		}
+		writeJSON(w, 200, toTaskResp(def))
+	})
+}
+
+// formatTaskRule 採点ルール付きのポイント表記（例: 50pt/10分 上限6）
+func formatTaskRule(def service.TaskDefinition) string {
+	out := formatPoints(def.Points)
+	switch def.Scoring {
+	case service.ScoringPerMinutes:
+		out += fmt.Sprintf("/%d分", def.UnitMinutes)
+	case service.ScoringPerUnit:
+		out += "/回"
+	}
+	if def.MaxUnits > 0 {
+		out += fmt.Sprintf(" 上限%d", def.MaxUnits)
+	}
+	return out
+}
+
+// formatTaskEntry LINE返信用のタスク表記（例: 窓拭き 150pt（別名: まど, 窓））
+func formatTaskEntry(def service.TaskDefinition) string {
+	return fmt.Sprintf("%s %s（別名: %s）", def.Key, formatTaskRule(def), readableAliases(def.Key, def.Aliases))
+}
+
+// formatTaskCatalog "@bot task" のタスク一覧（テキスト版）
+func formatTaskCatalog(defs []service.TaskDefinition) string {
+	lines := make([]string, 0, len(defs)+1)
+	lines = append(lines, "登録タスクとポイント:")
+	for _, def := range defs {
+		lines = append(lines, fmt.Sprintf("・%s: %s", def.Key, formatTaskRule(def)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// taskCatalogMessage "@bot task" の返信（タスクのカルーセル。タスクがなければテキスト）
+func taskCatalogMessage(defs []service.TaskDefinition) lineReplyMessage {
+	if len(defs) == 0 {
+		return lineTextMessage(formatTaskCatalog(defs))
+	}
+	tasks := make([]flex.Task, 0, len(defs))
+	for _, def := range defs {
+		t := flex.Task{Name: def.Key, Rule: formatTaskRule(def)}
+		if aliases := readableAliases(def.Key, def.Aliases); aliases != "―" {
+			t.Aliases = aliases
+		}
+		tasks = append(tasks, t)
+	}
+	return lineFlexMessage(formatTaskCatalog(defs), flex.TaskCarousel("登録タスク", tasks))
+}
+
+// splitAliasList "まど,窓" / "まど、窓" を別名の配列に分解する
+func splitAliasList(s string) []string {
+	parts := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == '、' || r == '，'
+	})
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func parseTaskPoints(s string) (float64, error) {
+	pt, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(s), "pt"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("ポイントは数字で指定してね: %q", s)
+	}
+	return pt, nil
+}
+
+// lineTaskAdminReply "@bot task add|set|rm ..." を実行し、返信文を返す
+func lineTaskAdminReply(ctx context.Context, sv *service.Service, groupID, userID string, args []string) string {
+	const usage = "使い方:\n・@bot task add 窓拭き 150 まど,窓\n・@bot task set 皿洗い 200\n・@bot task rm 窓拭き"
+
+	switch strings.ToLower(args[0]) {
+	case "add", "set", "rm", "del", "delete":
+	default:
+		return usage
+	}
+	if err := sv.RequireHouseAdmin(ctx, groupID, userID); err != nil {
+		if errors.Is(err, service.ErrNotHouseAdmin) {
+			return "権限なし: タスクの変更はハウス管理者だけができるよ。"
+		}
+		log.Printf("LINE task admin check error: group=%s user=%s err=%v", groupID, userID, err)
+		noteLineFailure(ctx, err)
+		return "失敗: 少し待ってから試してね"
+	}
+
+	var (
+		def    service.TaskDefinition
+		err    error
+		prefix string
+	)
+	switch strings.ToLower(args[0]) {
+	case "add":
+		if len(args) < 3 {
+			return usage
+		}
+		pt, perr := parseTaskPoints(args[2])
+		if perr != nil {
+			return perr.Error()
+		}
+		in := service.TaskInput{Key: args[1], Points: pt}
+		if len(args) > 3 {
+			in.Aliases = splitAliasList(strings.Join(args[3:], ","))
+		}
+		def, err = sv.CreateTask(ctx, groupID, in)
+		prefix = "追加したよ"
+	case "set":
+		if len(args) < 3 {
+			return usage
+		}
+		pt, perr := parseTaskPoints(args[2])
+		if perr != nil {
+			return perr.Error()
+		}
+		def, err = sv.FindTask(ctx, groupID, args[1])
+		if err == nil {
+			def, err = sv.UpdateTask(ctx, groupID, def.ID, service.TaskPatch{Points: &pt})
+		}
+		prefix = "更新したよ"
+	case "rm", "del", "delete":
+		if len(args) < 2 {
+			return usage
+		}
+		def, err = sv.FindTask(ctx, groupID, args[1])
+		if err == nil {
+			def, err = sv.ArchiveTask(ctx, groupID, def.ID)
+		}
+		prefix = "削除したよ"
+	default:
+		return usage
+	}
+
+	if err != nil {
+		var conflict *service.TaskConflictError
+		switch {
+		case errors.Is(err, service.ErrTaskNotFound), errors.Is(err, repo.ErrNoTaskFound):
+			return fmt.Sprintf("不明: \"%s\"", args[1])
+		case errors.As(err, &conflict):
+			if conflict.TaskKey != "" {
+				return fmt.Sprintf("重複: \"%s\" は「%s」で使われているよ", conflict.Name, conflict.TaskKey)
+			}
+			return fmt.Sprintf("重複: \"%s\" は登録済みだよ", conflict.Name)
+		case errors.Is(err, service.ErrInvalidTask):
+			return "入力エラー: タスク名とポイント（0以上）を確認してね"
+		}
+		log.Printf("LINE task admin error: group=%s user=%s args=%v err=%v", groupID, userID, args, err)
+		noteLineFailure(ctx, err)
+		return "失敗: 少し待ってから試してね"
+	}
+	return fmt.Sprintf("%s: %s", prefix, formatTaskEntry(def))
+}
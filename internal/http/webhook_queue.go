@@ -0,0 +1,283 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"chores_contributor/internal/repo"
+	"chores_contributor/internal/service"
+)
+
+const (
+	webhookJobTimeout     = 5 * time.Second     // 1イベントの処理時間の上限
+	webhookPollInterval   = time.Second         // 通知がなくても処理待ち（再試行・他インスタンス分）を確認する間隔
+	webhookStaleAfter     = 2 * time.Minute     // running のまま放置された行を取り直すまでの時間
+	webhookMaxAttempts    = 5                   // これを超えて失敗したら dead にする
+	webhookRetryBaseDelay = 2 * time.Second     // 1回目の失敗後の待ち時間（以降は倍々）
+	webhookRetryMaxDelay  = 30 * time.Second    // リプライトークンの期限があるので長くは待たない
+	webhookDedupTTL       = 7 * 24 * time.Hour  // webhookEventIdを覚えておく期間（LINEの再送はこれより短い）
+	webhookPurgeInterval  = time.Hour           // 期限切れのwebhookEventIdと古いイベントを削除する間隔
+	webhookDoneRetention  = 7 * 24 * time.Hour  // 処理済みのイベントを残す期間
+	webhookDeadRetention  = 30 * 24 * time.Hour // deadのイベントを残す期間（原因の調査用に長め）
+)
+
+// errInvalidWebhookJob 保存したイベントが読めない（再試行しても直らないので即 dead）
+var errInvalidWebhookJob = errors.New("invalid webhook job")
+
+// WebhookQueue DBに保存したLINE Webhookのイベントを決まった数のワーカーで処理する
+type WebhookQueue struct {
+	sv      *service.Service
+	lc      LineClient
+	workers int
+	budget  int
+	wake    chan struct{}
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+// NewWebhookQueue workers個のワーカーで処理するキューを作る（Startするまで処理しない）。
+// 返信やプロフィールの取得は lc で行い、返信できなければ月pushBudget通までプッシュで送り直す
+func NewWebhookQueue(sv *service.Service, lc LineClient, workers, pushBudget int) *WebhookQueue {
+	if workers < 1 {
+		workers = 1
+	}
+	return &WebhookQueue{
+		sv:      sv,
+		lc:      lc,
+		workers: workers,
+		budget:  pushBudget,
+		wake:    make(chan struct{}, workers),
+		stop:    make(chan struct{}),
+	}
+}
+
+// Start ワーカーと、期限切れのwebhookEventIdと保存期間を過ぎたイベントを削除するゴルーチンを起動する
+func (q *WebhookQueue) Start() {
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	q.wg.Add(1)
+	go q.purge()
+}
+
+// Enqueue 署名を確認したイベントを保存する。受け付け済みのwebhookEventId（再送）は保存しない
+func (q *WebhookQueue) Enqueue(ctx context.Context, destination string, events []lineEvent) error {
+	jobs := make([]repo.WebhookJobParams, 0, len(events))
+	for _, e := range events {
+		if !isQueuedLineEvent(e) {
+			continue
+		}
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, repo.WebhookJobParams{EventID: e.WebhookEventID, Payload: b})
+	}
+	queued, err := q.sv.Rp().EnqueueWebhookJobs(ctx, destination, jobs, webhookDedupTTL)
+	if err != nil {
+		return err
+	}
+	if skipped := len(jobs) - queued; skipped > 0 {
+		log.Printf("LINE webhook duplicates skipped: %d", skipped)
+	}
+	if queued > 0 {
+		q.notify()
+	}
+	return nil
+}
+
+// notify 新しいイベントを保存したことをワーカーに知らせる（待っているワーカーがいなければ何もしない）
+func (q *WebhookQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Shutdown 新しいイベントの取り出しをやめ、処理中のイベントが終わるのをctxの期限まで待つ。
+// 取り出していないイベントはDBに残り、次に起動したときに処理する
+func (q *WebhookQueue) Shutdown(ctx context.Context) error {
+	q.once.Do(func() { close(q.stop) })
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *WebhookQueue) purge() {
+	defer q.wg.Done()
+	ticker := time.NewTicker(webhookPurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), webhookJobTimeout)
+		if _, err := q.sv.Rp().PurgeWebhookEventIDs(ctx); err != nil {
+			log.Printf("webhook event id purge error: %v", err)
+		}
+		if n, err := q.sv.Rp().PurgeWebhookJobs(ctx, webhookDoneRetention, webhookDeadRetention); err != nil {
+			log.Printf("webhook job purge error: %v", err)
+		} else if n > 0 {
+			log.Printf("webhook jobs purged: %d", n)
+		}
+		cancel()
+	}
+}
+
+func (q *WebhookQueue) work() {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.stop:
+			return
+		default:
+		}
+		claimed, err := q.processNext()
+		if err != nil {
+			log.Printf("webhook queue error: %v", err)
+		}
+		if claimed && err == nil {
+			continue
+		}
+		select {
+		case <-q.stop:
+			return
+		case <-q.wake:
+		case <-time.After(webhookPollInterval):
+		}
+	}
+}
+
+// processNext 処理待ちのイベントを1件処理する（なければ false）
+func (q *WebhookQueue) processNext() (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookJobTimeout)
+	defer cancel()
+	job, ok, err := q.sv.Rp().ClaimWebhookJob(ctx, webhookStaleAfter)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	runErr := runWebhookJob(ctx, q.sv, q.lc, q.budget, job)
+	// 処理がタイムアウトしても結果は記録する
+	ctx, cancel = context.WithTimeout(context.Background(), webhookJobTimeout)
+	defer cancel()
+	switch {
+	case runErr == nil:
+		err = q.sv.Rp().CompleteWebhookJob(ctx, job.ID)
+	case errors.Is(runErr, errInvalidWebhookJob) || job.Attempts >= webhookMaxAttempts:
+		log.Printf("webhook job dead-lettered: id=%d event_id=%s attempts=%d err=%v", job.ID, job.EventID, job.Attempts, runErr)
+		err = q.sv.Rp().DeadLetterWebhookJob(ctx, job.ID, runErr)
+	default:
+		delay := webhookRetryDelay(job.Attempts)
+		log.Printf("webhook job retry: id=%d event_id=%s attempts=%d delay=%s err=%v", job.ID, job.EventID, job.Attempts, delay, runErr)
+		err = q.sv.Rp().RetryWebhookJob(ctx, job.ID, time.Now().Add(delay), runErr)
+	}
+	return true, err
+}
+
+// webhookRetryDelay attempts回目の失敗のあと再試行するまでの待ち時間
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBaseDelay
+	for i := 1; i < attempts && delay < webhookRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > webhookRetryMaxDelay {
+		delay = webhookRetryMaxDelay
+	}
+	return delay
+}
+
+// runWebhookJob 保存したイベントを処理する。パニックも失敗として再試行に回す。
+// pushBudgetは返信できなかったときにプッシュで送り直す月の上限（0以下なら送り直さない）
+func runWebhookJob(ctx context.Context, sv *service.Service, lc LineClient, pushBudget int, job repo.WebhookJob) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	var e lineEvent
+	if err := json.Unmarshal(job.Payload, &e); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidWebhookJob, err)
+	}
+	if !isQueuedLineEvent(e) {
+		return fmt.Errorf("%w: unsupported event type %q", errInvalidWebhookJob, e.Type)
+	}
+	if job.Attempts < webhookMaxAttempts {
+		ctx = withRetry(ctx)
+	}
+	ctx = withFailureNote(ctx)
+	lc = withReplyFallback(lc, sv.Rp(), pushBudget, e)
+	return handleLineEvent(ctx, sv, lc, job.Destination, e)
+}
+
+// isQueuedLineEvent 保存して処理するイベント（テキストメッセージとpostback）か
+func isQueuedLineEvent(e lineEvent) bool {
+	return (e.Type == "message" && e.Message.Type == "text") || (e.Type == "postback" && e.Postback != nil)
+}
+
+// handleLineEvent イベントの種類ごとの処理に振り分ける
+func handleLineEvent(ctx context.Context, sv *service.Service, lc LineClient, botID string, e lineEvent) error {
+	if e.Type == "postback" {
+		return handleLinePostback(ctx, sv, lc, e)
+	}
+	return handleLineMessage(ctx, sv, lc, botID, e)
+}
+
+type retryKey struct{}
+
+// withRetry 失敗したらキューが再試行することを示す（最後の試行では付けない）
+func withRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryKey{}, true)
+}
+
+// willRetry 一時的なエラーを返信せずに返してよいか（キューが再試行する）
+func willRetry(ctx context.Context) bool {
+	retry, _ := ctx.Value(retryKey{}).(bool)
+	return retry
+}
+
+type failureKey struct{}
+
+// lineFailure コマンドの処理中に起きた一時的なエラー（最初の1件）
+type lineFailure struct{ err error }
+
+// withFailureNote コマンドの一時的なエラーを記録できるようにする
+func withFailureNote(ctx context.Context) context.Context {
+	return context.WithValue(ctx, failureKey{}, &lineFailure{})
+}
+
+// noteLineFailure 一時的なエラーを記録する。返信を組み立てたあとで再試行に回すかを決めるのに使う
+func noteLineFailure(ctx context.Context, err error) {
+	if f, ok := ctx.Value(failureKey{}).(*lineFailure); ok && f.err == nil {
+		f.err = err
+	}
+}
+
+// retryableFailure 記録した一時的なエラー。キューが再試行しないならnil（失敗の返信を送る）
+func retryableFailure(ctx context.Context) error {
+	if !willRetry(ctx) {
+		return nil
+	}
+	f, _ := ctx.Value(failureKey{}).(*lineFailure)
+	if f == nil {
+		return nil
+	}
+	return f.err
+}
@@ -0,0 +1,282 @@
+// Package linefake テスト用の偽LINE Messaging APIサーバー。
+// 返信とプッシュを送られた順に記録し、プロフィール・グループ概要・メンバー数は登録した内容を返す
+package linefake
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// 記録した送信の種類
+const (
+	OpReply = "reply"
+	OpPush  = "push"
+)
+
+type Message struct {
+	Type       string          `json:"type"`
+	Text       string          `json:"text,omitempty"`
+	AltText    string          `json:"altText,omitempty"`
+	Contents   json.RawMessage `json:"contents,omitempty"`
+	QuickReply *QuickReply     `json:"quickReply,omitempty"`
+}
+
+type QuickReply struct {
+	Items []QuickReplyItem `json:"items"`
+}
+
+type QuickReplyItem struct {
+	Type   string `json:"type"`
+	Action Action `json:"action"`
+}
+
+type Action struct {
+	Type        string `json:"type"`
+	Label       string `json:"label"`
+	Data        string `json:"data,omitempty"`
+	DisplayText string `json:"displayText,omitempty"`
+}
+
+// Sent 受け付けた返信/プッシュ。返信ならReplyToken、プッシュならToとRetryKeyが入る
+type Sent struct {
+	Op         string
+	ReplyToken string
+	To         string
+	RetryKey   string
+	Messages   []Message
+}
+
+// Texts 送ったメッセージの本文（テキスト以外はaltText）
+func (s Sent) Texts() []string {
+	out := make([]string, 0, len(s.Messages))
+	for _, m := range s.Messages {
+		if m.Type == "text" {
+			out = append(out, m.Text)
+		} else {
+			out = append(out, m.AltText)
+		}
+	}
+	return out
+}
+
+type GroupSummary struct {
+	GroupID    string `json:"groupId"`
+	GroupName  string `json:"groupName"`
+	PictureURL string `json:"pictureUrl,omitempty"`
+}
+
+// Server httptestで動く偽サーバー。使い終わったらCloseする
+type Server struct {
+	srv   *httptest.Server
+	token string
+
+	mu         sync.Mutex
+	profiles   map[string]string
+	groups     map[string]GroupSummary
+	members    map[string]int
+	usedTokens map[string]bool
+	retryKeys  map[string]bool
+	sent       []Sent
+}
+
+// NewServer tokenで認証する偽サーバーを起動する
+func NewServer(token string) *Server {
+	s := &Server{
+		token:      token,
+		profiles:   map[string]string{},
+		groups:     map[string]GroupSummary{},
+		members:    map[string]int{},
+		usedTokens: map[string]bool{},
+		retryKeys:  map[string]bool{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v2/bot/message/reply", s.handleReply)
+	mux.HandleFunc("POST /v2/bot/message/push", s.handlePush)
+	mux.HandleFunc("GET /v2/bot/profile/{user}", s.handleProfile)
+	mux.HandleFunc("GET /v2/bot/group/{group}/member/{user}", s.handleProfile)
+	mux.HandleFunc("GET /v2/bot/room/{room}/member/{user}", s.handleProfile)
+	mux.HandleFunc("GET /v2/bot/group/{group}/summary", s.handleGroupSummary)
+	mux.HandleFunc("GET /v2/bot/group/{id}/members/count", s.handleMemberCount)
+	mux.HandleFunc("GET /v2/bot/room/{id}/members/count", s.handleMemberCount)
+	s.srv = httptest.NewServer(s.auth(mux))
+	return s
+}
+
+// URL クライアントのbaseURLに渡すURL（本番の https://api.line.me/v2/bot に当たる）
+func (s *Server) URL() string { return s.srv.URL + "/v2/bot" }
+
+func (s *Server) Close() { s.srv.Close() }
+
+// SetProfile プロフィール取得で返す表示名を登録する（未登録のユーザーは404）
+func (s *Server) SetProfile(userID, displayName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profiles[userID] = displayName
+}
+
+// SetGroupSummary グループ概要を登録する（未登録のグループは404）
+func (s *Server) SetGroupSummary(summary GroupSummary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.groups[summary.GroupID] = summary
+}
+
+// SetMemberCount グループ/ルームのメンバー数を登録する（未登録のグループ/ルームは404）
+func (s *Server) SetMemberCount(id string, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.members[id] = count
+}
+
+// Sent 受け付けた返信/プッシュを送られた順に返す
+func (s *Server) Sent() []Sent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Sent(nil), s.sent...)
+}
+
+// Replies 受け付けた返信だけを返す
+func (s *Server) Replies() []Sent { return s.filter(OpReply) }
+
+// Pushes 受け付けたプッシュだけを返す
+func (s *Server) Pushes() []Sent { return s.filter(OpPush) }
+
+func (s *Server) filter(op string) []Sent {
+	var out []Sent
+	for _, sent := range s.Sent() {
+		if sent.Op == op {
+			out = append(out, sent)
+		}
+	}
+	return out
+}
+
+// Reset 記録した送信と使用済みのリプライトークンを消す（登録したプロフィールは残す）
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = nil
+	s.usedTokens = map[string]bool{}
+	s.retryKeys = map[string]bool{}
+}
+
+func (s *Server) auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+s.token {
+			writeError(w, http.StatusUnauthorized, "Authentication failed")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleReply 本番と同じく、リプライトークンは1回しか使えない
+func (s *Server) handleReply(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ReplyToken string    `json:"replyToken"`
+		Messages   []Message `json:"messages"`
+	}
+	if !decodeMessages(w, r, &req, func() []Message { return req.Messages }) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if req.ReplyToken == "" || s.usedTokens[req.ReplyToken] {
+		writeError(w, http.StatusBadRequest, "Invalid reply token")
+		return
+	}
+	s.usedTokens[req.ReplyToken] = true
+	s.sent = append(s.sent, Sent{Op: OpReply, ReplyToken: req.ReplyToken, Messages: req.Messages})
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+// handlePush 本番と同じく、受け付け済みのX-Line-Retry-Keyは409を返して記録しない
+func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		To       string    `json:"to"`
+		Messages []Message `json:"messages"`
+	}
+	if !decodeMessages(w, r, &req, func() []Message { return req.Messages }) {
+		return
+	}
+	if req.To == "" {
+		writeError(w, http.StatusBadRequest, "The property, 'to', in the request body is invalid")
+		return
+	}
+	retryKey := r.Header.Get("X-Line-Retry-Key")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if retryKey != "" {
+		if s.retryKeys[retryKey] {
+			writeError(w, http.StatusConflict, "The retry key is already accepted")
+			return
+		}
+		s.retryKeys[retryKey] = true
+	}
+	s.sent = append(s.sent, Sent{Op: OpPush, To: req.To, RetryKey: retryKey, Messages: req.Messages})
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+func (s *Server) handleProfile(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("user")
+	s.mu.Lock()
+	name, ok := s.profiles[userID]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"userId": userID, "displayName": name})
+}
+
+func (s *Server) handleGroupSummary(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	summary, ok := s.groups[r.PathValue("group")]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+func (s *Server) handleMemberCount(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	count, ok := s.members[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"count": count})
+}
+
+// decodeMessages 本文を読み、メッセージが1〜5件でなければ400を返す
+func decodeMessages(w http.ResponseWriter, r *http.Request, req any, messages func() []Message) bool {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		writeError(w, http.StatusBadRequest, "Content-Type must be application/json")
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		writeError(w, http.StatusBadRequest, "The request body has 1 error(s)")
+		return false
+	}
+	if n := len(messages()); n == 0 || n > 5 {
+		writeError(w, http.StatusBadRequest, "The property, 'messages', in the request body is invalid")
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"message": msg})
+}
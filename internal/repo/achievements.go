@@ -0,0 +1,148 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// AchievementRow 獲得したバッジ。TaskKey/Threshold/PeriodStartは種類によって空
+type AchievementRow struct {
+	ID          int64
+	Kind        string
+	Code        string
+	TaskKey     string
+	Threshold   int
+	PeriodStart *time.Time // 日付のみ（UTCの0時）
+	EarnedAt    time.Time
+}
+
+// InsertAchievementParams Codeが同じバッジはメンバーごとに1回だけ記録される
+type InsertAchievementParams struct {
+	ExtGroupID  string
+	ExtUserID   string
+	Kind        string
+	Code        string
+	TaskKey     string
+	Threshold   int
+	PeriodStart *time.Time
+}
+
+// TaskCountRow タスクごとの記録の件数
+type TaskCountRow struct {
+	TaskKey string
+	Count   int
+}
+
+// UserActiveDays 記録のある日（ハウスのタイムゾーン、rolloverHourより前は前日）を新しい順に最大limit日返す
+func (r *Repo) UserActiveDays(ctx context.Context, extGroupID, extUserID, tz string, rolloverHour, limit int) ([]time.Time, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT DISTINCT ((e.performed_at AT TIME ZONE $3) - make_interval(hours => $4::int))::date AS day
+FROM events e
+JOIN users u  ON u.id = e.user_id
+JOIN houses h ON h.id = e.house_id
+WHERE h.ext_group_id = $1 AND u.ext_user_id = $2 AND `+countedEvent+`
+ORDER BY day DESC
+LIMIT $5
+`, extGroupID, extUserID, tz, rolloverHour, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []time.Time
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(&day); err != nil {
+			return nil, err
+		}
+		out = append(out, day)
+	}
+	return out, rows.Err()
+}
+
+// UserTaskCounts メンバーのタスクごとの記録の件数（取り消し分・承認待ちは除く）
+func (r *Repo) UserTaskCounts(ctx context.Context, extGroupID, extUserID string) ([]TaskCountRow, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT e.task_key, COUNT(*)
+FROM events e
+JOIN users u  ON u.id = e.user_id
+JOIN houses h ON h.id = e.house_id
+WHERE h.ext_group_id = $1 AND u.ext_user_id = $2 AND `+countedEvent+`
+GROUP BY e.task_key
+ORDER BY e.task_key
+`, extGroupID, extUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []TaskCountRow
+	for rows.Next() {
+		var row TaskCountRow
+		if err := rows.Scan(&row.TaskKey, &row.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+// InsertAchievement バッジを記録する。初めて獲得した場合だけtrueと記録した行を返す
+func (r *Repo) InsertAchievement(ctx context.Context, p InsertAchievementParams) (AchievementRow, bool, error) {
+	row := AchievementRow{
+		Kind:        p.Kind,
+		Code:        p.Code,
+		TaskKey:     p.TaskKey,
+		Threshold:   p.Threshold,
+		PeriodStart: p.PeriodStart,
+	}
+	err := r.db.QueryRowContext(ctx, `
+INSERT INTO achievements(house_id, user_id, kind, code, task_key, threshold, period_start)
+SELECT m.house_id, m.user_id, $3, $4, NULLIF($5, ''), NULLIF($6, 0), $7
+FROM memberships m
+JOIN houses h ON h.id = m.house_id
+JOIN users u  ON u.id = m.user_id
+WHERE h.ext_group_id = $1 AND u.ext_user_id = $2
+ON CONFLICT ON CONSTRAINT achievements_code_key DO NOTHING
+RETURNING id, earned_at
+`, p.ExtGroupID, p.ExtUserID, p.Kind, p.Code, p.TaskKey, p.Threshold, p.PeriodStart).Scan(&row.ID, &row.EarnedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return AchievementRow{}, false, nil
+	}
+	if err != nil {
+		return AchievementRow{}, false, err
+	}
+	return row, true, nil
+}
+
+// ListAchievements メンバーのバッジを獲得順に返す
+func (r *Repo) ListAchievements(ctx context.Context, extGroupID, extUserID string) ([]AchievementRow, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT a.id, a.kind, a.code, COALESCE(a.task_key, ''), COALESCE(a.threshold, 0), a.period_start, a.earned_at
+FROM achievements a
+JOIN houses h ON h.id = a.house_id
+JOIN users u  ON u.id = a.user_id
+WHERE h.ext_group_id = $1 AND u.ext_user_id = $2
+ORDER BY a.earned_at, a.id
+`, extGroupID, extUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []AchievementRow
+	for rows.Next() {
+		var row AchievementRow
+		var period sql.NullTime
+		if err := rows.Scan(&row.ID, &row.Kind, &row.Code, &row.TaskKey, &row.Threshold, &period, &row.EarnedAt); err != nil {
+			return nil, err
+		}
+		if period.Valid {
+			row.PeriodStart = &period.Time
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
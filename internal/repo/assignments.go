@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"context"
+	"time"
+)
+
+// MemberPointsRow ハウスのメンバーと期間内の合計ポイント（記録がなければ0）
+type MemberPointsRow struct {
+	ExtUserID string
+	Name      string
+	Points    float64
+}
+
+// TaskLastDoneRow タスクをメンバーが最後にやった日時
+type TaskLastDoneRow struct {
+	TaskKey    string
+	ExtUserID  string
+	LastDoneAt time.Time
+}
+
+// MemberPoints ハウスの全メンバーのsince以降の合計ポイントを返す
+func (r *Repo) MemberPoints(ctx context.Context, extGroupID string, since time.Time) ([]MemberPointsRow, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT u.ext_user_id,
+       COALESCE(u.display_name, substr(u.ext_user_id,1,6)) AS name,
+       COALESCE((SELECT SUM(e.points) FROM events e
+                 WHERE e.house_id = m.house_id AND e.user_id = m.user_id
+                   AND e.performed_at >= $2 AND `+countedEvent+`), 0) AS pt
+FROM memberships m
+JOIN houses h ON h.id = m.house_id
+JOIN users u  ON u.id = m.user_id
+WHERE h.ext_group_id = $1 AND u.ext_user_id IS NOT NULL
+ORDER BY u.id
+`, extGroupID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []MemberPointsRow
+	for rows.Next() {
+		var row MemberPointsRow
+		if err := rows.Scan(&row.ExtUserID, &row.Name, &row.Points); err != nil {
+			return nil, err
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+// TaskLastDone ハウスのタスク×メンバーごとの最後の記録日時を返す（承認待ちの記録は数えない）
+func (r *Repo) TaskLastDone(ctx context.Context, extGroupID string) ([]TaskLastDoneRow, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT e.task_key, u.ext_user_id, MAX(e.performed_at)
+FROM events e
+JOIN houses h ON h.id = e.house_id
+JOIN users u  ON u.id = e.user_id
+WHERE h.ext_group_id = $1 AND `+countedEvent+` AND u.ext_user_id IS NOT NULL
+GROUP BY e.task_key, u.ext_user_id
+`, extGroupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []TaskLastDoneRow
+	for rows.Next() {
+		var row TaskLastDoneRow
+		if err := rows.Scan(&row.TaskKey, &row.ExtUserID, &row.LastDoneAt); err != nil {
+			return nil, err
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,80 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// 操作の経路
+const (
+	SourceLINE = "line"
+	SourceHTTP = "http"
+)
+
+// event_audit.action
+const (
+	AuditCreate  = "create"
+	AuditEdit    = "edit"
+	AuditCancel  = "cancel"
+	AuditRestore = "restore"
+	AuditApprove = "approve"
+)
+
+// Actor 記録を操作した人（ext_user_id、HTTPでは空）と経路
+type Actor struct {
+	ExtUserID string
+	Source    string
+}
+
+// AuditRow 操作履歴の1件（内容は操作後のもの）
+type AuditRow struct {
+	Action     string
+	Actor      string
+	Source     string
+	TaskKey    string
+	TaskOption *string
+	Points     float64
+	CreatedAt  time.Time
+}
+
+func actorOrNil(a Actor) interface{} {
+	return trimmedOrNil(&a.ExtUserID)
+}
+
+// insertAudit イベントの現在の内容を操作履歴に残す
+func insertAudit(ctx context.Context, tx *sql.Tx, eventID int64, action string, actor Actor) error {
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO event_audit(event_id, house_id, action, actor, source, task_key, task_option, points)
+SELECT id, house_id, $2, $3, $4, task_key, task_option, points FROM events WHERE id = $1
+`, eventID, action, actorOrNil(actor), actor.Source)
+	return err
+}
+
+// ListEventAudit イベントの操作履歴を古い順に返す
+func (r *Repo) ListEventAudit(ctx context.Context, eventID int64) ([]AuditRow, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT action, COALESCE(actor, ''), source, task_key, task_option, points, created_at
+FROM event_audit
+WHERE event_id = $1
+ORDER BY created_at, id
+`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []AuditRow
+	for rows.Next() {
+		var a AuditRow
+		var option sql.NullString
+		if err := rows.Scan(&a.Action, &a.Actor, &a.Source, &a.TaskKey, &option, &a.Points, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		if option.Valid {
+			a.TaskOption = &option.String
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,302 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// EventRow 記録済みの家事イベント。Seqはハウス内の通し番号
+type EventRow struct {
+	ID          int64
+	Seq         int64
+	ExtGroupID  string
+	ExtUserID   string
+	UserName    string
+	TaskKey     string
+	TaskOption  *string
+	Points      float64
+	PerformedAt time.Time
+	CreatedAt   time.Time
+	Status      string // EventPending/EventApproved（自動承認の期限を過ぎた承認待ちはEventApproved）
+}
+
+// UpdateEventParams 付け替え後のタスク・オプションと再計算したポイント
+type UpdateEventParams struct {
+	ID         int64
+	TaskKey    string
+	TaskOption *string
+	Points     float64
+	Actor      Actor
+}
+
+// events.status
+const (
+	EventPending  = "pending"
+	EventApproved = "approved"
+)
+
+// countedEvent 集計に含めるイベントの条件（events e）。取り消し済みと承認待ちは除くが、
+// 承認待ちでも自動承認の期限を過ぎたものは承認済みとして扱う
+const countedEvent = `e.deleted_at IS NULL AND (e.status = 'approved' OR e.auto_approve_at <= now())`
+
+const eventColumns = `
+SELECT e.id, e.seq, COALESCE(h.ext_group_id, ''), COALESCE(u.ext_user_id, ''),
+       COALESCE(u.display_name, substr(u.ext_user_id,1,6)),
+       e.task_key, e.task_option, e.points, e.performed_at, e.created_at,
+       CASE WHEN e.status = 'pending' AND e.auto_approve_at <= now() THEN 'approved' ELSE e.status END
+FROM events e
+JOIN houses h ON h.id = e.house_id
+JOIN users u  ON u.id = e.user_id
+`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEvent(sc rowScanner) (EventRow, error) {
+	var ev EventRow
+	var option sql.NullString
+	err := sc.Scan(&ev.ID, &ev.Seq, &ev.ExtGroupID, &ev.ExtUserID, &ev.UserName,
+		&ev.TaskKey, &option, &ev.Points, &ev.PerformedAt, &ev.CreatedAt, &ev.Status)
+	if err != nil {
+		return EventRow{}, err
+	}
+	if option.Valid {
+		ev.TaskOption = &option.String
+	}
+	return ev, nil
+}
+
+func (r *Repo) queryEvent(ctx context.Context, query string, args ...any) (EventRow, error) {
+	ev, err := scanEvent(r.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return EventRow{}, ErrNoEventFound
+		}
+		return EventRow{}, err
+	}
+	return ev, nil
+}
+
+// GetEvent IDでイベントを取得する（取り消し済みはErrNoEventFound）
+func (r *Repo) GetEvent(ctx context.Context, id int64) (EventRow, error) {
+	return r.queryEvent(ctx, eventColumns+`WHERE e.id = $1 AND e.deleted_at IS NULL`, id)
+}
+
+// GetEventBySeq ハウス内の通し番号でイベントを取得する（取り消し済みはErrNoEventFound）
+func (r *Repo) GetEventBySeq(ctx context.Context, extGroupID string, seq int64) (EventRow, error) {
+	return r.queryEvent(ctx, eventColumns+`WHERE h.ext_group_id = $1 AND e.seq = $2 AND e.deleted_at IS NULL`, extGroupID, seq)
+}
+
+// ListUserEvents ユーザーの最近のイベントを実施日時の新しい順に返す
+func (r *Repo) ListUserEvents(ctx context.Context, extGroupID, extUserID string, limit int) ([]EventRow, error) {
+	rows, err := r.db.QueryContext(ctx, eventColumns+`
+WHERE h.ext_group_id = $1 AND u.ext_user_id = $2 AND e.deleted_at IS NULL
+ORDER BY e.performed_at DESC, e.seq DESC
+LIMIT $3
+`, extGroupID, extUserID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []EventRow
+	for rows.Next() {
+		ev, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ev)
+	}
+	return out, rows.Err()
+}
+
+// DeleteEvent IDを指定してイベントを取り消す（論理削除）
+func (r *Repo) DeleteEvent(ctx context.Context, id int64, actor Actor) (DeletedEvent, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return DeletedEvent{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var result DeletedEvent
+	err = tx.QueryRowContext(ctx, `
+UPDATE events SET deleted_at = now(), deleted_by = $2
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING task_key, points, created_at
+`, id, actorOrNil(actor)).Scan(&result.TaskKey, &result.Points, &result.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return DeletedEvent{}, ErrNoEventFound
+		}
+		return DeletedEvent{}, err
+	}
+	if err := insertAudit(ctx, tx, id, AuditCancel, actor); err != nil {
+		return DeletedEvent{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return DeletedEvent{}, err
+	}
+	return result, nil
+}
+
+// UpdateEvent イベントのタスク・オプション・ポイントを書き換える（実施日時や冪等キーは変えない）。
+// 承認制のハウスでポイントが増えるときは承認待ちに戻し、自動承認の期限も数え直す
+func (r *Repo) UpdateEvent(ctx context.Context, p UpdateEventParams) (EventRow, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return EventRow{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.ExecContext(ctx, `
+UPDATE events e SET task_key = $2, task_option = $3, points = $4,
+  status          = CASE WHEN h.require_approval AND $4 > e.points THEN 'pending' ELSE e.status END,
+  approved_by     = CASE WHEN h.require_approval AND $4 > e.points THEN NULL ELSE e.approved_by END,
+  approved_at     = CASE WHEN h.require_approval AND $4 > e.points THEN NULL ELSE e.approved_at END,
+  auto_approve_at = CASE WHEN h.require_approval AND $4 > e.points
+                         THEN now() + make_interval(hours => h.approval_timeout_hours)
+                         ELSE e.auto_approve_at END
+FROM houses h
+WHERE e.id = $1 AND e.deleted_at IS NULL AND h.id = e.house_id
+`, p.ID, p.TaskKey, trimmedOrNil(p.TaskOption), p.Points)
+	if err != nil {
+		return EventRow{}, err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return EventRow{}, ErrNoEventFound
+	}
+	if err := insertAudit(ctx, tx, p.ID, AuditEdit, p.Actor); err != nil {
+		return EventRow{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return EventRow{}, err
+	}
+	return r.GetEvent(ctx, p.ID)
+}
+
+// RestoreLatestCancelled ユーザーの記録のうち最後に取り消されたものを元に戻す。
+// anyCancellerがfalseなら本人が取り消した記録だけを戻し、ほかの人が取り消していればErrCancelledByOther
+func (r *Repo) RestoreLatestCancelled(ctx context.Context, extGroupID, extUserID string, anyCanceller bool, actor Actor) (EventRow, error) {
+	return r.restoreCancelled(ctx, `
+SELECT e.id, e.deleted_by
+FROM events e
+JOIN houses h ON h.id = e.house_id
+JOIN users u  ON u.id = e.user_id
+WHERE h.ext_group_id = $1 AND u.ext_user_id = $2 AND e.deleted_at IS NOT NULL
+ORDER BY e.deleted_at DESC, e.id DESC
+LIMIT 1
+FOR UPDATE OF e
+`, func(deletedBy sql.NullString) bool {
+		return anyCanceller || deletedBy.String == extUserID
+	}, actor, extGroupID, extUserID)
+}
+
+// RestoreEventBySeq ハウス内の通し番号で取り消し済みの記録を元に戻す（取り消した人は問わない）
+func (r *Repo) RestoreEventBySeq(ctx context.Context, extGroupID string, seq int64, actor Actor) (EventRow, error) {
+	return r.restoreCancelled(ctx, `
+SELECT e.id, e.deleted_by
+FROM events e
+JOIN houses h ON h.id = e.house_id
+WHERE h.ext_group_id = $1 AND e.seq = $2 AND e.deleted_at IS NOT NULL
+FOR UPDATE OF e
+`, func(sql.NullString) bool { return true }, actor, extGroupID, seq)
+}
+
+// restoreCancelled queryで選んだ取り消し済みのイベントを戻す。
+// 承認制のハウスでは、取り消す前の承認は引き継がず承認待ちからやり直す
+func (r *Repo) restoreCancelled(ctx context.Context, query string, allowed func(deletedBy sql.NullString) bool, actor Actor, args ...any) (EventRow, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return EventRow{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var eventID int64
+	var deletedBy sql.NullString
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&eventID, &deletedBy); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return EventRow{}, ErrNoEventFound
+		}
+		return EventRow{}, err
+	}
+	if !allowed(deletedBy) {
+		return EventRow{}, ErrCancelledByOther
+	}
+	if _, err := tx.ExecContext(ctx, `
+UPDATE events e SET deleted_at = NULL, deleted_by = NULL,
+  status          = CASE WHEN h.require_approval THEN 'pending' ELSE e.status END,
+  approved_by     = CASE WHEN h.require_approval THEN NULL ELSE e.approved_by END,
+  approved_at     = CASE WHEN h.require_approval THEN NULL ELSE e.approved_at END,
+  auto_approve_at = CASE WHEN h.require_approval
+                         THEN now() + make_interval(hours => h.approval_timeout_hours)
+                         ELSE e.auto_approve_at END
+FROM houses h
+WHERE e.id = $1 AND h.id = e.house_id
+`, eventID); err != nil {
+		return EventRow{}, err
+	}
+	if err := insertAudit(ctx, tx, eventID, AuditRestore, actor); err != nil {
+		return EventRow{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return EventRow{}, err
+	}
+	return r.GetEvent(ctx, eventID)
+}
+
+// ListPendingEvents ハウスの承認待ち（自動承認の期限前）のイベントを報告の古い順に返す
+// （excludeExtUserIDの報告は除く。空なら全員分）
+func (r *Repo) ListPendingEvents(ctx context.Context, extGroupID, excludeExtUserID string, limit int) ([]EventRow, error) {
+	rows, err := r.db.QueryContext(ctx, eventColumns+`
+WHERE h.ext_group_id = $1 AND e.deleted_at IS NULL
+  AND e.status = 'pending' AND e.auto_approve_at > now()
+  AND ($2::text = '' OR u.ext_user_id <> $2)
+ORDER BY e.created_at, e.seq
+LIMIT $3
+`, extGroupID, excludeExtUserID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []EventRow
+	for rows.Next() {
+		ev, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ev)
+	}
+	return out, rows.Err()
+}
+
+// ApproveEvent 承認待ちのイベントを承認する（承認待ちでなければErrNotPending）
+func (r *Repo) ApproveEvent(ctx context.Context, id int64, actor Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.ExecContext(ctx, `
+UPDATE events SET status = 'approved', approved_at = now(),
+  approved_by = (SELECT id FROM users WHERE ext_user_id = $2)
+WHERE id = $1 AND deleted_at IS NULL AND status = 'pending' AND auto_approve_at > now()
+`, id, actorOrNil(actor))
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrNotPending
+	}
+	if err := insertAudit(ctx, tx, id, AuditApprove, actor); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
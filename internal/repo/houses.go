@@ -0,0 +1,88 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// HouseSettings ハウスごとの設定（houses テーブルの列）
+type HouseSettings struct {
+	ReportReply       string
+	BackdateLimitDays int
+	WeekStart         int    // 0=日曜 … 6=土曜
+	DayRolloverHour   int    // この時刻より前は前日として扱う
+	Timezone          string // IANAのタイムゾーン名
+	RequireApproval   bool   // 報告はほかのメンバーの承認まで集計しない
+	ApprovalTimeout   int    // 承認待ちを自動承認するまでの時間（時間単位）
+}
+
+// DefaultHouseSettings 未登録ハウスやマイグレーション直後の既定値
+func DefaultHouseSettings() HouseSettings {
+	return HouseSettings{ReportReply: "always", BackdateLimitDays: 7, WeekStart: 1, DayRolloverHour: 0, Timezone: "Asia/Tokyo", ApprovalTimeout: 24}
+}
+
+// UpdateHouseSettingsParams nilの項目は変更しない
+type UpdateHouseSettingsParams struct {
+	ExtGroupID        string
+	ReportReply       *string
+	BackdateLimitDays *int
+	WeekStart         *int
+	DayRolloverHour   *int
+	Timezone          *string
+	RequireApproval   *bool
+	ApprovalTimeout   *int
+}
+
+// GetHouseSettings ハウスの設定を返す（未登録なら既定値）
+func (r *Repo) GetHouseSettings(ctx context.Context, extGroupID string) (HouseSettings, error) {
+	var hs HouseSettings
+	err := r.db.QueryRowContext(ctx, `
+SELECT report_reply, backdate_limit_days, week_start, day_rollover_hour, timezone, require_approval, approval_timeout_hours
+FROM houses WHERE ext_group_id = $1
+`, extGroupID).Scan(&hs.ReportReply, &hs.BackdateLimitDays, &hs.WeekStart, &hs.DayRolloverHour, &hs.Timezone, &hs.RequireApproval, &hs.ApprovalTimeout)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return DefaultHouseSettings(), nil
+		}
+		return HouseSettings{}, err
+	}
+	return hs, nil
+}
+
+// UpdateHouseSettings ハウスの設定を更新する（未登録ならハウスを作成する）
+func (r *Repo) UpdateHouseSettings(ctx context.Context, p UpdateHouseSettingsParams) (HouseSettings, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return HouseSettings{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	houseID, err := r.upsertHouse(ctx, tx, p.ExtGroupID)
+	if err != nil {
+		return HouseSettings{}, err
+	}
+
+	var hs HouseSettings
+	err = tx.QueryRowContext(ctx, `
+UPDATE houses SET
+  report_reply           = COALESCE($2::text, report_reply),
+  backdate_limit_days    = COALESCE($3::int, backdate_limit_days),
+  week_start             = COALESCE($4::smallint, week_start),
+  day_rollover_hour      = COALESCE($5::smallint, day_rollover_hour),
+  timezone               = COALESCE($6::text, timezone),
+  require_approval       = COALESCE($7::boolean, require_approval),
+  approval_timeout_hours = COALESCE($8::int, approval_timeout_hours)
+WHERE id = $1
+RETURNING report_reply, backdate_limit_days, week_start, day_rollover_hour, timezone, require_approval, approval_timeout_hours
+`, houseID, p.ReportReply, p.BackdateLimitDays, p.WeekStart, p.DayRolloverHour, p.Timezone, p.RequireApproval, p.ApprovalTimeout).Scan(
+		&hs.ReportReply, &hs.BackdateLimitDays, &hs.WeekStart, &hs.DayRolloverHour, &hs.Timezone, &hs.RequireApproval, &hs.ApprovalTimeout)
+	if err != nil {
+		return HouseSettings{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return HouseSettings{}, err
+	}
+	return hs, nil
+}
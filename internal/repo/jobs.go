@@ -0,0 +1,107 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// job_runs.status
+const (
+	JobRunning = "running"
+	JobDone    = "done"
+	JobSkipped = "skipped"
+	JobFailed  = "failed"
+)
+
+// JobRun 取得した実行権（RetryKeyは同じハウス・期間の再実行で変わらない）
+type JobRun struct {
+	ID       int64
+	RetryKey string
+	Attempts int
+}
+
+// ClaimJobParams StaleAfterより前から running のままの行は、落ちたインスタンスの分として取り直す
+type ClaimJobParams struct {
+	Job         string
+	ExtGroupID  string
+	PeriodStart time.Time
+	MaxAttempts int
+	StaleAfter  time.Duration
+}
+
+// HouseRow 定期ジョブの対象ハウス
+type HouseRow struct {
+	ExtGroupID string
+	Settings   HouseSettings
+}
+
+// ListLineHouses LINEのグループ/ルーム/個人チャットのIDを持つハウスを返す（HTTP専用のハウスは除く）
+func (r *Repo) ListLineHouses(ctx context.Context) ([]HouseRow, error) {
+	return r.listHouses(ctx, `WHERE ext_group_id ~ '^[CRU][0-9a-f]{32}$'`)
+}
+
+// ListHouses すべてのハウスを返す（LINEに送らない定期ジョブ用）
+func (r *Repo) ListHouses(ctx context.Context) ([]HouseRow, error) {
+	return r.listHouses(ctx, "")
+}
+
+func (r *Repo) listHouses(ctx context.Context, where string) ([]HouseRow, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT ext_group_id, report_reply, backdate_limit_days, week_start, day_rollover_hour, timezone
+FROM houses
+`+where+`
+ORDER BY id
+`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []HouseRow
+	for rows.Next() {
+		var h HouseRow
+		hs := &h.Settings
+		if err := rows.Scan(&h.ExtGroupID, &hs.ReportReply, &hs.BackdateLimitDays, &hs.WeekStart, &hs.DayRolloverHour, &hs.Timezone); err != nil {
+			return nil, err
+		}
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
+
+// ClaimJobRun ハウス・期間のジョブの実行権を取る。
+// 他のインスタンスが実行中・実行済み・試行回数切れなら ok=false
+func (r *Repo) ClaimJobRun(ctx context.Context, p ClaimJobParams) (JobRun, bool, error) {
+	var run JobRun
+	err := r.db.QueryRowContext(ctx, `
+INSERT INTO job_runs(job, house_id, period_start)
+SELECT $1, h.id, $3 FROM houses h WHERE h.ext_group_id = $2
+ON CONFLICT (job, house_id, period_start) DO UPDATE
+  SET status = 'running', attempts = job_runs.attempts + 1, claimed_at = now(), last_error = NULL
+  WHERE job_runs.attempts < $4
+    AND (job_runs.status = 'failed'
+         OR (job_runs.status = 'running' AND job_runs.claimed_at < now() - make_interval(secs => $5)))
+RETURNING id, retry_key::text, attempts
+`, p.Job, p.ExtGroupID, p.PeriodStart, p.MaxAttempts, p.StaleAfter.Seconds()).Scan(&run.ID, &run.RetryKey, &run.Attempts)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return JobRun{}, false, nil
+		}
+		return JobRun{}, false, err
+	}
+	return run, true, nil
+}
+
+// FinishJobRun 実行結果を記録する（failed の行は次回の ClaimJobRun で再実行できる）
+func (r *Repo) FinishJobRun(ctx context.Context, id int64, status string, lastErr error) error {
+	var msg interface{}
+	if lastErr != nil {
+		msg = lastErr.Error()
+	}
+	_, err := r.db.ExecContext(ctx, `
+UPDATE job_runs SET status = $2, last_error = $3, finished_at = now() WHERE id = $1
+`, id, status, msg)
+	return err
+}
@@ -0,0 +1,38 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// pushMonth LINEの月間上限に合わせて日本時間の今月
+const pushMonth = `date_trunc('month', now() AT TIME ZONE 'Asia/Tokyo')::date`
+
+// ReserveLinePushes 今月のプッシュ送信数にnを足す。足すとbudgetを超えるなら足さずに false
+func (r *Repo) ReserveLinePushes(ctx context.Context, n, budget int) (bool, error) {
+	var used int
+	err := r.db.QueryRowContext(ctx, `
+INSERT INTO line_push_usage(month, messages)
+SELECT `+pushMonth+`, $1::int WHERE $1::int <= $2::int
+ON CONFLICT (month) DO UPDATE SET messages = line_push_usage.messages + EXCLUDED.messages, updated_at = now()
+  WHERE line_push_usage.messages + EXCLUDED.messages <= $2::int
+RETURNING messages
+`, n, budget).Scan(&used)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// RecordLinePushes 予算に関係なく今月のプッシュ送信数にnを足す（負なら送れなかった分を戻す）
+func (r *Repo) RecordLinePushes(ctx context.Context, n int) error {
+	_, err := r.db.ExecContext(ctx, `
+INSERT INTO line_push_usage(month, messages) VALUES (`+pushMonth+`, GREATEST($1::int, 0))
+ON CONFLICT (month) DO UPDATE SET messages = GREATEST(line_push_usage.messages + $1::int, 0), updated_at = now()
+`, n)
+	return err
+}
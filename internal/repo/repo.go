@@ -8,7 +8,10 @@ import (
 	"time"
 )
 
-type Repo struct{ db *sql.DB }
+type Repo struct {
+	db        *sql.DB
+	taskSeeds []TaskSeed
+}
 
 func New(db *sql.DB) *Repo { return &Repo{db: db} }
 
@@ -17,8 +20,11 @@ func (r *Repo) Ping(ctx context.Context) error {
 }
 
 var (
-	ErrDuplicateEvent = errors.New("duplicate event")
-	ErrNoEventFound   = errors.New("no event found")
+	ErrDuplicateEvent   = errors.New("duplicate event")
+	ErrNoEventFound     = errors.New("no event found")
+	ErrNoMemberFound    = errors.New("no member found")
+	ErrNotPending       = errors.New("event is not pending")
+	ErrCancelledByOther = errors.New("event was cancelled by someone else")
 )
 
 type EventKind string
@@ -27,6 +33,11 @@ const (
 	KindChore EventKind = "chore"
 )
 
+const (
+	RoleMember = "member"
+	RoleAdmin  = "admin"
+)
+
 type UpsertHouseUserParams struct {
 	ExtGroupID  string
 	ExtUserID   string
@@ -41,8 +52,10 @@ type InsertEventParams struct {
 	TaskOption  *string
 	Points      float64
 	SourceMsgID *string
-	Now         time.Time
+	Now         time.Time // 報告日時
+	PerformedAt time.Time // 実施日時（ゼロ値ならNow）
 	Note        *string
+	Source      string // 操作履歴に残す経路（SourceLINE/SourceHTTP）
 }
 
 func trimmedOrNil(s *string) interface{} {
@@ -56,21 +69,41 @@ func trimmedOrNil(s *string) interface{} {
 	return trimmed
 }
 
-func (r *Repo) InsertEvent(ctx context.Context, p InsertEventParams) error {
+// InsertedEvent 記録したイベントの通し番号と、ハウスが承認制のため承認待ちになったか
+type InsertedEvent struct {
+	Seq     int64
+	Pending bool
+}
+
+func (r *Repo) InsertEvent(ctx context.Context, p InsertEventParams) (InsertedEvent, error) {
+	inserted, err := r.InsertEvents(ctx, []InsertEventParams{p})
+	if err != nil {
+		return InsertedEvent{}, err
+	}
+	return inserted[0], nil
+}
+
+// InsertEvents 同じハウス・ユーザーの複数イベントを1トランザクションで記録する（1件でも重複なら全件取り消し）
+func (r *Repo) InsertEvents(ctx context.Context, ps []InsertEventParams) ([]InsertedEvent, error) {
+	if len(ps) == 0 {
+		return nil, nil
+	}
+	for _, p := range ps[1:] {
+		if p.ExtGroupID != ps[0].ExtGroupID || p.ExtUserID != ps[0].ExtUserID {
+			return nil, errors.New("events in a batch must share group and user")
+		}
+	}
+	first := ps[0]
+
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	var houseID int64
-	err = tx.QueryRowContext(ctx, `
-INSERT INTO houses(ext_group_id) VALUES($1)
-ON CONFLICT(ext_group_id) DO UPDATE SET name=COALESCE(houses.name, EXCLUDED.ext_group_id)
-RETURNING id
-`, p.ExtGroupID).Scan(&houseID)
+	houseID, err := r.upsertHouse(ctx, tx, first.ExtGroupID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var userID int64
@@ -78,31 +111,66 @@ RETURNING id
 INSERT INTO users(ext_user_id, display_name) VALUES($1, $2)
 ON CONFLICT(ext_user_id) DO UPDATE SET display_name=COALESCE(EXCLUDED.display_name, users.display_name)
 RETURNING id
-`, p.ExtUserID, trimmedOrNil(p.DisplayName)).Scan(&userID)
+`, first.ExtUserID, trimmedOrNil(first.DisplayName)).Scan(&userID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if _, err = tx.ExecContext(ctx, `
 INSERT INTO memberships(house_id,user_id) VALUES($1,$2)
 ON CONFLICT(house_id,user_id) DO NOTHING
 `, houseID, userID); err != nil {
-		return err
+		return nil, err
 	}
 
-	result, err := tx.ExecContext(ctx, `
-INSERT INTO events(house_id,user_id,kind,task_key,task_option,points,source_msg_id,created_at,note)
-VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9)
-ON CONFLICT(house_id, source_msg_id) DO NOTHING
-`, houseID, userID, KindChore, p.TaskKey, p.TaskOption, p.Points, p.SourceMsgID, p.Now, p.Note)
-	if err != nil {
-		return err
+	inserted := make([]InsertedEvent, 0, len(ps))
+	for _, p := range ps {
+		performedAt := p.PerformedAt
+		if performedAt.IsZero() {
+			performedAt = p.Now
+		}
+		// 通し番号はハウス行をロックして採番する（重複で挿入されなくても番号は進む）。
+		// 承認制のハウスでは承認待ちで記録し、期限を過ぎたら自動承認として扱う。
+		// 挿入できた場合だけ操作履歴も同じ文で残すので、行が返らなければ重複を意味する
+		var ev InsertedEvent
+		err := tx.QueryRowContext(ctx, `
+WITH next AS (
+  UPDATE houses SET event_seq = event_seq + 1 WHERE id = $1
+  RETURNING event_seq, require_approval, approval_timeout_hours
+), ins AS (
+  INSERT INTO events(house_id,seq,user_id,kind,task_key,task_option,points,source_msg_id,created_at,performed_at,note,status,auto_approve_at)
+  SELECT $1, next.event_seq, $2, $3, $4, $5, $6, $7, $8, $9, $10,
+         CASE WHEN next.require_approval THEN 'pending' ELSE 'approved' END,
+         CASE WHEN next.require_approval THEN $8::timestamptz + make_interval(hours => next.approval_timeout_hours) END
+  FROM next
+  ON CONFLICT(house_id, source_msg_id) DO NOTHING
+  RETURNING id, house_id, seq, status, task_key, task_option, points
+), audit AS (
+  INSERT INTO event_audit(event_id, house_id, action, actor, source, task_key, task_option, points)
+  SELECT id, house_id, 'create', $11, $12, task_key, task_option, points FROM ins
+)
+SELECT seq, status = 'pending' FROM ins
+`, houseID, userID, KindChore, p.TaskKey, p.TaskOption, p.Points, p.SourceMsgID, p.Now, performedAt, p.Note, p.ExtUserID, sourceOrHTTP(p.Source)).Scan(&ev.Seq, &ev.Pending)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, ErrDuplicateEvent
+			}
+			return nil, err
+		}
+		inserted = append(inserted, ev)
 	}
-	if rows, _ := result.RowsAffected(); rows == 0 {
-		return ErrDuplicateEvent
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
 	}
+	return inserted, nil
+}
 
-	return tx.Commit()
+func sourceOrHTTP(source string) string {
+	if source == "" {
+		return SourceHTTP
+	}
+	return source
 }
 
 func (r *Repo) UpsertHouseUser(ctx context.Context, p UpsertHouseUserParams) error {
@@ -112,12 +180,7 @@ func (r *Repo) UpsertHouseUser(ctx context.Context, p UpsertHouseUserParams) err
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	var houseID int64
-	err = tx.QueryRowContext(ctx, `
-INSERT INTO houses(ext_group_id) VALUES($1)
-ON CONFLICT(ext_group_id) DO UPDATE SET name=COALESCE(houses.name, EXCLUDED.ext_group_id)
-RETURNING id
-`, p.ExtGroupID).Scan(&houseID)
+	houseID, err := r.upsertHouse(ctx, tx, p.ExtGroupID)
 	if err != nil {
 		return err
 	}
@@ -143,8 +206,9 @@ ON CONFLICT(house_id,user_id) DO NOTHING
 }
 
 type WeeklyRow struct {
-	Name   string  `json:"name"`
-	Points float64 `json:"points"`
+	ExtUserID string  `json:"-"`
+	Name      string  `json:"name"`
+	Points    float64 `json:"points"`
 }
 
 type DeletedEvent struct {
@@ -153,19 +217,16 @@ type DeletedEvent struct {
 	CreatedAt time.Time
 }
 
-type WeeklyTaskRow struct {
-	TaskKey string
-	Points  float64
-}
-
 func (r *Repo) WeeklyPoints(ctx context.Context, extGroupID string, start, end time.Time) ([]WeeklyRow, error) {
 	rows, err := r.db.QueryContext(ctx, `
-SELECT COALESCE(u.display_name, substr(u.ext_user_id,1,6)) AS name,
+SELECT COALESCE(u.ext_user_id, ''),
+       COALESCE(u.display_name, substr(u.ext_user_id,1,6)) AS name,
        COALESCE(SUM(e.points),0) AS pt
 FROM events e
 JOIN users u  ON u.id=e.user_id
 JOIN houses h ON h.id=e.house_id
-WHERE h.ext_group_id=$1 AND e.created_at >= $2 AND e.created_at < $3
+WHERE h.ext_group_id=$1 AND e.performed_at >= $2 AND e.performed_at < $3
+  AND `+countedEvent+`
 GROUP BY u.id, u.display_name, u.ext_user_id
 ORDER BY pt DESC
 `, extGroupID, start, end)
@@ -177,7 +238,7 @@ ORDER BY pt DESC
 	var out []WeeklyRow
 	for rows.Next() {
 		var w WeeklyRow
-		if err := rows.Scan(&w.Name, &w.Points); err != nil {
+		if err := rows.Scan(&w.ExtUserID, &w.Name, &w.Points); err != nil {
 			return nil, err
 		}
 		out = append(out, w)
@@ -185,37 +246,8 @@ ORDER BY pt DESC
 	return out, rows.Err()
 }
 
-func (r *Repo) WeeklyUserTaskPoints(ctx context.Context, extGroupID, extUserID string, start, end time.Time) ([]WeeklyTaskRow, error) {
-	rows, err := r.db.QueryContext(ctx, `
-SELECT e.task_key,
-       COALESCE(SUM(e.points),0) AS pt
-FROM events e
-JOIN houses h ON h.id = e.house_id
-JOIN users u  ON u.id = e.user_id
-WHERE h.ext_group_id = $1
-  AND u.ext_user_id = $2
-  AND e.created_at >= $3
-  AND e.created_at < $4
-GROUP BY e.task_key
-ORDER BY pt DESC, e.task_key ASC
-`, extGroupID, extUserID, start, end)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var out []WeeklyTaskRow
-	for rows.Next() {
-		var row WeeklyTaskRow
-		if err := rows.Scan(&row.TaskKey, &row.Points); err != nil {
-			return nil, err
-		}
-		out = append(out, row)
-	}
-	return out, rows.Err()
-}
-
-func (r *Repo) DeleteLatestEvent(ctx context.Context, extGroupID, extUserID string) (DeletedEvent, error) {
+// DeleteLatestEvent ユーザーの直前の記録を取り消す（論理削除）。まとめて報告した記録は作成日時が同じなので、後の項目（大きいID）から取り消す
+func (r *Repo) DeleteLatestEvent(ctx context.Context, extGroupID, extUserID string, actor Actor) (DeletedEvent, error) {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return DeletedEvent{}, err
@@ -230,9 +262,10 @@ WITH target AS (
     FROM events e
     JOIN houses h ON h.id = e.house_id
     JOIN users u  ON u.id = e.user_id
-    WHERE h.ext_group_id = $1 AND u.ext_user_id = $2
-    ORDER BY e.created_at DESC
+    WHERE h.ext_group_id = $1 AND u.ext_user_id = $2 AND e.deleted_at IS NULL
+    ORDER BY e.created_at DESC, e.id DESC
     LIMIT 1
+    FOR UPDATE OF e
 )
 SELECT t.id, t.task_key, t.points, t.created_at FROM target t
 `, extGroupID, extUserID).Scan(&eventID, &result.TaskKey, &result.Points, &result.CreatedAt)
@@ -243,7 +276,15 @@ SELECT t.id, t.task_key, t.points, t.created_at FROM target t
 		return DeletedEvent{}, err
 	}
 
-	if _, err := tx.ExecContext(ctx, `DELETE FROM events WHERE id=$1`, eventID); err != nil {
+	// 同時に届いた取り消しとは行ロックで順番になる。先に取り消されていたら取り消しの履歴を残さない
+	res, err := tx.ExecContext(ctx, `UPDATE events SET deleted_at=now(), deleted_by=$2 WHERE id=$1 AND deleted_at IS NULL`, eventID, actorOrNil(actor))
+	if err != nil {
+		return DeletedEvent{}, err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return DeletedEvent{}, ErrNoEventFound
+	}
+	if err := insertAudit(ctx, tx, eventID, AuditCancel, actor); err != nil {
 		return DeletedEvent{}, err
 	}
 
@@ -252,3 +293,22 @@ SELECT t.id, t.task_key, t.points, t.created_at FROM target t
 	}
 	return result, nil
 }
+
+// MemberRole ハウス内でのユーザーの役割を返す（未所属はErrNoMemberFound）
+func (r *Repo) MemberRole(ctx context.Context, extGroupID, extUserID string) (string, error) {
+	var role sql.NullString
+	err := r.db.QueryRowContext(ctx, `
+SELECT m.role
+FROM memberships m
+JOIN houses h ON h.id = m.house_id
+JOIN users u  ON u.id = m.user_id
+WHERE h.ext_group_id = $1 AND u.ext_user_id = $2
+`, extGroupID, extUserID).Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoMemberFound
+		}
+		return "", err
+	}
+	return role.String, nil
+}
@@ -54,21 +54,25 @@ func TestDeleteLatestEventSuccess(t *testing.T) {
     FROM events e
     JOIN houses h ON h.id = e.house_id
     JOIN users u  ON u.id = e.user_id
-    WHERE h.ext_group_id = $1 AND u.ext_user_id = $2
-    ORDER BY e.created_at DESC
+    WHERE h.ext_group_id = $1 AND u.ext_user_id = $2 AND e.deleted_at IS NULL
+    ORDER BY e.created_at DESC, e.id DESC
     LIMIT 1
+    FOR UPDATE OF e
 )
 SELECT t.id, t.task_key, t.points, t.created_at FROM target t
 `)).
 		WithArgs("g1", "u1").
 		WillReturnRows(sqlmock.NewRows([]string{"id", "task_key", "points", "created_at"}).
 			AddRow(42, "皿洗い", 150.0, now))
-	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM events WHERE id=$1")).
-		WithArgs(int64(42)).
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE events SET deleted_at=now(), deleted_by=$2 WHERE id=$1 AND deleted_at IS NULL")).
+		WithArgs(int64(42), "u1").
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO event_audit(`)).
+		WithArgs(int64(42), AuditCancel, "u1", SourceLINE).
+		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
-	out, err := r.DeleteLatestEvent(context.Background(), "g1", "u1")
+	out, err := r.DeleteLatestEvent(context.Background(), "g1", "u1", Actor{ExtUserID: "u1", Source: SourceLINE})
 	if err != nil {
 		t.Fatalf("DeleteLatestEvent returned error: %v", err)
 	}
@@ -95,9 +99,10 @@ func TestDeleteLatestEventNoRows(t *testing.T) {
     FROM events e
     JOIN houses h ON h.id = e.house_id
     JOIN users u  ON u.id = e.user_id
-    WHERE h.ext_group_id = $1 AND u.ext_user_id = $2
-    ORDER BY e.created_at DESC
+    WHERE h.ext_group_id = $1 AND u.ext_user_id = $2 AND e.deleted_at IS NULL
+    ORDER BY e.created_at DESC, e.id DESC
     LIMIT 1
+    FOR UPDATE OF e
 )
 SELECT t.id, t.task_key, t.points, t.created_at FROM target t
 `)).
@@ -105,7 +110,36 @@ SELECT t.id, t.task_key, t.points, t.created_at FROM target t
 		WillReturnError(sql.ErrNoRows)
 	mock.ExpectRollback()
 
-	_, err = r.DeleteLatestEvent(context.Background(), "g1", "u1")
+	_, err = r.DeleteLatestEvent(context.Background(), "g1", "u1", Actor{ExtUserID: "u1", Source: SourceLINE})
+	if !errors.Is(err, ErrNoEventFound) {
+		t.Fatalf("expected ErrNoEventFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations not met: %v", err)
+	}
+}
+
+func TestDeleteLatestEventAlreadyCancelled(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+
+	r := New(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FOR UPDATE OF e`)).
+		WithArgs("g1", "u1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "task_key", "points", "created_at"}).
+			AddRow(42, "皿洗い", 150.0, time.Now()))
+	// 先に別のワーカーが取り消していたら、取り消しの履歴を残さずErrNoEventFound
+	mock.ExpectExec(regexp.QuoteMeta("WHERE id=$1 AND deleted_at IS NULL")).
+		WithArgs(int64(42), "u1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	_, err = r.DeleteLatestEvent(context.Background(), "g1", "u1", Actor{ExtUserID: "u1", Source: SourceLINE})
 	if !errors.Is(err, ErrNoEventFound) {
 		t.Fatalf("expected ErrNoEventFound, got %v", err)
 	}
@@ -130,21 +164,22 @@ func TestDeleteLatestEventDeleteFailure(t *testing.T) {
     FROM events e
     JOIN houses h ON h.id = e.house_id
     JOIN users u  ON u.id = e.user_id
-    WHERE h.ext_group_id = $1 AND u.ext_user_id = $2
-    ORDER BY e.created_at DESC
+    WHERE h.ext_group_id = $1 AND u.ext_user_id = $2 AND e.deleted_at IS NULL
+    ORDER BY e.created_at DESC, e.id DESC
     LIMIT 1
+    FOR UPDATE OF e
 )
 SELECT t.id, t.task_key, t.points, t.created_at FROM target t
 `)).
 		WithArgs("g1", "u1").
 		WillReturnRows(sqlmock.NewRows([]string{"id", "task_key", "points", "created_at"}).
 			AddRow(42, "皿洗い", 150.0, now))
-	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM events WHERE id=$1")).
-		WithArgs(int64(42)).
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE events SET deleted_at=now(), deleted_by=$2 WHERE id=$1 AND deleted_at IS NULL")).
+		WithArgs(int64(42), "u1").
 		WillReturnError(errors.New("delete failed"))
 	mock.ExpectRollback()
 
-	_, err = r.DeleteLatestEvent(context.Background(), "g1", "u1")
+	_, err = r.DeleteLatestEvent(context.Background(), "g1", "u1", Actor{ExtUserID: "u1", Source: SourceLINE})
 	if err == nil || err.Error() != "delete failed" {
 		t.Fatalf("expected delete failed error, got %v", err)
 	}
@@ -152,3 +187,576 @@ SELECT t.id, t.task_key, t.points, t.created_at FROM target t
 		t.Fatalf("expectations not met: %v", err)
 	}
 }
+
+func TestListTasksGroupsAliases(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+
+	r := New(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM tasks t`)).
+		WithArgs("g1", false).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "task_key", "points", "scoring", "unit_minutes", "max_units", "archived", "rotation", "owner", "alias"}).
+			AddRow(1, "皿洗い", 180.0, "fixed", 0, 0, false, "least_points", "", "さらあらい").
+			AddRow(1, "皿洗い", 180.0, "fixed", 0, 0, false, "least_points", "", "洗い物").
+			AddRow(2, "ゴミ出し", 100.0, "fixed", 0, 0, false, "fixed", "u1", nil))
+
+	out, err := r.ListTasks(context.Background(), "g1", false)
+	if err != nil {
+		t.Fatalf("ListTasks returned error: %v", err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("expected 2 tasks, got %+v", out)
+	}
+	if out[0].Key != "皿洗い" || len(out[0].Aliases) != 2 {
+		t.Fatalf("unexpected first task: %+v", out[0])
+	}
+	if out[1].Key != "ゴミ出し" || len(out[1].Aliases) != 0 || out[1].Owner != "u1" {
+		t.Fatalf("unexpected second task: %+v", out[1])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations not met: %v", err)
+	}
+}
+
+func TestInsertEventsRollsBackOnDuplicate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+
+	r := New(db)
+	now := time.Now()
+	id1, id2 := "m#1", "m#2"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO houses(ext_group_id)`)).
+		WithArgs("g1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "inserted"}).AddRow(1, false))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO users(ext_user_id, display_name)`)).
+		WithArgs("u1", nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO memberships(house_id,user_id)`)).
+		WithArgs(int64(1), int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO events(`)).
+		WillReturnRows(sqlmock.NewRows([]string{"seq", "pending"}).AddRow(10, false))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO events(`)).
+		WillReturnRows(sqlmock.NewRows([]string{"seq", "pending"}))
+	mock.ExpectRollback()
+
+	_, err = r.InsertEvents(context.Background(), []InsertEventParams{
+		{ExtGroupID: "g1", ExtUserID: "u1", TaskKey: "皿洗い", Points: 180, SourceMsgID: &id1, Now: now},
+		{ExtGroupID: "g1", ExtUserID: "u1", TaskKey: "ゴミ出し", Points: 100, SourceMsgID: &id2, Now: now},
+	})
+	if !errors.Is(err, ErrDuplicateEvent) {
+		t.Fatalf("expected ErrDuplicateEvent, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations not met: %v", err)
+	}
+}
+
+func TestDeleteEventNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+
+	r := New(db)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE id = $1 AND deleted_at IS NULL`)).
+		WithArgs(int64(42), nil).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	if _, err := r.DeleteEvent(context.Background(), 42, Actor{Source: SourceHTTP}); !errors.Is(err, ErrNoEventFound) {
+		t.Fatalf("expected ErrNoEventFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetEventBySeqScansOption(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+
+	r := New(db)
+	now := time.Now()
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE h.ext_group_id = $1 AND e.seq = $2`)).
+		WithArgs("g1", int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "seq", "group", "user", "name", "task_key", "task_option", "points", "performed_at", "created_at", "status"}).
+			AddRow(int64(10), int64(3), "g1", "u1", "Alice", "散歩", "30分", 50.0, now, now, EventApproved))
+
+	ev, err := r.GetEventBySeq(context.Background(), "g1", 3)
+	if err != nil {
+		t.Fatalf("GetEventBySeq failed: %v", err)
+	}
+	if ev.ID != 10 || ev.Seq != 3 || ev.TaskOption == nil || *ev.TaskOption != "30分" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestApproveEventNotPending(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+
+	r := New(db)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE events SET status = 'approved'`)).
+		WithArgs(int64(10), "u2").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err = r.ApproveEvent(context.Background(), 10, Actor{ExtUserID: "u2", Source: SourceLINE})
+	if !errors.Is(err, ErrNotPending) {
+		t.Fatalf("expected ErrNotPending, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestUpdateEventResetsApprovalWhenPointsIncrease(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+
+	r := New(db)
+	now := time.Now()
+	option := "x10"
+	mock.ExpectBegin()
+	// 承認制のハウスでポイントが増えたら承認待ちに戻し、承認者と自動承認の期限を付け直す
+	mock.ExpectExec(regexp.QuoteMeta(`status          = CASE WHEN h.require_approval AND $4 > e.points THEN 'pending' ELSE e.status END,
+  approved_by     = CASE WHEN h.require_approval AND $4 > e.points THEN NULL ELSE e.approved_by END,
+  approved_at     = CASE WHEN h.require_approval AND $4 > e.points THEN NULL ELSE e.approved_at END,
+  auto_approve_at = CASE WHEN h.require_approval AND $4 > e.points
+                         THEN now() + make_interval(hours => h.approval_timeout_hours)`)).
+		WithArgs(int64(10), "窓拭き", "x10", 1500.0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO event_audit`)).
+		WithArgs(int64(10), AuditEdit, "u1", SourceLINE).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE e.id = $1`)).
+		WithArgs(int64(10)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "seq", "group", "user", "name", "task_key", "task_option", "points", "performed_at", "created_at", "status"}).
+			AddRow(int64(10), int64(3), "g1", "u1", "Alice", "窓拭き", "x10", 1500.0, now, now, EventPending))
+
+	ev, err := r.UpdateEvent(context.Background(), UpdateEventParams{
+		ID:         10,
+		TaskKey:    "窓拭き",
+		TaskOption: &option,
+		Points:     1500,
+		Actor:      Actor{ExtUserID: "u1", Source: SourceLINE},
+	})
+	if err != nil {
+		t.Fatalf("UpdateEvent failed: %v", err)
+	}
+	if ev.Status != EventPending {
+		t.Fatalf("expected edited event to be pending, got %q", ev.Status)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestListPendingEventsExcludesApproverBeforeLimit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+
+	r := New(db)
+	// 本人の報告はLIMITの前にSQLで除く（古い順の先頭が本人の報告で埋まっても他の人の分を返す）
+	mock.ExpectQuery(regexp.QuoteMeta(`AND ($2::text = '' OR u.ext_user_id <> $2)
+ORDER BY e.created_at, e.seq
+LIMIT $3`)).
+		WithArgs("g1", "u1", 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "seq", "group", "user", "name", "task_key", "task_option", "points", "performed_at", "created_at", "status"}))
+
+	if _, err := r.ListPendingEvents(context.Background(), "g1", "u1", 20); err != nil {
+		t.Fatalf("ListPendingEvents failed: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRestoreLatestCancelledNoRows(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+
+	r := New(db)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT e.id, e.deleted_by`)).
+		WithArgs("g1", "u1").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	if _, err := r.RestoreLatestCancelled(context.Background(), "g1", "u1", false, Actor{ExtUserID: "u1", Source: SourceLINE}); !errors.Is(err, ErrNoEventFound) {
+		t.Fatalf("expected ErrNoEventFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRestoreLatestCancelledByOther(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+
+	r := New(db)
+	// 管理者が取り消した記録は、本人の @bot 復元 では戻さない
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT e.id, e.deleted_by`)).
+		WithArgs("g1", "u1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "deleted_by"}).AddRow(int64(7), "admin"))
+	mock.ExpectRollback()
+
+	if _, err := r.RestoreLatestCancelled(context.Background(), "g1", "u1", false, Actor{ExtUserID: "u1", Source: SourceLINE}); !errors.Is(err, ErrCancelledByOther) {
+		t.Fatalf("expected ErrCancelledByOther, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRestoreEventBySeqResetsApproval(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+
+	r := New(db)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT e.id, e.deleted_by`)).
+		WithArgs("g1", int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "deleted_by"}).AddRow(int64(7), "u1"))
+	// 承認制のハウスでは取り消す前の承認を引き継がず、承認待ちからやり直す
+	mock.ExpectExec(regexp.QuoteMeta(`status          = CASE WHEN h.require_approval THEN 'pending' ELSE e.status END`)).
+		WithArgs(int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO event_audit`)).
+		WithArgs(int64(7), AuditRestore, "admin", SourceLINE).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	now := time.Now()
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE e.id = $1 AND e.deleted_at IS NULL`)).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "seq", "group", "user", "name", "task_key", "task_option", "points", "performed_at", "created_at", "status"}).
+			AddRow(int64(7), int64(3), "g1", "u1", "Alice", "皿洗い", nil, 100.0, now, now, EventPending))
+
+	ev, err := r.RestoreEventBySeq(context.Background(), "g1", 3, Actor{ExtUserID: "admin", Source: SourceLINE})
+	if err != nil {
+		t.Fatalf("RestoreEventBySeq failed: %v", err)
+	}
+	if ev.ID != 7 || ev.Status != EventPending {
+		t.Fatalf("unexpected restored event: %+v", ev)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestClaimJobRunAlreadyClaimed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+
+	r := New(db)
+	week := time.Date(2025, 11, 9, 15, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO job_runs(job, house_id, period_start)`)).
+		WithArgs("weekly_recap", "g1", week, 5, float64(600)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "retry_key", "attempts"}))
+
+	_, ok, err := r.ClaimJobRun(context.Background(), ClaimJobParams{
+		Job: "weekly_recap", ExtGroupID: "g1", PeriodStart: week, MaxAttempts: 5, StaleAfter: 10 * time.Minute,
+	})
+	if err != nil || ok {
+		t.Fatalf("expected no claim, got ok=%v err=%v", ok, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestScheduleReminderLease(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+
+	r := New(db)
+	ctx := context.Background()
+	due := time.Date(2025, 11, 10, 0, 0, 0, 0, time.UTC)
+	// 送信権を取るだけでは通知済みにせず、送れてから reminded_due に記録する
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE chore_schedules SET reminding_due = $2, reminder_claimed_at = now()`)).
+		WithArgs(int64(3), due, float64(600)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE chore_schedules SET reminded_due = $2, reminding_due = NULL, reminder_claimed_at = NULL`)).
+		WithArgs(int64(3), due).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ok, err := r.ClaimScheduleReminder(ctx, 3, due, 10*time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("expected a claim, got ok=%v err=%v", ok, err)
+	}
+	if err := r.CompleteScheduleReminder(ctx, 3, due); err != nil {
+		t.Fatalf("CompleteScheduleReminder failed: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestDeleteScheduleNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+
+	r := New(db)
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM chore_schedules s`)).
+		WithArgs("g1", int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := r.DeleteSchedule(context.Background(), "g1", 7); !errors.Is(err, ErrNoScheduleFound) {
+		t.Fatalf("expected ErrNoScheduleFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestInsertShareNotMember(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+
+	r := New(db)
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO member_shares`)).
+		WithArgs("g1", "U404", 60.0, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err = r.InsertShare(context.Background(), InsertShareParams{ExtGroupID: "g1", ExtUserID: "U404", Share: 60})
+	if !errors.Is(err, ErrNoMemberFound) {
+		t.Fatalf("expected ErrNoMemberFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestInsertAchievementAlreadyEarned(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+
+	r := New(db)
+	mock.ExpectQuery(regexp.QuoteMeta(`ON CONFLICT ON CONSTRAINT achievements_code_key DO NOTHING`)).
+		WithArgs("g1", "U1", "streak", "streak:7", "", 7, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "earned_at"}))
+
+	_, ok, err := r.InsertAchievement(context.Background(), InsertAchievementParams{
+		ExtGroupID: "g1", ExtUserID: "U1", Kind: "streak", Code: "streak:7", Threshold: 7,
+	})
+	if err != nil || ok {
+		t.Fatalf("expected already earned, got ok=%v err=%v", ok, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCreateRedemptionInsufficientPoints(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+
+	r := New(db)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FOR UPDATE OF m`)).
+		WithArgs("g1", "U1").
+		WillReturnRows(sqlmock.NewRows([]string{"house_id", "user_id"}).AddRow(int64(1), int64(2)))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT cost FROM rewards`)).
+		WithArgs(int64(1), int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"cost"}).AddRow(2000.0))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM memberships m`)).
+		WithArgs("g1", "U1").
+		WillReturnRows(sqlmock.NewRows([]string{"earned", "spent", "reserved"}).AddRow(3000.0, 0.0, 1500.0))
+	mock.ExpectRollback()
+
+	if _, err := r.CreateRedemption(context.Background(), "g1", "U1", 5); !errors.Is(err, ErrInsufficientPoints) {
+		t.Fatalf("expected ErrInsufficientPoints, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestClaimWebhookJobEmpty(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+
+	r := New(db)
+	mock.ExpectQuery(regexp.QuoteMeta(`FOR UPDATE SKIP LOCKED`)).
+		WithArgs(float64(120)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_id", "destination", "payload", "attempts"}))
+
+	_, ok, err := r.ClaimWebhookJob(context.Background(), 2*time.Minute)
+	if err != nil || ok {
+		t.Fatalf("expected no job, got ok=%v err=%v", ok, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPurgeWebhookJobsDeletesFinishedRows(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+
+	r := New(db)
+	mock.ExpectExec(regexp.QuoteMeta(`WHERE (status = 'done' AND finished_at < now() - make_interval(secs => $1))
+   OR (status = 'dead' AND finished_at < now() - make_interval(secs => $2))`)).
+		WithArgs(float64(86400), float64(604800)).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	n, err := r.PurgeWebhookJobs(context.Background(), 24*time.Hour, 7*24*time.Hour)
+	if err != nil || n != 3 {
+		t.Fatalf("expected 3 purged rows, got n=%d err=%v", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestEnqueueWebhookJobsSkipsSeenEventIDs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+
+	r := New(db)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO webhook_event_ids(event_id, expires_at)`)).
+		WithArgs("ev1", "dest", `{"type":"message"}`, float64(3600)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO webhook_event_ids(event_id, expires_at)`)).
+		WithArgs("ev2", "dest", `{"type":"postback"}`, float64(3600)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	queued, err := r.EnqueueWebhookJobs(context.Background(), "dest", []WebhookJobParams{
+		{EventID: "ev1", Payload: []byte(`{"type":"message"}`)},
+		{EventID: "ev2", Payload: []byte(`{"type":"postback"}`)},
+	}, time.Hour)
+	if err != nil {
+		t.Fatalf("EnqueueWebhookJobs failed: %v", err)
+	}
+	if queued != 1 {
+		t.Fatalf("queued = %d, want 1", queued)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestReserveLinePushesOverBudget(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+
+	r := New(db)
+	// 予算を超える更新は WHERE で弾かれて行が返らない
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO line_push_usage(month, messages)`)).
+		WithArgs(2, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"messages"}))
+
+	ok, err := r.ReserveLinePushes(context.Background(), 2, 100)
+	if err != nil || ok {
+		t.Fatalf("expected budget exceeded, got ok=%v err=%v", ok, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestUpdateTaskRenameRewritesEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+
+	r := New(db)
+	key := "食器洗い"
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT t.house_id, t.task_key`)).
+		WithArgs("g1", int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"house_id", "task_key"}).AddRow(int64(1), "皿洗い"))
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE tasks t SET`)).
+		WithArgs(int64(5), &key, nil, nil, nil, nil, nil, nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"task_key"}).AddRow(key))
+	// 過去の記録も新しい名前で引けるように付け替える
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE events SET task_key = $3 WHERE house_id = $1 AND task_key = $2`)).
+		WithArgs(int64(1), "皿洗い", key).
+		WillReturnResult(sqlmock.NewResult(0, 12))
+	// 辞書の版を上げて、ほかのインスタンスのキャッシュを古くする
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE houses SET catalog_version = catalog_version + 1`)).
+		WithArgs("g1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := r.UpdateTask(context.Background(), UpdateTaskParams{ExtGroupID: "g1", TaskID: 5, Key: &key}); err != nil {
+		t.Fatalf("UpdateTask failed: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
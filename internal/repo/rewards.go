@@ -0,0 +1,317 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// 交換の状態（redemptions.status）
+const (
+	RedemptionPending  = "pending"
+	RedemptionApproved = "approved"
+	RedemptionRejected = "rejected"
+)
+
+var (
+	ErrNoRewardFound      = errors.New("no reward found")
+	ErrRewardConflict     = errors.New("reward conflict")
+	ErrNoRedemptionFound  = errors.New("no redemption found")
+	ErrRedemptionDecided  = errors.New("redemption already decided")
+	ErrInsufficientPoints = errors.New("insufficient points")
+)
+
+type RewardRow struct {
+	ID       int64
+	Name     string
+	Cost     float64
+	Archived bool
+}
+
+// UpdateRewardParams nilの項目は変更しない
+type UpdateRewardParams struct {
+	ExtGroupID string
+	ID         int64
+	Name       *string
+	Cost       *float64
+	Archived   *bool
+}
+
+// WalletRow メンバーのポイントの出入り。使えるポイントは Earned - Spent - Reserved
+type WalletRow struct {
+	Earned   float64 // 記録の合計
+	Spent    float64 // 承認済みの交換
+	Reserved float64 // 申請中の交換
+}
+
+// RedemptionRow 交換の記録。DecidedByは承認/却下したメンバー
+type RedemptionRow struct {
+	ID            int64
+	RewardID      int64
+	RewardName    string
+	Cost          float64
+	ExtUserID     string
+	Name          string
+	Status        string
+	DecidedBy     string
+	DecidedByName string
+	RequestedAt   time.Time
+	DecidedAt     *time.Time
+}
+
+// ListRewards ハウスのごほうびを安い順に返す
+func (r *Repo) ListRewards(ctx context.Context, extGroupID string, includeArchived bool) ([]RewardRow, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT rw.id, rw.name, rw.cost, rw.archived_at IS NOT NULL
+FROM rewards rw
+JOIN houses h ON h.id = rw.house_id
+WHERE h.ext_group_id = $1 AND ($2 OR rw.archived_at IS NULL)
+ORDER BY rw.cost, rw.id
+`, extGroupID, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []RewardRow
+	for rows.Next() {
+		var row RewardRow
+		if err := rows.Scan(&row.ID, &row.Name, &row.Cost, &row.Archived); err != nil {
+			return nil, err
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+// InsertReward ごほうびを追加し、IDを返す
+func (r *Repo) InsertReward(ctx context.Context, extGroupID, name string, cost float64) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	houseID, err := r.upsertHouse(ctx, tx, extGroupID)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+INSERT INTO rewards(house_id, name, cost) VALUES($1, $2, $3) RETURNING id
+`, houseID, name, cost).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, ErrRewardConflict
+		}
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// UpdateReward ごほうびの名前・ポイント・アーカイブ状態を更新する
+func (r *Repo) UpdateReward(ctx context.Context, p UpdateRewardParams) error {
+	res, err := r.db.ExecContext(ctx, `
+UPDATE rewards rw SET
+  name        = COALESCE($3, rw.name),
+  cost        = COALESCE($4, rw.cost),
+  archived_at = CASE
+                  WHEN $5::boolean IS NULL THEN rw.archived_at
+                  WHEN $5::boolean THEN COALESCE(rw.archived_at, now())
+                  ELSE NULL
+                END
+FROM houses h
+WHERE h.id = rw.house_id AND h.ext_group_id = $1 AND rw.id = $2
+`, p.ExtGroupID, p.ID, p.Name, p.Cost, p.Archived)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrRewardConflict
+		}
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRewardFound
+	}
+	return nil
+}
+
+const walletQuery = `
+SELECT COALESCE((SELECT SUM(e.points) FROM events e
+                 WHERE e.house_id = m.house_id AND e.user_id = m.user_id AND ` + countedEvent + `), 0),
+       COALESCE((SELECT SUM(d.cost) FROM redemptions d
+                 WHERE d.house_id = m.house_id AND d.user_id = m.user_id AND d.status = 'approved'), 0),
+       COALESCE((SELECT SUM(d.cost) FROM redemptions d
+                 WHERE d.house_id = m.house_id AND d.user_id = m.user_id AND d.status = 'pending'), 0)
+FROM memberships m
+JOIN houses h ON h.id = m.house_id
+JOIN users u  ON u.id = m.user_id
+WHERE h.ext_group_id = $1 AND u.ext_user_id = $2
+`
+
+// Wallet メンバーのポイントの出入り（メンバーでなければ ErrNoMemberFound）
+func (r *Repo) Wallet(ctx context.Context, extGroupID, extUserID string) (WalletRow, error) {
+	var w WalletRow
+	err := r.db.QueryRowContext(ctx, walletQuery, extGroupID, extUserID).Scan(&w.Earned, &w.Spent, &w.Reserved)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return WalletRow{}, ErrNoMemberFound
+		}
+		return WalletRow{}, err
+	}
+	return w, nil
+}
+
+// CreateRedemption 使えるポイントが足りていれば交換を申請中で記録し、IDを返す。
+// 同じメンバーの申請が同時に来ても使いすぎないよう、メンバーの行をロックして確認する
+func (r *Repo) CreateRedemption(ctx context.Context, extGroupID, extUserID string, rewardID int64) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var houseID, userID int64
+	err = tx.QueryRowContext(ctx, `
+SELECT m.house_id, m.user_id
+FROM memberships m
+JOIN houses h ON h.id = m.house_id
+JOIN users u  ON u.id = m.user_id
+WHERE h.ext_group_id = $1 AND u.ext_user_id = $2
+FOR UPDATE OF m
+`, extGroupID, extUserID).Scan(&houseID, &userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoMemberFound
+		}
+		return 0, err
+	}
+
+	var cost float64
+	err = tx.QueryRowContext(ctx, `
+SELECT cost FROM rewards WHERE house_id = $1 AND id = $2 AND archived_at IS NULL
+`, houseID, rewardID).Scan(&cost)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRewardFound
+		}
+		return 0, err
+	}
+
+	var w WalletRow
+	if err := tx.QueryRowContext(ctx, walletQuery, extGroupID, extUserID).Scan(&w.Earned, &w.Spent, &w.Reserved); err != nil {
+		return 0, err
+	}
+	if w.Earned-w.Spent-w.Reserved < cost {
+		return 0, ErrInsufficientPoints
+	}
+
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+INSERT INTO redemptions(house_id, reward_id, user_id, cost) VALUES($1, $2, $3, $4) RETURNING id
+`, houseID, rewardID, userID, cost).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+const redemptionColumns = `
+SELECT d.id, d.reward_id, rw.name, d.cost,
+       u.ext_user_id, COALESCE(u.display_name, substr(u.ext_user_id,1,6)),
+       d.status,
+       COALESCE(a.ext_user_id, ''), COALESCE(a.display_name, substr(a.ext_user_id,1,6), ''),
+       d.requested_at, d.decided_at
+FROM redemptions d
+JOIN houses h   ON h.id = d.house_id
+JOIN rewards rw ON rw.id = d.reward_id
+JOIN users u    ON u.id = d.user_id
+LEFT JOIN users a ON a.id = d.decided_by
+`
+
+func scanRedemption(sc rowScanner) (RedemptionRow, error) {
+	var row RedemptionRow
+	var decided sql.NullTime
+	err := sc.Scan(&row.ID, &row.RewardID, &row.RewardName, &row.Cost, &row.ExtUserID, &row.Name,
+		&row.Status, &row.DecidedBy, &row.DecidedByName, &row.RequestedAt, &decided)
+	if err != nil {
+		return RedemptionRow{}, err
+	}
+	if decided.Valid {
+		row.DecidedAt = &decided.Time
+	}
+	return row, nil
+}
+
+// ListRedemptions ハウスの交換を新しい順に返す（status/extUserIDが空なら絞り込まない）
+func (r *Repo) ListRedemptions(ctx context.Context, extGroupID, status, extUserID string, limit int) ([]RedemptionRow, error) {
+	rows, err := r.db.QueryContext(ctx, redemptionColumns+`
+WHERE h.ext_group_id = $1 AND ($2 = '' OR d.status = $2) AND ($3 = '' OR u.ext_user_id = $3)
+ORDER BY d.id DESC
+LIMIT $4
+`, extGroupID, status, extUserID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []RedemptionRow
+	for rows.Next() {
+		row, err := scanRedemption(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+// GetRedemption ハウスの交換をIDで取得する
+func (r *Repo) GetRedemption(ctx context.Context, extGroupID string, id int64) (RedemptionRow, error) {
+	row, err := scanRedemption(r.db.QueryRowContext(ctx, redemptionColumns+`
+WHERE h.ext_group_id = $1 AND d.id = $2
+`, extGroupID, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return RedemptionRow{}, ErrNoRedemptionFound
+		}
+		return RedemptionRow{}, err
+	}
+	return row, nil
+}
+
+// DecideRedemption 申請中の交換を承認/却下する（申請中でなければ ErrRedemptionDecided）
+func (r *Repo) DecideRedemption(ctx context.Context, extGroupID string, id int64, deciderExtUserID, status string) error {
+	res, err := r.db.ExecContext(ctx, `
+UPDATE redemptions d SET
+  status     = $4,
+  decided_by = (SELECT u.id FROM users u WHERE u.ext_user_id = $3),
+  decided_at = now()
+FROM houses h
+WHERE h.id = d.house_id AND h.ext_group_id = $1 AND d.id = $2 AND d.status = 'pending'
+`, extGroupID, id, deciderExtUserID, status)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		if _, err := r.GetRedemption(ctx, extGroupID, id); err != nil {
+			return err
+		}
+		return ErrRedemptionDecided
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrNoScheduleFound = errors.New("no schedule found")
+
+// ScheduleRow 定期家事の定義と、同じタスクの最後の記録日時
+type ScheduleRow struct {
+	ID           int64
+	TaskKey      string
+	Rule         string
+	IntervalDays int
+	Weekday      *int // weekly のみ
+	AssigneeID   string
+	AssigneeName string
+	StartDate    time.Time  // 日付のみ（UTCの0時）
+	RemindedDue  *time.Time // 日付のみ（UTCの0時）
+	LastDoneAt   *time.Time
+}
+
+// InsertScheduleParams AssigneeExtUserIDが空なら担当なし
+type InsertScheduleParams struct {
+	ExtGroupID        string
+	TaskID            int64
+	Rule              string
+	IntervalDays      int
+	Weekday           *int
+	AssigneeExtUserID string
+	StartDate         time.Time
+}
+
+// scheduleColumns 最後に実施した日時は集計に入る記録（承認待ちを除く）から取る
+const scheduleColumns = `
+SELECT s.id, t.task_key, s.rule, s.interval_days, s.weekday,
+       COALESCE(u.ext_user_id, ''), COALESCE(u.display_name, substr(u.ext_user_id,1,6), ''),
+       s.start_date, s.reminded_due,
+       (SELECT max(e.performed_at) FROM events e
+        WHERE e.house_id = s.house_id AND e.task_key = t.task_key AND ` + countedEvent + `)
+FROM chore_schedules s
+JOIN houses h ON h.id = s.house_id
+JOIN tasks t  ON t.id = s.task_id
+LEFT JOIN users u ON u.id = s.assignee_user_id
+`
+
+func scanSchedule(sc rowScanner) (ScheduleRow, error) {
+	var row ScheduleRow
+	var weekday sql.NullInt16
+	var reminded, lastDone sql.NullTime
+	err := sc.Scan(&row.ID, &row.TaskKey, &row.Rule, &row.IntervalDays, &weekday,
+		&row.AssigneeID, &row.AssigneeName, &row.StartDate, &reminded, &lastDone)
+	if err != nil {
+		return ScheduleRow{}, err
+	}
+	if weekday.Valid {
+		wd := int(weekday.Int16)
+		row.Weekday = &wd
+	}
+	if reminded.Valid {
+		row.RemindedDue = &reminded.Time
+	}
+	if lastDone.Valid {
+		row.LastDoneAt = &lastDone.Time
+	}
+	return row, nil
+}
+
+// ListSchedules ハウスの定期家事を登録順に返す（アーカイブ済みタスクの分は除く）
+func (r *Repo) ListSchedules(ctx context.Context, extGroupID string) ([]ScheduleRow, error) {
+	rows, err := r.db.QueryContext(ctx, scheduleColumns+`
+WHERE h.ext_group_id = $1 AND t.archived_at IS NULL
+ORDER BY s.id
+`, extGroupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ScheduleRow
+	for rows.Next() {
+		row, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+// GetSchedule ハウスの定期家事をIDで取得する
+func (r *Repo) GetSchedule(ctx context.Context, extGroupID string, id int64) (ScheduleRow, error) {
+	row, err := scanSchedule(r.db.QueryRowContext(ctx, scheduleColumns+`
+WHERE h.ext_group_id = $1 AND s.id = $2
+`, extGroupID, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ScheduleRow{}, ErrNoScheduleFound
+		}
+		return ScheduleRow{}, err
+	}
+	return row, nil
+}
+
+// InsertSchedule 定期家事を登録する（担当者はハウスのメンバーであること）
+func (r *Repo) InsertSchedule(ctx context.Context, p InsertScheduleParams) (int64, error) {
+	var id int64
+	err := r.db.QueryRowContext(ctx, `
+INSERT INTO chore_schedules(house_id, task_id, rule, interval_days, weekday, assignee_user_id, start_date)
+SELECT h.id, t.id, $3, $4, $5,
+       (SELECT m.user_id FROM memberships m JOIN users u ON u.id = m.user_id
+        WHERE m.house_id = h.id AND u.ext_user_id = $6),
+       $7
+FROM houses h
+JOIN tasks t ON t.house_id = h.id AND t.id = $2 AND t.archived_at IS NULL
+WHERE h.ext_group_id = $1
+RETURNING id
+`, p.ExtGroupID, p.TaskID, p.Rule, p.IntervalDays, p.Weekday, p.AssigneeExtUserID, p.StartDate).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoTaskFound
+		}
+		return 0, err
+	}
+	return id, nil
+}
+
+// DeleteSchedule 定期家事を削除する
+func (r *Repo) DeleteSchedule(ctx context.Context, extGroupID string, id int64) error {
+	res, err := r.db.ExecContext(ctx, `
+DELETE FROM chore_schedules s
+USING houses h
+WHERE h.id = s.house_id AND h.ext_group_id = $1 AND s.id = $2
+`, extGroupID, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoScheduleFound
+	}
+	return nil
+}
+
+// ClaimScheduleReminder 期日dueの期限切れ通知を送る権利を取る（通知済み・ほかのインスタンスが送信中ならfalse）。
+// staleAfterより前に取ったまま終わっていない送信権は、落ちたインスタンスの分として取り直す
+func (r *Repo) ClaimScheduleReminder(ctx context.Context, id int64, due time.Time, staleAfter time.Duration) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+UPDATE chore_schedules SET reminding_due = $2, reminder_claimed_at = now()
+WHERE id = $1 AND reminded_due IS DISTINCT FROM $2::date
+  AND (reminding_due IS DISTINCT FROM $2::date
+       OR reminder_claimed_at < now() - make_interval(secs => $3))
+`, id, due, staleAfter.Seconds())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// CompleteScheduleReminder 送れた通知の期日を記録し、送信権を返す
+func (r *Repo) CompleteScheduleReminder(ctx context.Context, id int64, due time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+UPDATE chore_schedules SET reminded_due = $2, reminding_due = NULL, reminder_claimed_at = NULL
+WHERE id = $1 AND reminding_due = $2::date
+`, id, due)
+	return err
+}
+
+// ReleaseScheduleReminder 送信に失敗した通知の送信権を返し、次回送り直せるようにする
+func (r *Repo) ReleaseScheduleReminder(ctx context.Context, id int64, due time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+UPDATE chore_schedules SET reminding_due = NULL, reminder_claimed_at = NULL
+WHERE id = $1 AND reminding_due = $2::date
+`, id, due)
+	return err
+}
@@ -0,0 +1,103 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrNoShareFound = errors.New("no share found")
+
+// ShareRow メンバーの目標の分担。ValidFrom/ValidToは日付のみ（UTCの0時、nilは無期限）
+type ShareRow struct {
+	ID        int64
+	ExtUserID string
+	Name      string
+	Share     float64
+	ValidFrom *time.Time
+	ValidTo   *time.Time
+}
+
+type InsertShareParams struct {
+	ExtGroupID string
+	ExtUserID  string
+	Share      float64
+	ValidFrom  *time.Time
+	ValidTo    *time.Time
+}
+
+// ListShares ハウスの目標の分担をメンバー・開始日の順に返す
+func (r *Repo) ListShares(ctx context.Context, extGroupID string) ([]ShareRow, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT s.id, u.ext_user_id, COALESCE(u.display_name, substr(u.ext_user_id,1,6)),
+       s.share, s.valid_from, s.valid_to
+FROM member_shares s
+JOIN houses h ON h.id = s.house_id
+JOIN users u  ON u.id = s.user_id
+WHERE h.ext_group_id = $1
+ORDER BY u.id, s.valid_from NULLS FIRST, s.id
+`, extGroupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ShareRow
+	for rows.Next() {
+		var row ShareRow
+		var from, to sql.NullTime
+		if err := rows.Scan(&row.ID, &row.ExtUserID, &row.Name, &row.Share, &from, &to); err != nil {
+			return nil, err
+		}
+		if from.Valid {
+			row.ValidFrom = &from.Time
+		}
+		if to.Valid {
+			row.ValidTo = &to.Time
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+// InsertShare 目標の分担を登録する（メンバーでなければ ErrNoMemberFound）
+func (r *Repo) InsertShare(ctx context.Context, p InsertShareParams) (int64, error) {
+	var id int64
+	err := r.db.QueryRowContext(ctx, `
+INSERT INTO member_shares(house_id, user_id, share, valid_from, valid_to)
+SELECT m.house_id, m.user_id, $3, $4, $5
+FROM memberships m
+JOIN houses h ON h.id = m.house_id
+JOIN users u  ON u.id = m.user_id
+WHERE h.ext_group_id = $1 AND u.ext_user_id = $2
+RETURNING id
+`, p.ExtGroupID, p.ExtUserID, p.Share, p.ValidFrom, p.ValidTo).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoMemberFound
+		}
+		return 0, err
+	}
+	return id, nil
+}
+
+// DeleteShare 目標の分担を削除する
+func (r *Repo) DeleteShare(ctx context.Context, extGroupID string, id int64) error {
+	res, err := r.db.ExecContext(ctx, `
+DELETE FROM member_shares s
+USING houses h
+WHERE h.id = s.house_id AND h.ext_group_id = $1 AND s.id = $2
+`, extGroupID, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoShareFound
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package repo
+
+import (
+	"context"
+	"time"
+)
+
+// DailyPointsRow ユーザー・タスク・日（ハウスのタイムゾーン）ごとの合計
+type DailyPointsRow struct {
+	ExtUserID string
+	Name      string
+	TaskKey   string
+	Day       time.Time // 日付のみ（UTCの0時）
+	Points    float64
+	Count     int
+}
+
+// DailyPoints 期間内の記録を日単位で集計する（extUserIDが空なら全員）。
+// rolloverHourより前の記録は前日に数える
+func (r *Repo) DailyPoints(ctx context.Context, extGroupID, extUserID string, start, end time.Time, tz string, rolloverHour int) ([]DailyPointsRow, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT COALESCE(u.ext_user_id, ''),
+       COALESCE(u.display_name, substr(u.ext_user_id,1,6)) AS name,
+       e.task_key,
+       ((e.performed_at AT TIME ZONE $5) - make_interval(hours => $6::int))::date AS day,
+       COALESCE(SUM(e.points),0) AS pt,
+       COUNT(*)
+FROM events e
+JOIN users u  ON u.id = e.user_id
+JOIN houses h ON h.id = e.house_id
+WHERE h.ext_group_id = $1
+  AND ($2::text = '' OR u.ext_user_id = $2)
+  AND e.performed_at >= $3
+  AND e.performed_at < $4
+  AND `+countedEvent+`
+GROUP BY u.id, u.ext_user_id, u.display_name, e.task_key, day
+ORDER BY day, pt DESC
+`, extGroupID, extUserID, start, end, tz, rolloverHour)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []DailyPointsRow
+	for rows.Next() {
+		var row DailyPointsRow
+		if err := rows.Scan(&row.ExtUserID, &row.Name, &row.TaskKey, &row.Day, &row.Points, &row.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
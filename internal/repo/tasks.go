@@ -0,0 +1,382 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrNoTaskFound  = errors.New("no task found")
+	ErrNoAliasFound = errors.New("no alias found")
+	ErrTaskConflict = errors.New("task conflict")
+)
+
+// TaskSeed 新規ハウス作成時に投入するタスク定義
+type TaskSeed struct {
+	Key     string
+	Aliases []string
+	Points  float64
+}
+
+// TaskRow UnitMinutes/MaxUnitsは0で未設定
+type TaskRow struct {
+	ID          int64
+	Key         string
+	Points      float64
+	Scoring     string
+	UnitMinutes int
+	MaxUnits    int
+	Aliases     []string
+	Archived    bool
+	Rotation    string
+	Owner       string // 担当者の ext_user_id（未設定は空）
+}
+
+type InsertTaskParams struct {
+	ExtGroupID  string
+	Key         string
+	Points      float64
+	Scoring     string
+	UnitMinutes int
+	MaxUnits    int
+	Aliases     []string
+	Rotation    string
+	Owner       string // ハウスのメンバーの ext_user_id（空なら未設定）
+}
+
+// UpdateTaskParams nilの項目は変更しない（UnitMinutes/MaxUnitsは0で、Ownerは空文字でNULLに戻す）
+type UpdateTaskParams struct {
+	ExtGroupID  string
+	TaskID      int64
+	Key         *string
+	Points      *float64
+	Scoring     *string
+	UnitMinutes *int
+	MaxUnits    *int
+	Archived    *bool
+	Rotation    *string
+	Owner       *string
+}
+
+// isUniqueViolation 一意制約違反（23505）かどうか
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// SetDefaultTasks 新規ハウスに投入するデフォルト辞書を設定する
+func (r *Repo) SetDefaultTasks(seeds []TaskSeed) {
+	r.taskSeeds = append([]TaskSeed(nil), seeds...)
+}
+
+// upsertHouse ハウスを取得/作成し、新規作成時はデフォルト辞書を投入する
+func (r *Repo) upsertHouse(ctx context.Context, tx *sql.Tx, extGroupID string) (int64, error) {
+	var houseID int64
+	var inserted bool
+	err := tx.QueryRowContext(ctx, `
+INSERT INTO houses(ext_group_id) VALUES($1)
+ON CONFLICT(ext_group_id) DO UPDATE SET name=COALESCE(houses.name, EXCLUDED.ext_group_id)
+RETURNING id, (xmax = 0) AS inserted
+`, extGroupID).Scan(&houseID, &inserted)
+	if err != nil {
+		return 0, err
+	}
+	if inserted {
+		if err := seedTasks(ctx, tx, houseID, r.taskSeeds); err != nil {
+			return 0, err
+		}
+	}
+	return houseID, nil
+}
+
+func seedTasks(ctx context.Context, tx *sql.Tx, houseID int64, seeds []TaskSeed) error {
+	for i, seed := range seeds {
+		var taskID int64
+		err := tx.QueryRowContext(ctx, `
+INSERT INTO tasks(house_id, task_key, points, sort_order) VALUES($1,$2,$3,$4)
+ON CONFLICT(house_id, task_key) DO UPDATE SET points=tasks.points
+RETURNING id
+`, houseID, seed.Key, seed.Points, i+1).Scan(&taskID)
+		if err != nil {
+			return err
+		}
+		for _, alias := range seed.Aliases {
+			if alias == seed.Key {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, `
+INSERT INTO task_aliases(house_id, task_id, alias) VALUES($1,$2,$3)
+ON CONFLICT(house_id, alias) DO NOTHING
+`, houseID, taskID, alias); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// EnsureHouse ハウスが無ければ作成する（デフォルト辞書の投入を含む）
+func (r *Repo) EnsureHouse(ctx context.Context, extGroupID string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := r.upsertHouse(ctx, tx, extGroupID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListTasks ハウスのタスク辞書を並び順で返す
+func (r *Repo) ListTasks(ctx context.Context, extGroupID string, includeArchived bool) ([]TaskRow, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT t.id, t.task_key, t.points, t.scoring, COALESCE(t.unit_minutes, 0), COALESCE(t.max_units, 0),
+       t.archived_at IS NOT NULL AS archived, t.rotation, COALESCE(o.ext_user_id, ''), a.alias
+FROM tasks t
+JOIN houses h ON h.id = t.house_id
+LEFT JOIN users o ON o.id = t.owner_user_id
+LEFT JOIN task_aliases a ON a.task_id = t.id
+WHERE h.ext_group_id = $1 AND ($2 OR t.archived_at IS NULL)
+ORDER BY t.sort_order ASC, t.id ASC, a.alias ASC
+`, extGroupID, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []TaskRow
+	for rows.Next() {
+		var (
+			row   TaskRow
+			alias sql.NullString
+		)
+		if err := rows.Scan(&row.ID, &row.Key, &row.Points, &row.Scoring, &row.UnitMinutes, &row.MaxUnits, &row.Archived, &row.Rotation, &row.Owner, &alias); err != nil {
+			return nil, err
+		}
+		if len(out) == 0 || out[len(out)-1].ID != row.ID {
+			out = append(out, row)
+		}
+		if alias.Valid {
+			last := &out[len(out)-1]
+			last.Aliases = append(last.Aliases, alias.String)
+		}
+	}
+	return out, rows.Err()
+}
+
+// InsertTask タスクを辞書の末尾に追加し、IDを返す
+func (r *Repo) InsertTask(ctx context.Context, p InsertTaskParams) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	houseID, err := r.upsertHouse(ctx, tx, p.ExtGroupID)
+	if err != nil {
+		return 0, err
+	}
+
+	var taskID int64
+	err = tx.QueryRowContext(ctx, `
+INSERT INTO tasks(house_id, task_key, points, scoring, unit_minutes, max_units, rotation, owner_user_id, sort_order)
+SELECT $1, $2, $3, $4, NULLIF($5, 0), NULLIF($6, 0), $7,
+       (SELECT m.user_id FROM memberships m JOIN users u ON u.id = m.user_id
+        WHERE m.house_id = $1 AND u.ext_user_id = NULLIF($8, '')),
+       COALESCE(MAX(sort_order), 0) + 1 FROM tasks WHERE house_id = $1
+RETURNING id
+`, houseID, p.Key, p.Points, p.Scoring, p.UnitMinutes, p.MaxUnits, p.Rotation, p.Owner).Scan(&taskID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, ErrTaskConflict
+		}
+		return 0, err
+	}
+
+	for _, alias := range p.Aliases {
+		if _, err := tx.ExecContext(ctx, `
+INSERT INTO task_aliases(house_id, task_id, alias) VALUES($1,$2,$3)
+`, houseID, taskID, alias); err != nil {
+			if isUniqueViolation(err) {
+				return 0, ErrTaskConflict
+			}
+			return 0, err
+		}
+	}
+	if err := bumpCatalogVersion(ctx, tx, p.ExtGroupID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return taskID, nil
+}
+
+// UpdateTask タスク名・ポイント・採点ルール・アーカイブ状態・担当の決め方を更新する。
+// 名前を変えたら、過去の記録も同じトランザクションで新しい名前に付け替える
+// （定期家事の最後の実施日・担当の順番・回数バッジは記録のtask_keyでタスクと結びつくため）
+func (r *Repo) UpdateTask(ctx context.Context, p UpdateTaskParams) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var houseID int64
+	var oldKey string
+	err = tx.QueryRowContext(ctx, `
+SELECT t.house_id, t.task_key
+FROM tasks t
+JOIN houses h ON h.id = t.house_id
+WHERE h.ext_group_id = $1 AND t.id = $2
+FOR UPDATE OF t
+`, p.ExtGroupID, p.TaskID).Scan(&houseID, &oldKey)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoTaskFound
+		}
+		return err
+	}
+
+	var newKey string
+	err = tx.QueryRowContext(ctx, `
+UPDATE tasks t SET
+  task_key     = COALESCE($2::text, t.task_key),
+  points       = COALESCE($3::numeric, t.points),
+  scoring      = COALESCE($4::text, t.scoring),
+  unit_minutes = CASE WHEN $5::int IS NULL THEN t.unit_minutes ELSE NULLIF($5::int, 0) END,
+  max_units    = CASE WHEN $6::int IS NULL THEN t.max_units ELSE NULLIF($6::int, 0) END,
+  archived_at  = CASE
+                   WHEN $7::boolean IS NULL THEN t.archived_at
+                   WHEN $7::boolean THEN COALESCE(t.archived_at, now())
+                   ELSE NULL
+                 END,
+  rotation     = COALESCE($8::text, t.rotation),
+  owner_user_id = CASE
+                    WHEN $9::text IS NULL THEN t.owner_user_id
+                    ELSE (SELECT m.user_id FROM memberships m JOIN users u ON u.id = m.user_id
+                          WHERE m.house_id = t.house_id AND u.ext_user_id = NULLIF($9::text, ''))
+                  END
+WHERE t.id = $1
+RETURNING t.task_key
+`, p.TaskID, p.Key, p.Points, p.Scoring, p.UnitMinutes, p.MaxUnits, p.Archived, p.Rotation, p.Owner).Scan(&newKey)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrTaskConflict
+		}
+		return err
+	}
+
+	if newKey != oldKey {
+		if _, err := tx.ExecContext(ctx, `
+UPDATE events SET task_key = $3 WHERE house_id = $1 AND task_key = $2
+`, houseID, oldKey, newKey); err != nil {
+			return err
+		}
+	}
+	if err := bumpCatalogVersion(ctx, tx, p.ExtGroupID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// InsertTaskAlias タスクに別名を追加する
+func (r *Repo) InsertTaskAlias(ctx context.Context, extGroupID string, taskID int64, alias string) error {
+	return r.catalogWrite(ctx, extGroupID, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `
+INSERT INTO task_aliases(house_id, task_id, alias)
+SELECT t.house_id, t.id, $3
+FROM tasks t
+JOIN houses h ON h.id = t.house_id
+WHERE h.ext_group_id = $1 AND t.id = $2
+`, extGroupID, taskID, alias)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return ErrTaskConflict
+			}
+			return err
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			return ErrNoTaskFound
+		}
+		return nil
+	})
+}
+
+// DeleteTaskAlias タスクから別名を外す
+func (r *Repo) DeleteTaskAlias(ctx context.Context, extGroupID string, taskID int64, alias string) error {
+	return r.catalogWrite(ctx, extGroupID, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `
+DELETE FROM task_aliases a
+USING houses h
+WHERE h.id = a.house_id AND h.ext_group_id = $1 AND a.task_id = $2 AND a.alias = $3
+`, extGroupID, taskID, alias)
+		if err != nil {
+			return err
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			return ErrNoAliasFound
+		}
+		return nil
+	})
+}
+
+// MoveTaskAlias 別名の付け先を同じハウスの別タスクへ付け替える
+func (r *Repo) MoveTaskAlias(ctx context.Context, extGroupID, alias string, toTaskID int64) error {
+	return r.catalogWrite(ctx, extGroupID, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `
+UPDATE task_aliases a SET task_id = t.id
+FROM tasks t
+JOIN houses h ON h.id = t.house_id
+WHERE a.house_id = t.house_id AND h.ext_group_id = $1 AND a.alias = $2 AND t.id = $3
+`, extGroupID, alias, toTaskID)
+		if err != nil {
+			return err
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			return ErrNoAliasFound
+		}
+		return nil
+	})
+}
+
+// catalogWrite タスク辞書への書き込みをトランザクションで行い、同じトランザクションで辞書の版を上げる
+func (r *Repo) catalogWrite(ctx context.Context, extGroupID string, write func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := write(tx); err != nil {
+		return err
+	}
+	if err := bumpCatalogVersion(ctx, tx, extGroupID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// bumpCatalogVersion ハウスのタスク辞書の版を上げる（各インスタンスが持つ辞書のキャッシュを古くする）
+func bumpCatalogVersion(ctx context.Context, tx *sql.Tx, extGroupID string) error {
+	_, err := tx.ExecContext(ctx, `
+UPDATE houses SET catalog_version = catalog_version + 1 WHERE ext_group_id = $1
+`, extGroupID)
+	return err
+}
+
+// CatalogVersion ハウスのタスク辞書の版（ハウスが無ければ0）
+func (r *Repo) CatalogVersion(ctx context.Context, extGroupID string) (int64, error) {
+	var version int64
+	err := r.db.QueryRowContext(ctx, `SELECT catalog_version FROM houses WHERE ext_group_id = $1`, extGroupID).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return version, err
+}
@@ -0,0 +1,143 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// webhook_jobs.status
+const (
+	WebhookQueued  = "queued"
+	WebhookRunning = "running"
+	WebhookDone    = "done"
+	WebhookDead    = "dead"
+)
+
+// WebhookJobParams 保存するWebhookイベント（Payloadはイベント1件のJSON）
+type WebhookJobParams struct {
+	EventID string
+	Payload []byte
+}
+
+// WebhookJob 取り出したWebhookイベント。Attemptsは今回の分を含む試行回数
+type WebhookJob struct {
+	ID          int64
+	EventID     string
+	Destination string
+	Payload     []byte
+	Attempts    int
+}
+
+// EnqueueWebhookJobs 1回のWebhookで届いたイベントをまとめて保存する（全件保存か全件失敗）。
+// webhookEventIdはdedupTTLの間記録し、同じIDのイベント（LINEの再送）は保存しない。戻り値は保存した件数
+func (r *Repo) EnqueueWebhookJobs(ctx context.Context, destination string, jobs []WebhookJobParams, dedupTTL time.Duration) (int, error) {
+	if len(jobs) == 0 {
+		return 0, nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	queued := 0
+	for _, job := range jobs {
+		// 期限切れの記録は新しいイベントとして取り直す。IDのないイベントは常に保存する
+		result, err := tx.ExecContext(ctx, `
+WITH fresh AS (
+  INSERT INTO webhook_event_ids(event_id, expires_at)
+  SELECT $1, now() + make_interval(secs => $4) WHERE $1::text IS NOT NULL
+  ON CONFLICT (event_id) DO UPDATE SET received_at = now(), expires_at = EXCLUDED.expires_at
+    WHERE webhook_event_ids.expires_at <= now()
+  RETURNING event_id
+)
+INSERT INTO webhook_jobs(event_id, destination, payload)
+SELECT $1, $2, $3 WHERE $1::text IS NULL OR EXISTS (SELECT 1 FROM fresh)
+`, trimmedOrNil(&job.EventID), destination, string(job.Payload), dedupTTL.Seconds())
+		if err != nil {
+			return 0, err
+		}
+		if rows, _ := result.RowsAffected(); rows > 0 {
+			queued++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return queued, nil
+}
+
+// PurgeWebhookEventIDs 期限切れのwebhookEventIdの記録を削除し、件数を返す
+func (r *Repo) PurgeWebhookEventIDs(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM webhook_event_ids WHERE expires_at <= now()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// PurgeWebhookJobs 処理済みはdoneAfter、deadはdeadAfterより前に終わった行を削除し、件数を返す
+func (r *Repo) PurgeWebhookJobs(ctx context.Context, doneAfter, deadAfter time.Duration) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+DELETE FROM webhook_jobs
+WHERE (status = 'done' AND finished_at < now() - make_interval(secs => $1))
+   OR (status = 'dead' AND finished_at < now() - make_interval(secs => $2))
+`, doneAfter.Seconds(), deadAfter.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ClaimWebhookJob 処理待ちのイベントを1件取り出して running にする（なければ ok=false）。
+// staleAfterより前から running のままの行は、落ちたインスタンスの分として取り直す
+func (r *Repo) ClaimWebhookJob(ctx context.Context, staleAfter time.Duration) (WebhookJob, bool, error) {
+	var job WebhookJob
+	var eventID sql.NullString
+	err := r.db.QueryRowContext(ctx, `
+UPDATE webhook_jobs SET status = 'running', attempts = attempts + 1, locked_at = now()
+WHERE id = (
+    SELECT id FROM webhook_jobs
+    WHERE (status = 'queued' AND run_at <= now())
+       OR (status = 'running' AND locked_at < now() - make_interval(secs => $1))
+    ORDER BY run_at, id
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, event_id, destination, payload, attempts
+`, staleAfter.Seconds()).Scan(&job.ID, &eventID, &job.Destination, &job.Payload, &job.Attempts)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return WebhookJob{}, false, nil
+		}
+		return WebhookJob{}, false, err
+	}
+	job.EventID = eventID.String
+	return job, true, nil
+}
+
+// CompleteWebhookJob 処理済みにする
+func (r *Repo) CompleteWebhookJob(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+UPDATE webhook_jobs SET status = 'done', last_error = NULL, finished_at = now() WHERE id = $1
+`, id)
+	return err
+}
+
+// RetryWebhookJob 失敗したイベントをrunAt以降に再試行する
+func (r *Repo) RetryWebhookJob(ctx context.Context, id int64, runAt time.Time, lastErr error) error {
+	_, err := r.db.ExecContext(ctx, `
+UPDATE webhook_jobs SET status = 'queued', run_at = $2, locked_at = NULL, last_error = $3 WHERE id = $1
+`, id, runAt, lastErr.Error())
+	return err
+}
+
+// DeadLetterWebhookJob 再試行しても処理できないイベントを dead にして取り出さないようにする
+func (r *Repo) DeadLetterWebhookJob(ctx context.Context, id int64, lastErr error) error {
+	_, err := r.db.ExecContext(ctx, `
+UPDATE webhook_jobs SET status = 'dead', last_error = $2, finished_at = now() WHERE id = $1
+`, id, lastErr.Error())
+	return err
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"chores_contributor/internal/repo"
+)
+
+// バッジの種類（achievements.kind）
+const (
+	AchievementFirstReport    = "first_report"    // はじめての報告
+	AchievementStreak         = "streak"          // Threshold日連続で報告
+	AchievementTaskMilestone  = "task_milestone"  // 同じタスクをThreshold回
+	AchievementWeeklyChampion = "weekly_champion" // 週の1位（同点は全員）
+)
+
+var (
+	streakThresholds = []int{3, 7, 14, 30, 100}
+	taskMilestones   = []int{10, 50, 100, 500, 1000}
+)
+
+// streakLookbackDays 連続日数を数えるためにさかのぼる記録の日数
+const streakLookbackDays = 400
+
+// Achievement 獲得したバッジ。TaskKey/Threshold/PeriodStartは種類によって空
+type Achievement struct {
+	Kind        string
+	Code        string
+	TaskKey     string
+	Threshold   int
+	PeriodStart *time.Time // weekly_champion の週の初日
+	EarnedAt    time.Time
+}
+
+// AchievementReport メンバーの連続日数と獲得したバッジ（獲得順）
+type AchievementReport struct {
+	UserID        string
+	CurrentStreak int // 今日か昨日までの連続日数
+	BestStreak    int
+	Achievements  []Achievement
+}
+
+func achievementFromRow(row repo.AchievementRow, loc *time.Location) Achievement {
+	a := Achievement{
+		Kind:      row.Kind,
+		Code:      row.Code,
+		TaskKey:   row.TaskKey,
+		Threshold: row.Threshold,
+		EarnedAt:  row.EarnedAt.In(loc),
+	}
+	if row.PeriodStart != nil {
+		d := dateIn(*row.PeriodStart, loc)
+		a.PeriodStart = &d
+	}
+	return a
+}
+
+// streaks 記録のある日（新しい順の日付）から、todayか前日までの連続日数と最長の連続日数を数える
+func streaks(days []time.Time, today time.Time) (current, best int) {
+	run, latest := 0, 0
+	for i, d := range days {
+		if i > 0 && d.Equal(days[i-1].AddDate(0, 0, -1)) {
+			run++
+		} else {
+			run = 1
+		}
+		if run > best {
+			best = run
+		}
+		if run == i+1 { // いちばん新しい日から続いている
+			latest = run
+		}
+	}
+	if len(days) > 0 && !days[0].Before(today.AddDate(0, 0, -1)) {
+		current = latest
+	}
+	return current, best
+}
+
+// earnedAchievements 記録の状況から獲得条件を満たしているバッジ（獲得済みかどうかは問わない）
+func earnedAchievements(bestStreak int, counts []repo.TaskCountRow) []Achievement {
+	var out []Achievement
+	if len(counts) > 0 {
+		out = append(out, Achievement{Kind: AchievementFirstReport, Code: AchievementFirstReport})
+	}
+	for _, n := range streakThresholds {
+		if bestStreak >= n {
+			out = append(out, Achievement{Kind: AchievementStreak, Code: fmt.Sprintf("streak:%d", n), Threshold: n})
+		}
+	}
+	for _, c := range counts {
+		for _, n := range taskMilestones {
+			if c.Count >= n {
+				out = append(out, Achievement{
+					Kind:      AchievementTaskMilestone,
+					Code:      fmt.Sprintf("task:%s:%d", c.TaskKey, n),
+					TaskKey:   c.TaskKey,
+					Threshold: n,
+				})
+			}
+		}
+	}
+	return out
+}
+
+// userStreaks メンバーの連続日数（ハウスの日付で数える）
+func (s *Service) userStreaks(ctx context.Context, groupID, userID string, cal Calendar) (current, best int, err error) {
+	rows, err := s.rp.UserActiveDays(ctx, groupID, userID, cal.Loc.String(), cal.RolloverHour, streakLookbackDays)
+	if err != nil {
+		return 0, 0, err
+	}
+	days := make([]time.Time, 0, len(rows))
+	for _, d := range rows {
+		days = append(days, dateIn(d, cal.Loc))
+	}
+	current, best = streaks(days, cal.Date(time.Now()))
+	return current, best, nil
+}
+
+// CheckAchievements 報告のあとに呼び、新しく獲得したバッジを記録して返す
+func (s *Service) CheckAchievements(ctx context.Context, groupID, userID string) ([]Achievement, error) {
+	cal, err := s.HouseCalendar(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	_, best, err := s.userStreaks(ctx, groupID, userID, cal)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.rp.UserTaskCounts(ctx, groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	have, err := s.rp.ListAchievements(ctx, groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	owned := make(map[string]bool, len(have))
+	for _, row := range have {
+		owned[row.Code] = true
+	}
+
+	var out []Achievement
+	for _, a := range earnedAchievements(best, counts) {
+		if owned[a.Code] {
+			continue
+		}
+		row, ok, err := s.rp.InsertAchievement(ctx, repo.InsertAchievementParams{
+			ExtGroupID: groupID,
+			ExtUserID:  userID,
+			Kind:       a.Kind,
+			Code:       a.Code,
+			TaskKey:    a.TaskKey,
+			Threshold:  a.Threshold,
+		})
+		if err != nil {
+			return out, err
+		}
+		if ok { // 同時に届いた別の報告で獲得済みなら知らせない
+			out = append(out, achievementFromRow(row, cal.Loc))
+		}
+	}
+	return out, nil
+}
+
+// awardWeeklyChampions 週のまとめの1位（同点は全員）に週間チャンピオンを記録する
+func (s *Service) awardWeeklyChampions(ctx context.Context, recap WeeklyRecap) error {
+	for _, m := range recap.Members {
+		if m.Rank != 1 || m.Points <= 0 {
+			continue
+		}
+		start := recap.Start
+		_, _, err := s.rp.InsertAchievement(ctx, repo.InsertAchievementParams{
+			ExtGroupID:  recap.GroupID,
+			ExtUserID:   m.UserID,
+			Kind:        AchievementWeeklyChampion,
+			Code:        "champion:" + start.Format("2006-01-02"),
+			PeriodStart: &start,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UserAchievements メンバーの連続日数と獲得したバッジ（メンバーでなければ repo.ErrNoMemberFound）
+func (s *Service) UserAchievements(ctx context.Context, groupID, userID string) (AchievementReport, error) {
+	if _, err := s.rp.MemberRole(ctx, groupID, userID); err != nil {
+		return AchievementReport{}, err
+	}
+	cal, err := s.HouseCalendar(ctx, groupID)
+	if err != nil {
+		return AchievementReport{}, err
+	}
+	report := AchievementReport{UserID: userID}
+	report.CurrentStreak, report.BestStreak, err = s.userStreaks(ctx, groupID, userID, cal)
+	if err != nil {
+		return AchievementReport{}, err
+	}
+	rows, err := s.rp.ListAchievements(ctx, groupID, userID)
+	if err != nil {
+		return AchievementReport{}, err
+	}
+	report.Achievements = make([]Achievement, 0, len(rows))
+	for _, row := range rows {
+		report.Achievements = append(report.Achievements, achievementFromRow(row, cal.Loc))
+	}
+	return report, nil
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"chores_contributor/internal/repo"
+)
+
+// 次の担当の決め方
+const (
+	RotationLeastPoints = "least_points" // 直近の合計が目安より最も少ない人
+	RotationRoundRobin  = "round_robin"  // このタスクを最後にやったのが最も前の人
+	RotationFixed       = "fixed"        // 決まった担当者（メンバーでなければ least_points）
+)
+
+// AssignmentWindowDays 目安（均等割り）を計算する日数
+const AssignmentWindowDays = 28
+
+var ErrNoHouseMembers = errors.New("no house members")
+
+func validateRotation(rotation string) error {
+	switch rotation {
+	case RotationLeastPoints, RotationRoundRobin, RotationFixed:
+		return nil
+	}
+	return fmt.Errorf("%w: rotation must be one of %s, %s, %s", ErrInvalidTask, RotationLeastPoints, RotationRoundRobin, RotationFixed)
+}
+
+// checkTaskOwner 担当者（空なら未設定）がハウスのメンバーか確認する
+func (s *Service) checkTaskOwner(ctx context.Context, groupID, owner string) error {
+	if strings.TrimSpace(owner) == "" {
+		return nil
+	}
+	if _, err := s.rp.MemberRole(ctx, groupID, owner); err != nil {
+		if errors.Is(err, repo.ErrNoMemberFound) {
+			return fmt.Errorf("%w: owner is not a member of the house", ErrInvalidTask)
+		}
+		return err
+	}
+	return nil
+}
+
+// AssignmentCandidate Deficitは目安との差（正なら目安より少ない）
+type AssignmentCandidate struct {
+	UserID     string
+	Name       string
+	Points     float64
+	Deficit    float64
+	LastDoneAt *time.Time // このタスクを最後にやった日時
+}
+
+// Assignment タスクの次の担当の提案。Candidatesは選ばれやすい順で、先頭が担当
+type Assignment struct {
+	TaskKey    string
+	Rotation   string
+	UserID     string
+	Name       string
+	Fallback   bool // fixed の担当者がメンバーにいないため least_points で選んだ
+	Candidates []AssignmentCandidate
+}
+
+// AssignmentPlan Since以降の記録から計算した次の担当。FairShareは1人あたりの目安
+type AssignmentPlan struct {
+	Since       time.Time
+	FairShare   float64
+	Assignments []Assignment
+}
+
+// NextAssignments 各タスク（taskが空でなければそのタスクだけ）の次の担当を提案する
+func (s *Service) NextAssignments(ctx context.Context, groupID, task string) (AssignmentPlan, error) {
+	idx, err := s.taskIndex(ctx, groupID)
+	if err != nil {
+		return AssignmentPlan{}, err
+	}
+	defs := idx.defs
+	if task != "" {
+		def, err := resolveTask(idx, task)
+		if err != nil {
+			return AssignmentPlan{}, err
+		}
+		defs = []TaskDefinition{def}
+	}
+
+	cal, err := s.HouseCalendar(ctx, groupID)
+	if err != nil {
+		return AssignmentPlan{}, err
+	}
+	since := cal.At(cal.Date(time.Now()).AddDate(0, 0, 1-AssignmentWindowDays))
+	members, err := s.rp.MemberPoints(ctx, groupID, since)
+	if err != nil {
+		return AssignmentPlan{}, err
+	}
+	if len(members) == 0 {
+		return AssignmentPlan{}, ErrNoHouseMembers
+	}
+	lastDone, err := s.rp.TaskLastDone(ctx, groupID)
+	if err != nil {
+		return AssignmentPlan{}, err
+	}
+	return planAssignments(defs, members, lastDone, since, cal.Loc), nil
+}
+
+func planAssignments(defs []TaskDefinition, members []repo.MemberPointsRow, lastDone []repo.TaskLastDoneRow, since time.Time, loc *time.Location) AssignmentPlan {
+	plan := AssignmentPlan{Since: since, Assignments: make([]Assignment, 0, len(defs))}
+	var total float64
+	for _, m := range members {
+		total += m.Points
+	}
+	plan.FairShare = total / float64(len(members))
+
+	done := map[string]map[string]time.Time{}
+	for _, row := range lastDone {
+		if done[row.TaskKey] == nil {
+			done[row.TaskKey] = map[string]time.Time{}
+		}
+		done[row.TaskKey][row.ExtUserID] = row.LastDoneAt.In(loc)
+	}
+
+	for _, def := range defs {
+		candidates := make([]AssignmentCandidate, 0, len(members))
+		for _, m := range members {
+			c := AssignmentCandidate{UserID: m.ExtUserID, Name: m.Name, Points: m.Points, Deficit: plan.FairShare - m.Points}
+			if at, ok := done[def.Key][m.ExtUserID]; ok {
+				c.LastDoneAt = &at
+			}
+			candidates = append(candidates, c)
+		}
+		plan.Assignments = append(plan.Assignments, assignTask(def, candidates))
+	}
+	return plan
+}
+
+// assignTask 担当の決め方に従って候補を並べ、先頭を担当にする
+func assignTask(def TaskDefinition, candidates []AssignmentCandidate) Assignment {
+	a := Assignment{TaskKey: def.Key, Rotation: def.Rotation}
+	if a.Rotation == "" {
+		a.Rotation = RotationLeastPoints
+	}
+
+	// 同じ条件なら、このタスクを長くやっていない人を優先する
+	leastPoints := func(x, y AssignmentCandidate) bool {
+		if x.Deficit != y.Deficit {
+			return x.Deficit > y.Deficit
+		}
+		return doneBefore(x.LastDoneAt, y.LastDoneAt)
+	}
+	less := leastPoints
+	switch a.Rotation {
+	case RotationRoundRobin:
+		less = func(x, y AssignmentCandidate) bool {
+			if !sameTime(x.LastDoneAt, y.LastDoneAt) {
+				return doneBefore(x.LastDoneAt, y.LastDoneAt)
+			}
+			return x.Deficit > y.Deficit
+		}
+	case RotationFixed:
+		owner := -1
+		for i, c := range candidates {
+			if c.UserID == def.Owner {
+				owner = i
+			}
+		}
+		a.Fallback = owner < 0
+		less = func(x, y AssignmentCandidate) bool {
+			if x.UserID == def.Owner || y.UserID == def.Owner {
+				return x.UserID == def.Owner && y.UserID != def.Owner
+			}
+			return leastPoints(x, y)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return less(candidates[i], candidates[j]) })
+
+	a.Candidates = candidates
+	if len(candidates) > 0 {
+		a.UserID, a.Name = candidates[0].UserID, candidates[0].Name
+	}
+	return a
+}
+
+// doneBefore xの方が前にやった（一度もやっていない人が最も前）
+func doneBefore(x, y *time.Time) bool {
+	switch {
+	case x == nil:
+		return y != nil
+	case y == nil:
+		return false
+	}
+	return x.Before(*y)
+}
+
+func sameTime(x, y *time.Time) bool {
+	if x == nil || y == nil {
+		return x == nil && y == nil
+	}
+	return x.Equal(*y)
+}
@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"chores_contributor/internal/repo"
+)
+
+// balanceDefaultWeeks 期間を省略したときのバランスの週数（今週を含む）
+const balanceDefaultWeeks = 4
+
+const maxShare = 1000
+
+var ErrInvalidShare = errors.New("invalid share")
+
+// MemberShare メンバーの目標の分担。同じ週に有効な分担の比で按分する。
+// ValidFrom/ValidToはハウスの日付（nilは無期限）で、週の初日が範囲に入る週に使う
+type MemberShare struct {
+	ID        int64
+	UserID    string
+	Name      string
+	Share     float64
+	ValidFrom *time.Time
+	ValidTo   *time.Time
+}
+
+// ShareInput ValidFrom/ValidToはYYYY-MM-DD（省略時は無期限）
+type ShareInput struct {
+	User      string  `json:"user"`
+	Share     float64 `json:"share"`
+	ValidFrom string  `json:"valid_from,omitempty"`
+	ValidTo   string  `json:"valid_to,omitempty"`
+}
+
+func shareFromRow(row repo.ShareRow, loc *time.Location) MemberShare {
+	sh := MemberShare{ID: row.ID, UserID: row.ExtUserID, Name: row.Name, Share: row.Share}
+	if row.ValidFrom != nil {
+		d := dateIn(*row.ValidFrom, loc)
+		sh.ValidFrom = &d
+	}
+	if row.ValidTo != nil {
+		d := dateIn(*row.ValidTo, loc)
+		sh.ValidTo = &d
+	}
+	return sh
+}
+
+// appliesTo 週の初日weekStartがこの分担の有効期間に入るか
+func (sh MemberShare) appliesTo(weekStart time.Time) bool {
+	if sh.ValidFrom != nil && weekStart.Before(*sh.ValidFrom) {
+		return false
+	}
+	return sh.ValidTo == nil || !weekStart.After(*sh.ValidTo)
+}
+
+// overlaps 有効期間が重なるか
+func (sh MemberShare) overlaps(other MemberShare) bool {
+	if sh.ValidTo != nil && other.ValidFrom != nil && sh.ValidTo.Before(*other.ValidFrom) {
+		return false
+	}
+	return other.ValidTo == nil || sh.ValidFrom == nil || !other.ValidTo.Before(*sh.ValidFrom)
+}
+
+// parseShareDate YYYY-MM-DD をハウスの日付にする（空ならnil）
+func parseShareDate(name, raw string, loc *time.Location) (*time.Time, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	d, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s must be YYYY-MM-DD", ErrInvalidShare, name)
+	}
+	d = dateIn(d, loc)
+	return &d, nil
+}
+
+// ListShares ハウスの目標の分担を返す
+func (s *Service) ListShares(ctx context.Context, groupID string) ([]MemberShare, error) {
+	cal, err := s.HouseCalendar(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.rp.ListShares(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]MemberShare, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, shareFromRow(row, cal.Loc))
+	}
+	return out, nil
+}
+
+// CreateShare 目標の分担を登録する（同じメンバーの有効期間が重なる分担があればエラー）
+func (s *Service) CreateShare(ctx context.Context, groupID string, in ShareInput) (MemberShare, error) {
+	user := strings.TrimSpace(in.User)
+	if user == "" {
+		return MemberShare{}, fmt.Errorf("%w: user is required", ErrInvalidShare)
+	}
+	if in.Share <= 0 || in.Share > maxShare {
+		return MemberShare{}, fmt.Errorf("%w: share must be greater than 0 and at most %d", ErrInvalidShare, maxShare)
+	}
+	cal, err := s.HouseCalendar(ctx, groupID)
+	if err != nil {
+		return MemberShare{}, err
+	}
+	sh := MemberShare{UserID: user, Share: in.Share}
+	if sh.ValidFrom, err = parseShareDate("valid_from", in.ValidFrom, cal.Loc); err != nil {
+		return MemberShare{}, err
+	}
+	if sh.ValidTo, err = parseShareDate("valid_to", in.ValidTo, cal.Loc); err != nil {
+		return MemberShare{}, err
+	}
+	if sh.ValidFrom != nil && sh.ValidTo != nil && sh.ValidTo.Before(*sh.ValidFrom) {
+		return MemberShare{}, fmt.Errorf("%w: valid_from must not be after valid_to", ErrInvalidShare)
+	}
+
+	existing, err := s.ListShares(ctx, groupID)
+	if err != nil {
+		return MemberShare{}, err
+	}
+	for _, other := range existing {
+		if other.UserID == user && sh.overlaps(other) {
+			return MemberShare{}, fmt.Errorf("%w: overlaps share %d of the same member", ErrInvalidShare, other.ID)
+		}
+	}
+
+	id, err := s.rp.InsertShare(ctx, repo.InsertShareParams{
+		ExtGroupID: groupID,
+		ExtUserID:  user,
+		Share:      in.Share,
+		ValidFrom:  sh.ValidFrom,
+		ValidTo:    sh.ValidTo,
+	})
+	if err != nil {
+		if errors.Is(err, repo.ErrNoMemberFound) {
+			return MemberShare{}, fmt.Errorf("%w: user is not a member of the house", ErrInvalidShare)
+		}
+		return MemberShare{}, err
+	}
+	sh.ID = id
+	return sh, nil
+}
+
+// DeleteShare 目標の分担を削除する
+func (s *Service) DeleteShare(ctx context.Context, groupID string, id int64) error {
+	return s.rp.DeleteShare(ctx, groupID, id)
+}
+
+// BalanceMember Debtは目標に対して足りないポイント（負なら目標より多くやっている）
+type BalanceMember struct {
+	UserID      string
+	Name        string
+	Points      float64
+	Share       float64 // 実績の割合（0〜1）
+	TargetShare float64 // 目標の割合（0〜1）
+	Expected    float64 // 目標の割合で按分したポイント
+	Debt        float64
+}
+
+// BalanceWeek 1週分。MembersのDebtはその週までの持ち越し
+type BalanceWeek struct {
+	Start   time.Time
+	End     time.Time // 含まない
+	Total   float64
+	Members []BalanceMember
+}
+
+// Balance 期間内の実績と目標の分担。Targetedがfalseなら目標未設定で均等に按分した
+type Balance struct {
+	Start    time.Time
+	End      time.Time // 含まない
+	Total    float64
+	Targeted bool
+	Members  []BalanceMember
+	Weeks    []BalanceWeek
+}
+
+// Balance from〜to（ハウスの日付、週単位に広げる）の実績と目標の分担を週ごとに比べる。
+// 両方ゼロ値なら今週を含む直近4週
+func (s *Service) Balance(ctx context.Context, groupID string, from, to time.Time) (Balance, error) {
+	cal, err := s.HouseCalendar(ctx, groupID)
+	if err != nil {
+		return Balance{}, err
+	}
+	if from.IsZero() && to.IsZero() {
+		to = cal.Date(time.Now())
+		from = cal.PeriodStartDate(to, GranularityWeek).AddDate(0, 0, -7*(balanceDefaultWeeks-1))
+	}
+	start := cal.PeriodStartDate(dateIn(from, cal.Loc), GranularityWeek)
+	end := nextPeriod(cal.PeriodStartDate(dateIn(to, cal.Loc), GranularityWeek), GranularityWeek)
+	if !start.Before(end) {
+		return Balance{}, fmt.Errorf("%w: from must not be after to", ErrInvalidSummary)
+	}
+
+	sum, err := s.Summary(ctx, groupID, SummaryQuery{From: start, To: end.AddDate(0, 0, -1), Granularity: GranularityWeek})
+	if err != nil {
+		return Balance{}, err
+	}
+	shares, err := s.ListShares(ctx, groupID)
+	if err != nil {
+		return Balance{}, err
+	}
+	members, err := s.rp.MemberPoints(ctx, groupID, cal.At(start))
+	if err != nil {
+		return Balance{}, err
+	}
+	return buildBalance(sum, members, shares), nil
+}
+
+// PeriodBalance refを含む期間（week/month/year）のバランス
+func (s *Service) PeriodBalance(ctx context.Context, groupID, unit string, ref time.Time) (Balance, error) {
+	cal, err := s.HouseCalendar(ctx, groupID)
+	if err != nil {
+		return Balance{}, err
+	}
+	from, to := cal.DateRange(ref, unit)
+	return s.Balance(ctx, groupID, from, to)
+}
+
+// buildBalance 週ごとの合計を目標の分担で按分し、差を翌週に持ち越す。
+// 有効な分担がない週は全員均等、分担のあるメンバーがいる週は分担のないメンバーの目標を0とする
+func buildBalance(sum Summary, members []repo.MemberPointsRow, shares []MemberShare) Balance {
+	bal := Balance{Start: sum.Start, End: sum.End, Total: sum.Total}
+
+	type member struct{ id, name string }
+	var order []member
+	seen := map[string]bool{}
+	for _, m := range members {
+		order = append(order, member{m.ExtUserID, m.Name})
+		seen[m.ExtUserID] = true
+	}
+	for _, u := range sum.Users { // 記録はあるがもうメンバーではない人
+		if !seen[u.UserID] {
+			order = append(order, member{u.UserID, u.Name})
+			seen[u.UserID] = true
+		}
+	}
+	if len(order) == 0 {
+		return bal
+	}
+
+	totals := make([]BalanceMember, len(order))
+	for i, m := range order {
+		totals[i] = BalanceMember{UserID: m.id, Name: m.name}
+	}
+	var lastTargets []float64
+	for _, b := range sum.Buckets {
+		weights := make([]float64, len(order))
+		var weightSum float64
+		for i, m := range order {
+			for _, sh := range shares {
+				if sh.UserID == m.id && sh.appliesTo(b.Start) {
+					weights[i] = sh.Share
+				}
+			}
+			weightSum += weights[i]
+		}
+		if weightSum > 0 {
+			bal.Targeted = true
+		} else {
+			for i := range weights {
+				weights[i] = 1
+			}
+			weightSum = float64(len(weights))
+		}
+
+		actual := map[string]float64{}
+		for _, u := range b.Users {
+			actual[u.UserID] = u.Points
+		}
+		week := BalanceWeek{Start: b.Start, End: b.End, Total: b.Points, Members: make([]BalanceMember, len(order))}
+		lastTargets = make([]float64, len(order))
+		for i := range order {
+			t := &totals[i]
+			lastTargets[i] = weights[i] / weightSum
+			expected := b.Points * lastTargets[i]
+			t.Points += actual[t.UserID]
+			t.Expected += expected
+			t.Debt += expected - actual[t.UserID]
+			week.Members[i] = BalanceMember{
+				UserID:      t.UserID,
+				Name:        t.Name,
+				Points:      actual[t.UserID],
+				TargetShare: lastTargets[i],
+				Expected:    expected,
+				Debt:        t.Debt,
+			}
+			if b.Points > 0 {
+				week.Members[i].Share = actual[t.UserID] / b.Points
+			}
+		}
+		bal.Weeks = append(bal.Weeks, week)
+	}
+
+	for i := range totals {
+		if bal.Total > 0 {
+			totals[i].Share = totals[i].Points / bal.Total
+			totals[i].TargetShare = totals[i].Expected / bal.Total
+		} else if lastTargets != nil {
+			totals[i].TargetShare = lastTargets[i]
+		}
+	}
+	bal.Members = totals
+	return bal
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultTimezone 新しいハウスのタイムゾーン（houses.timezone の既定値と同じ）
+const DefaultTimezone = "Asia/Tokyo"
+
+var (
+	defaultLoc = must(time.LoadLocation(DefaultTimezone))
+	locations  sync.Map // タイムゾーン名 → *time.Location
+)
+
+func must(loc *time.Location, err error) *time.Location {
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+// loadLocation time.LoadLocationの結果をキャッシュする
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// Calendar ハウスの暦。週・日の区切りの計算はすべてここを通す。
+// 「日付」は Loc の0時で表し、実際の区切り時刻は日付の RolloverHour 時になる
+type Calendar struct {
+	Loc          *time.Location
+	WeekStart    time.Weekday
+	RolloverHour int
+}
+
+// DefaultCalendar 月曜始まり・0時切り替え・DefaultTimezone
+func DefaultCalendar() Calendar {
+	return Calendar{Loc: defaultLoc, WeekStart: time.Monday}
+}
+
+// calendarFromSettings 読み込めないタイムゾーン名は既定に戻す（設定時に検証済みのため通常は起きない）
+func calendarFromSettings(hs HouseSettings) Calendar {
+	cal := DefaultCalendar()
+	if wd, ok := parseWeekday(hs.WeekStart); ok {
+		cal.WeekStart = wd
+	}
+	cal.RolloverHour = hs.DayRolloverHour
+	if loc, err := loadLocation(hs.Timezone); err == nil {
+		cal.Loc = loc
+	}
+	return cal
+}
+
+// Now ハウスのタイムゾーンでの現在時刻
+func (c Calendar) Now() time.Time {
+	return time.Now().In(c.Loc)
+}
+
+// HouseCalendar ハウスの設定から暦を作る
+func (s *Service) HouseCalendar(ctx context.Context, groupID string) (Calendar, error) {
+	hs, err := s.HouseSettings(ctx, groupID)
+	if err != nil {
+		return Calendar{}, err
+	}
+	return calendarFromSettings(hs), nil
+}
+
+// midnight tと同じ年月日の0時（tのロケーション）
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// dateIn tの年月日をlocの0時として読み替える
+func dateIn(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// Date tが属する日付（切り替え時刻より前なら前日）
+func (c Calendar) Date(t time.Time) time.Time {
+	return midnight(t.In(c.Loc).Add(-time.Duration(c.RolloverHour) * time.Hour))
+}
+
+// At 日付dateが始まる時刻
+func (c Calendar) At(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), c.RolloverHour, 0, 0, 0, c.Loc)
+}
+
+// PeriodStartDate 日付dateを含む期間（day/week/month/year）の最初の日付
+func (c Calendar) PeriodStartDate(date time.Time, unit string) time.Time {
+	date = midnight(date)
+	switch unit {
+	case GranularityWeek:
+		back := (int(date.Weekday()) - int(c.WeekStart) + 7) % 7
+		return date.AddDate(0, 0, -back)
+	case GranularityMonth:
+		return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
+	case PeriodYear:
+		return time.Date(date.Year(), 1, 1, 0, 0, 0, 0, date.Location())
+	}
+	return date
+}
+
+// nextPeriod 期間の最初の日付startから、次の期間の最初の日付
+func nextPeriod(start time.Time, unit string) time.Time {
+	switch unit {
+	case GranularityWeek:
+		return start.AddDate(0, 0, 7)
+	case GranularityMonth:
+		return start.AddDate(0, 1, 0)
+	case PeriodYear:
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// Range refを含む期間の開始と終了の時刻（終了は含まない）
+func (c Calendar) Range(ref time.Time, unit string) (time.Time, time.Time) {
+	start := c.PeriodStartDate(c.Date(ref), unit)
+	return c.At(start), c.At(nextPeriod(start, unit))
+}
+
+// DateRange refを含む期間の最初と最後の日付（両端を含む）
+func (c Calendar) DateRange(ref time.Time, unit string) (time.Time, time.Time) {
+	start := c.PeriodStartDate(c.Date(ref), unit)
+	return start, nextPeriod(start, unit).AddDate(0, 0, -1)
+}
+
+// WeekRange refを含む週の開始と終了の時刻
+func (c Calendar) WeekRange(ref time.Time) (time.Time, time.Time) {
+	return c.Range(ref, GranularityWeek)
+}
+
+var weekdayNames = [...]string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+func weekdayName(wd time.Weekday) string {
+	return weekdayNames[wd]
+}
+
+func parseWeekday(s string) (time.Weekday, bool) {
+	for i, name := range weekdayNames {
+		if s == name {
+			return time.Weekday(i), true
+		}
+	}
+	return 0, false
+}
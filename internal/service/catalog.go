@@ -0,0 +1,411 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"chores_contributor/internal/repo"
+)
+
+const maxTaskPoints = 100000
+
+var (
+	ErrInvalidTask   = errors.New("invalid task")
+	ErrTaskConflict  = errors.New("task conflict")
+	ErrNotHouseAdmin = errors.New("not a house admin")
+)
+
+// TaskConflictError 正規化後のタスク名/別名が他のタスクと衝突した
+type TaskConflictError struct {
+	Name    string
+	TaskKey string
+}
+
+func (e *TaskConflictError) Error() string {
+	if e.TaskKey == "" {
+		return fmt.Sprintf("%q is already registered", e.Name)
+	}
+	return fmt.Sprintf("%q is already used by task %q", e.Name, e.TaskKey)
+}
+
+func (e *TaskConflictError) Unwrap() error {
+	return ErrTaskConflict
+}
+
+type TaskInput struct {
+	Key         string   `json:"key"`
+	Points      float64  `json:"points"`
+	Scoring     string   `json:"scoring,omitempty"`
+	UnitMinutes int      `json:"unit_minutes,omitempty"`
+	MaxUnits    int      `json:"max_units,omitempty"`
+	Aliases     []string `json:"aliases,omitempty"`
+	Rotation    string   `json:"rotation,omitempty"`
+	Owner       string   `json:"owner,omitempty"`
+}
+
+// TaskPatch nilの項目は変更しない（unit_minutes/max_unitsは0で、ownerは空文字で解除）
+type TaskPatch struct {
+	Key         *string  `json:"key,omitempty"`
+	Points      *float64 `json:"points,omitempty"`
+	Scoring     *string  `json:"scoring,omitempty"`
+	UnitMinutes *int     `json:"unit_minutes,omitempty"`
+	MaxUnits    *int     `json:"max_units,omitempty"`
+	Archived    *bool    `json:"archived,omitempty"`
+	Rotation    *string  `json:"rotation,omitempty"`
+	Owner       *string  `json:"owner,omitempty"`
+}
+
+func validateTaskPoints(pt float64) error {
+	if math.IsNaN(pt) || math.IsInf(pt, 0) || pt < 0 || pt > maxTaskPoints {
+		return fmt.Errorf("%w: points must be between 0 and %d", ErrInvalidTask, maxTaskPoints)
+	}
+	return nil
+}
+
+// validateTaskScoring 採点ルールの組み合わせを検証する
+func validateTaskScoring(scoring string, unitMinutes, maxUnits int) error {
+	switch scoring {
+	case ScoringFixed, ScoringPerUnit:
+	case ScoringPerMinutes:
+		if unitMinutes <= 0 {
+			return fmt.Errorf("%w: unit_minutes is required for scoring %q", ErrInvalidTask, ScoringPerMinutes)
+		}
+	default:
+		return fmt.Errorf("%w: scoring must be one of %s, %s, %s", ErrInvalidTask, ScoringFixed, ScoringPerMinutes, ScoringPerUnit)
+	}
+	if unitMinutes < 0 || unitMinutes > maxOptionMinutes {
+		return fmt.Errorf("%w: unit_minutes must be between 0 and %d", ErrInvalidTask, maxOptionMinutes)
+	}
+	if maxUnits < 0 {
+		return fmt.Errorf("%w: max_units must not be negative", ErrInvalidTask)
+	}
+	return nil
+}
+
+// catalogNames 正規化済みのタスク名/別名 → 所有タスク
+func catalogNames(defs []TaskDefinition) map[string]TaskDefinition {
+	out := make(map[string]TaskDefinition)
+	for _, def := range defs {
+		out[normalizeCategory(def.Key)] = def
+		for _, alias := range def.Aliases {
+			out[normalizeCategory(alias)] = def
+		}
+	}
+	return out
+}
+
+// checkTaskNames namesが既存の名前と衝突しないか確認する（exemptIDのタスク自身は除外）
+func checkTaskNames(defs []TaskDefinition, exemptID int64, names ...string) error {
+	used := catalogNames(defs)
+	seen := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		if _, dup := seen[name]; dup {
+			return &TaskConflictError{Name: name}
+		}
+		seen[name] = struct{}{}
+		if owner, ok := used[name]; ok && owner.ID != exemptID {
+			return &TaskConflictError{Name: name, TaskKey: owner.Key}
+		}
+	}
+	return nil
+}
+
+func findTask(defs []TaskDefinition, id int64) (TaskDefinition, bool) {
+	for _, def := range defs {
+		if def.ID == id {
+			return def, true
+		}
+	}
+	return TaskDefinition{}, false
+}
+
+// findTaskByKey 正規化済みキーでタスクを探す
+func findTaskByKey(defs []TaskDefinition, key string) (TaskDefinition, bool) {
+	for _, def := range defs {
+		if normalizeCategory(def.Key) == key {
+			return def, true
+		}
+	}
+	return TaskDefinition{}, false
+}
+
+// allTasks アーカイブ済みを含むハウスの辞書（キャッシュを使わない）
+func (s *Service) allTasks(ctx context.Context, groupID string) ([]TaskDefinition, error) {
+	if err := s.rp.EnsureHouse(ctx, groupID); err != nil {
+		return nil, err
+	}
+	rows, err := s.rp.ListTasks(ctx, groupID, true)
+	if err != nil {
+		return nil, err
+	}
+	return taskDefinitionsFromRows(rows), nil
+}
+
+// reloadTask 書き込み後にキャッシュを破棄し、最新のタスクを返す
+func (s *Service) reloadTask(ctx context.Context, groupID string, id int64) (TaskDefinition, error) {
+	s.catalogs.invalidate(groupID)
+	defs, err := s.allTasks(ctx, groupID)
+	if err != nil {
+		return TaskDefinition{}, err
+	}
+	def, ok := findTask(defs, id)
+	if !ok {
+		return TaskDefinition{}, repo.ErrNoTaskFound
+	}
+	return def, nil
+}
+
+func mapTaskWriteErr(err error, name string) error {
+	if errors.Is(err, repo.ErrTaskConflict) {
+		return &TaskConflictError{Name: name}
+	}
+	return err
+}
+
+// RequireHouseAdmin ハウス管理者（memberships.role=admin）でなければErrNotHouseAdminを返す
+func (s *Service) RequireHouseAdmin(ctx context.Context, groupID, userID string) error {
+	role, err := s.rp.MemberRole(ctx, groupID, userID)
+	if err != nil {
+		if errors.Is(err, repo.ErrNoMemberFound) {
+			return ErrNotHouseAdmin
+		}
+		return err
+	}
+	if role != repo.RoleAdmin {
+		return ErrNotHouseAdmin
+	}
+	return nil
+}
+
+// FindTask 名前か別名が完全一致するタスクを返す（管理操作用のためタイプミス補正はしない）
+func (s *Service) FindTask(ctx context.Context, groupID, name string) (TaskDefinition, error) {
+	idx, err := s.taskIndex(ctx, groupID)
+	if err != nil {
+		return TaskDefinition{}, err
+	}
+	if canonical, ok := idx.exact[normalizeCategory(name)]; ok {
+		return idx.defMap[normalizeCategory(canonical)], nil
+	}
+	return TaskDefinition{}, &TaskNotFoundError{Input: name}
+}
+
+// ListTasks ハウスのタスク辞書を返す（includeArchived=trueでアーカイブ済みも含む）
+func (s *Service) ListTasks(ctx context.Context, groupID string, includeArchived bool) ([]TaskDefinition, error) {
+	if !includeArchived {
+		return s.TaskDefinitions(ctx, groupID)
+	}
+	return s.allTasks(ctx, groupID)
+}
+
+// CreateTask タスクを追加する
+func (s *Service) CreateTask(ctx context.Context, groupID string, in TaskInput) (TaskDefinition, error) {
+	key := normalizeCategory(in.Key)
+	if key == "" {
+		return TaskDefinition{}, fmt.Errorf("%w: key is required", ErrInvalidTask)
+	}
+	if err := validateTaskPoints(in.Points); err != nil {
+		return TaskDefinition{}, err
+	}
+	if in.Scoring == "" {
+		in.Scoring = ScoringFixed
+	}
+	if err := validateTaskScoring(in.Scoring, in.UnitMinutes, in.MaxUnits); err != nil {
+		return TaskDefinition{}, err
+	}
+	if in.Rotation == "" {
+		in.Rotation = RotationLeastPoints
+	}
+	if err := validateRotation(in.Rotation); err != nil {
+		return TaskDefinition{}, err
+	}
+	if err := s.checkTaskOwner(ctx, groupID, in.Owner); err != nil {
+		return TaskDefinition{}, err
+	}
+	aliases := make([]string, 0, len(in.Aliases))
+	for _, alias := range in.Aliases {
+		normalized := normalizeCategory(alias)
+		if normalized == "" || normalized == key {
+			continue
+		}
+		aliases = append(aliases, normalized)
+	}
+
+	defs, err := s.allTasks(ctx, groupID)
+	if err != nil {
+		return TaskDefinition{}, err
+	}
+	if err := checkTaskNames(defs, 0, append([]string{key}, aliases...)...); err != nil {
+		return TaskDefinition{}, err
+	}
+
+	id, err := s.rp.InsertTask(ctx, repo.InsertTaskParams{
+		ExtGroupID:  groupID,
+		Key:         key,
+		Points:      in.Points,
+		Scoring:     in.Scoring,
+		UnitMinutes: in.UnitMinutes,
+		MaxUnits:    in.MaxUnits,
+		Aliases:     aliases,
+		Rotation:    in.Rotation,
+		Owner:       in.Owner,
+	})
+	if err != nil {
+		return TaskDefinition{}, mapTaskWriteErr(err, key)
+	}
+	return s.reloadTask(ctx, groupID, id)
+}
+
+// UpdateTask タスク名・ポイント・採点ルール・アーカイブ状態・担当の決め方を更新する
+func (s *Service) UpdateTask(ctx context.Context, groupID string, id int64, patch TaskPatch) (TaskDefinition, error) {
+	if patch.Points != nil {
+		if err := validateTaskPoints(*patch.Points); err != nil {
+			return TaskDefinition{}, err
+		}
+	}
+	if patch.Rotation != nil {
+		if err := validateRotation(*patch.Rotation); err != nil {
+			return TaskDefinition{}, err
+		}
+	}
+	if patch.Owner != nil {
+		if err := s.checkTaskOwner(ctx, groupID, *patch.Owner); err != nil {
+			return TaskDefinition{}, err
+		}
+	}
+
+	defs, err := s.allTasks(ctx, groupID)
+	if err != nil {
+		return TaskDefinition{}, err
+	}
+	current, ok := findTask(defs, id)
+	if !ok {
+		return TaskDefinition{}, repo.ErrNoTaskFound
+	}
+
+	params := repo.UpdateTaskParams{
+		ExtGroupID:  groupID,
+		TaskID:      id,
+		Points:      patch.Points,
+		Scoring:     patch.Scoring,
+		UnitMinutes: patch.UnitMinutes,
+		MaxUnits:    patch.MaxUnits,
+		Archived:    patch.Archived,
+		Rotation:    patch.Rotation,
+		Owner:       patch.Owner,
+	}
+
+	if patch.Scoring != nil || patch.UnitMinutes != nil || patch.MaxUnits != nil {
+		scoring, unitMinutes, maxUnits := current.Scoring, current.UnitMinutes, current.MaxUnits
+		if patch.Scoring != nil {
+			scoring = *patch.Scoring
+		}
+		if patch.UnitMinutes != nil {
+			unitMinutes = *patch.UnitMinutes
+		}
+		if patch.MaxUnits != nil {
+			maxUnits = *patch.MaxUnits
+		}
+		if err := validateTaskScoring(scoring, unitMinutes, maxUnits); err != nil {
+			return TaskDefinition{}, err
+		}
+	}
+
+	var key string
+	if patch.Key != nil {
+		key = normalizeCategory(*patch.Key)
+		if key == "" {
+			return TaskDefinition{}, fmt.Errorf("%w: key must not be empty", ErrInvalidTask)
+		}
+		if err := checkTaskNames(defs, id, key); err != nil {
+			return TaskDefinition{}, err
+		}
+		params.Key = &key
+	}
+
+	if err := s.rp.UpdateTask(ctx, params); err != nil {
+		return TaskDefinition{}, mapTaskWriteErr(err, key)
+	}
+	return s.reloadTask(ctx, groupID, id)
+}
+
+// ArchiveTask タスクをアーカイブする（過去の記録は残る）
+func (s *Service) ArchiveTask(ctx context.Context, groupID string, id int64) (TaskDefinition, error) {
+	archived := true
+	return s.UpdateTask(ctx, groupID, id, TaskPatch{Archived: &archived})
+}
+
+// AddTaskAlias タスクに別名を追加する
+func (s *Service) AddTaskAlias(ctx context.Context, groupID string, id int64, alias string) (TaskDefinition, error) {
+	normalized := normalizeCategory(alias)
+	if normalized == "" {
+		return TaskDefinition{}, fmt.Errorf("%w: alias is required", ErrInvalidTask)
+	}
+	defs, err := s.allTasks(ctx, groupID)
+	if err != nil {
+		return TaskDefinition{}, err
+	}
+	if _, ok := findTask(defs, id); !ok {
+		return TaskDefinition{}, repo.ErrNoTaskFound
+	}
+	if err := checkTaskNames(defs, 0, normalized); err != nil {
+		return TaskDefinition{}, err
+	}
+	if err := s.rp.InsertTaskAlias(ctx, groupID, id, normalized); err != nil {
+		return TaskDefinition{}, mapTaskWriteErr(err, normalized)
+	}
+	return s.reloadTask(ctx, groupID, id)
+}
+
+// storedAlias 入力を正規化して、ハウス内に保存されている別名と所有タスクを探す
+func storedAlias(defs []TaskDefinition, alias string) (string, TaskDefinition, bool) {
+	normalized := normalizeCategory(alias)
+	for _, def := range defs {
+		for _, a := range def.Aliases {
+			if normalizeCategory(a) == normalized {
+				return a, def, true
+			}
+		}
+	}
+	return "", TaskDefinition{}, false
+}
+
+// RemoveTaskAlias タスクから別名を外す
+func (s *Service) RemoveTaskAlias(ctx context.Context, groupID string, id int64, alias string) (TaskDefinition, error) {
+	defs, err := s.allTasks(ctx, groupID)
+	if err != nil {
+		return TaskDefinition{}, err
+	}
+	stored, owner, ok := storedAlias(defs, alias)
+	if !ok || owner.ID != id {
+		return TaskDefinition{}, repo.ErrNoAliasFound
+	}
+	if err := s.rp.DeleteTaskAlias(ctx, groupID, id, stored); err != nil {
+		return TaskDefinition{}, err
+	}
+	return s.reloadTask(ctx, groupID, id)
+}
+
+// MoveTaskAlias 別名を別のタスクへ付け替える
+func (s *Service) MoveTaskAlias(ctx context.Context, groupID, alias string, toID int64) (TaskDefinition, error) {
+	defs, err := s.allTasks(ctx, groupID)
+	if err != nil {
+		return TaskDefinition{}, err
+	}
+	target, ok := findTask(defs, toID)
+	if !ok {
+		return TaskDefinition{}, repo.ErrNoTaskFound
+	}
+	stored, _, ok := storedAlias(defs, alias)
+	if !ok {
+		return TaskDefinition{}, repo.ErrNoAliasFound
+	}
+	if normalizeCategory(stored) == normalizeCategory(target.Key) {
+		return TaskDefinition{}, &TaskConflictError{Name: stored, TaskKey: target.Key}
+	}
+	if err := s.rp.MoveTaskAlias(ctx, groupID, stored, toID); err != nil {
+		return TaskDefinition{}, err
+	}
+	return s.reloadTask(ctx, groupID, toID)
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const performedAtFutureSkew = 5 * time.Minute
+
+var ErrInvalidPerformedAt = errors.New("invalid performed_at")
+
+// PerformedAtError 実施日時が未来、またはハウスのさかのぼり上限より古い
+type PerformedAtError struct {
+	PerformedAt time.Time
+	LimitDays   int
+	Future      bool
+}
+
+func (e *PerformedAtError) Error() string {
+	if e.Future {
+		return fmt.Sprintf("performed_at %s is in the future", e.PerformedAt.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("performed_at %s is older than %d days", e.PerformedAt.Format(time.RFC3339), e.LimitDays)
+}
+
+func (e *PerformedAtError) Unwrap() error {
+	return ErrInvalidPerformedAt
+}
+
+// checkPerformedAt 実施日時が「limitDays日前の始まり」以降かつ未来でないことを確認する
+func checkPerformedAt(performed, now time.Time, limitDays int, cal Calendar) error {
+	if performed.After(now.Add(performedAtFutureSkew)) {
+		return &PerformedAtError{PerformedAt: performed, LimitDays: limitDays, Future: true}
+	}
+	earliest := cal.At(cal.Date(now).AddDate(0, 0, -limitDays))
+	if performed.Before(earliest) {
+		return &PerformedAtError{PerformedAt: performed, LimitDays: limitDays}
+	}
+	return nil
+}
+
+var (
+	dateSlashPattern   = regexp.MustCompile(`^(\d{1,2})/(\d{1,2})$`)
+	dateKanjiPattern   = regexp.MustCompile(`^(\d{1,2})月(\d{1,2})日$`)
+	daysAgoPattern     = regexp.MustCompile(`^(\d{1,2})日前$`)
+	defaultDateTokenHr = 12
+)
+
+// atClock tの日付のhour時。nowより未来ならnowに丸める
+func atClock(t time.Time, hour int, now time.Time) time.Time {
+	out := time.Date(t.Year(), t.Month(), t.Day(), hour, 0, 0, 0, t.Location())
+	if out.After(now) {
+		return now
+	}
+	return out
+}
+
+// ParseDateToken LINEメッセージ中の日付語（今日・昨日・おととい・今朝・昨夜・N日前・11/3・11月3日）を
+// 実施日時に変換する。日付のみの指定は正午、未来の月日は前年として扱う
+func ParseDateToken(token string, now time.Time) (time.Time, bool) {
+	s := normalizeCategory(token)
+	switch s {
+	case "今日", "きょう":
+		return now, true
+	case "昨日", "きのう":
+		return now.AddDate(0, 0, -1), true
+	case "おととい", "一昨日", "おとつい":
+		return now.AddDate(0, 0, -2), true
+	case "今朝", "けさ":
+		return atClock(now, 8, now), true
+	case "昨夜", "昨晩", "ゆうべ":
+		return atClock(now.AddDate(0, 0, -1), 21, now), true
+	}
+
+	if m := daysAgoPattern.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return now.AddDate(0, 0, -n), true
+	}
+
+	m := dateSlashPattern.FindStringSubmatch(s)
+	if m == nil {
+		m = dateKanjiPattern.FindStringSubmatch(s)
+	}
+	if m == nil {
+		return time.Time{}, false
+	}
+	month, _ := strconv.Atoi(m[1])
+	day, _ := strconv.Atoi(m[2])
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return time.Time{}, false
+	}
+	year := now.Year()
+	d := time.Date(year, time.Month(month), day, 0, 0, 0, 0, now.Location())
+	if d.Month() != time.Month(month) {
+		// 2/30 など存在しない日付
+		return time.Time{}, false
+	}
+	if d.After(midnight(now)) {
+		d = time.Date(year-1, time.Month(month), day, 0, 0, 0, 0, now.Location())
+		if d.Month() != time.Month(month) {
+			return time.Time{}, false
+		}
+	}
+	return atClock(d, defaultDateTokenHr, now), true
+}
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"chores_contributor/internal/repo"
+)
+
+const (
+	maxEventHistory = 50
+	maxPendingList  = 20
+)
+
+var (
+	ErrNotEventOwner     = errors.New("event belongs to another member")
+	ErrInvalidEventPatch = errors.New("invalid event patch")
+	ErrInvalidApproval   = errors.New("invalid approval")
+)
+
+// Event 記録済みの家事イベント。Seqはハウス内の通し番号（LINEで指定する短いID）
+type Event struct {
+	ID          int64
+	Seq         int64
+	GroupID     string
+	UserID      string
+	UserName    string
+	TaskKey     string
+	Option      *string
+	Points      float64
+	PerformedAt time.Time
+	CreatedAt   time.Time
+	Pending     bool // 承認待ち（集計に入らない）
+}
+
+// EventPatch nilの項目は変更しない。Optionに空文字を指定するとオプションを外す
+type EventPatch struct {
+	Task   *string `json:"task,omitempty"`
+	Option *string `json:"option,omitempty"`
+}
+
+func eventFromRow(row repo.EventRow, loc *time.Location) Event {
+	return Event{
+		ID:          row.ID,
+		Seq:         row.Seq,
+		GroupID:     row.ExtGroupID,
+		UserID:      row.ExtUserID,
+		UserName:    row.UserName,
+		TaskKey:     row.TaskKey,
+		Option:      row.TaskOption,
+		Points:      row.Points,
+		PerformedAt: row.PerformedAt.In(loc),
+		CreatedAt:   row.CreatedAt.In(loc),
+		Pending:     row.Status == repo.EventPending,
+	}
+}
+
+// houseEvent 日時をハウスのタイムゾーンに合わせたイベントを返す
+func (s *Service) houseEvent(ctx context.Context, row repo.EventRow) (Event, error) {
+	cal, err := s.HouseCalendar(ctx, row.ExtGroupID)
+	if err != nil {
+		return Event{}, err
+	}
+	return eventFromRow(row, cal.Loc), nil
+}
+
+// RecentEvents ユーザーの最近の記録を新しい順に返す
+func (s *Service) RecentEvents(ctx context.Context, groupID, userID string, limit int) ([]Event, error) {
+	if limit <= 0 || limit > maxEventHistory {
+		limit = maxEventHistory
+	}
+	cal, err := s.HouseCalendar(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.rp.ListUserEvents(ctx, groupID, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Event, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, eventFromRow(row, cal.Loc))
+	}
+	return out, nil
+}
+
+// GetEvent IDでイベントを返す
+func (s *Service) GetEvent(ctx context.Context, id int64) (Event, error) {
+	row, err := s.rp.GetEvent(ctx, id)
+	if err != nil {
+		return Event{}, err
+	}
+	return s.houseEvent(ctx, row)
+}
+
+// MemberEvent ハウス内の通し番号でイベントを返す。本人の記録かハウス管理者でなければErrNotEventOwner
+func (s *Service) MemberEvent(ctx context.Context, groupID, userID string, seq int64) (Event, error) {
+	row, err := s.rp.GetEventBySeq(ctx, groupID, seq)
+	if err != nil {
+		return Event{}, err
+	}
+	if row.ExtUserID != userID {
+		if err := s.RequireHouseAdmin(ctx, groupID, userID); err != nil {
+			if errors.Is(err, ErrNotHouseAdmin) {
+				return Event{}, ErrNotEventOwner
+			}
+			return Event{}, err
+		}
+	}
+	return s.houseEvent(ctx, row)
+}
+
+// CancelEvent 指定したイベントを取り消す（論理削除）
+func (s *Service) CancelEvent(ctx context.Context, id int64, actor repo.Actor) (CancelResult, error) {
+	deleted, err := s.rp.DeleteEvent(ctx, id, actor)
+	if err != nil {
+		return CancelResult{}, err
+	}
+	return CancelResult{TaskKey: deleted.TaskKey, Points: deleted.Points}, nil
+}
+
+// EditEvent イベントのタスク/オプションを付け替え、ハウスのタスク辞書でポイントを再計算する。
+// 承認制のハウスでポイントが増えたら承認待ちに戻る（承認後に修正して水増しできないように）
+func (s *Service) EditEvent(ctx context.Context, id int64, patch EventPatch, actor repo.Actor) (Event, error) {
+	if patch.Task == nil && patch.Option == nil {
+		return Event{}, fmt.Errorf("%w: task or option is required", ErrInvalidEventPatch)
+	}
+	if patch.Task != nil && strings.TrimSpace(*patch.Task) == "" {
+		return Event{}, fmt.Errorf("%w: task must not be empty", ErrInvalidEventPatch)
+	}
+
+	row, err := s.rp.GetEvent(ctx, id)
+	if err != nil {
+		return Event{}, err
+	}
+	option := row.TaskOption
+	if patch.Option != nil {
+		option = nil
+		if trimmed := strings.TrimSpace(*patch.Option); trimmed != "" {
+			option = &trimmed
+		}
+	}
+
+	canonical, points, err := s.scoreEditedTask(ctx, row, patch.Task, option)
+	if err != nil {
+		return Event{}, err
+	}
+	updated, err := s.rp.UpdateEvent(ctx, repo.UpdateEventParams{
+		ID:         id,
+		TaskKey:    canonical,
+		TaskOption: option,
+		Points:     points,
+		Actor:      actor,
+	})
+	if err != nil {
+		return Event{}, err
+	}
+	return s.houseEvent(ctx, updated)
+}
+
+// scoreEditedTask 修正後のタスクとポイントを決める。
+// タスクを付け替えるときは有効なタスクから解決し、オプションだけの修正では
+// 記録済みのタスク（アーカイブ済みでもよい）のまま計算し直す
+func (s *Service) scoreEditedTask(ctx context.Context, row repo.EventRow, task, option *string) (string, float64, error) {
+	if task != nil {
+		idx, err := s.taskIndex(ctx, row.ExtGroupID)
+		if err != nil {
+			return "", 0, err
+		}
+		canonical, points, _, err := scoreTask(idx, strings.TrimSpace(*task), option)
+		return canonical, points, err
+	}
+	defs, err := s.allTasks(ctx, row.ExtGroupID)
+	if err != nil {
+		return "", 0, err
+	}
+	def, ok := findTaskByKey(defs, row.TaskKey)
+	if !ok {
+		return "", 0, &TaskNotFoundError{Input: row.TaskKey}
+	}
+	return scoreDefinition(def, option)
+}
+
+// RestoreLastCancelled ユーザーの記録のうち最後に取り消されたものを元に戻す。
+// ほかの人が取り消した記録はハウス管理者でなければ戻せない（repo.ErrCancelledByOther）
+func (s *Service) RestoreLastCancelled(ctx context.Context, groupID, userID, source string) (Event, error) {
+	anyCanceller := true
+	if err := s.RequireHouseAdmin(ctx, groupID, userID); err != nil {
+		if !errors.Is(err, ErrNotHouseAdmin) {
+			return Event{}, err
+		}
+		anyCanceller = false
+	}
+	row, err := s.rp.RestoreLatestCancelled(ctx, groupID, userID, anyCanceller, repo.Actor{ExtUserID: userID, Source: source})
+	if err != nil {
+		return Event{}, err
+	}
+	return s.houseEvent(ctx, row)
+}
+
+// RestoreEvent 通し番号で取り消し済みの記録を元に戻す（ハウス管理者のみ。誰の記録でも戻せる）
+func (s *Service) RestoreEvent(ctx context.Context, groupID, userID string, seq int64, source string) (Event, error) {
+	if err := s.RequireHouseAdmin(ctx, groupID, userID); err != nil {
+		return Event{}, err
+	}
+	row, err := s.rp.RestoreEventBySeq(ctx, groupID, seq, repo.Actor{ExtUserID: userID, Source: source})
+	if err != nil {
+		return Event{}, err
+	}
+	return s.houseEvent(ctx, row)
+}
+
+// AuditEntry 記録の操作履歴（内容は操作後のもの）
+type AuditEntry struct {
+	Action    string
+	Actor     string
+	Source    string
+	TaskKey   string
+	Option    *string
+	Points    float64
+	CreatedAt time.Time
+}
+
+// EventAudit 記録の操作履歴を古い順に返す（取り消し済みの記録も含む）
+func (s *Service) EventAudit(ctx context.Context, id int64) ([]AuditEntry, error) {
+	rows, err := s.rp.ListEventAudit(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]AuditEntry, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, AuditEntry{
+			Action:    row.Action,
+			Actor:     row.Actor,
+			Source:    row.Source,
+			TaskKey:   row.TaskKey,
+			Option:    row.TaskOption,
+			Points:    row.Points,
+			CreatedAt: row.CreatedAt,
+		})
+	}
+	return out, nil
+}
+
+// PendingEvents ハウスの承認待ちの報告を古い順に返す（excludeUserIDの報告は除く。空なら全員分）
+func (s *Service) PendingEvents(ctx context.Context, groupID, excludeUserID string) ([]Event, error) {
+	cal, err := s.HouseCalendar(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.rp.ListPendingEvents(ctx, groupID, excludeUserID, maxPendingList)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Event, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, eventFromRow(row, cal.Loc))
+	}
+	return out, nil
+}
+
+// ApproveEvent 承認待ちの報告を承認する。承認できるのは報告した本人以外のハウスのメンバーのみ
+// （承認待ちでなければ repo.ErrNotPending）
+func (s *Service) ApproveEvent(ctx context.Context, id int64, actor repo.Actor) (Event, error) {
+	row, err := s.rp.GetEvent(ctx, id)
+	if err != nil {
+		return Event{}, err
+	}
+	return s.approveEvent(ctx, row, actor)
+}
+
+// ApproveEventBySeq ハウス内の通し番号を指定して承認待ちの報告を承認する
+func (s *Service) ApproveEventBySeq(ctx context.Context, groupID string, seq int64, actor repo.Actor) (Event, error) {
+	row, err := s.rp.GetEventBySeq(ctx, groupID, seq)
+	if err != nil {
+		return Event{}, err
+	}
+	return s.approveEvent(ctx, row, actor)
+}
+
+func (s *Service) approveEvent(ctx context.Context, row repo.EventRow, actor repo.Actor) (Event, error) {
+	if strings.TrimSpace(actor.ExtUserID) == "" {
+		return Event{}, fmt.Errorf("%w: user is required", ErrInvalidApproval)
+	}
+	if row.ExtUserID == actor.ExtUserID {
+		return Event{}, ErrSelfApproval
+	}
+	if _, err := s.rp.MemberRole(ctx, row.ExtGroupID, actor.ExtUserID); err != nil {
+		return Event{}, err
+	}
+	if err := s.rp.ApproveEvent(ctx, row.ID, actor); err != nil {
+		return Event{}, err
+	}
+	return s.GetEvent(ctx, row.ID)
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	ScoringFixed      = "fixed"       // 1回あたりのポイント（x2などの回数指定は掛け算）
+	ScoringPerMinutes = "per_minutes" // UnitMinutes分ごとのポイント
+	ScoringPerUnit    = "per_unit"    // 1単位（回/個）ごとのポイント
+
+	maxOptionCount   = 100
+	maxOptionMinutes = 24 * 60
+)
+
+var ErrInvalidOption = errors.New("invalid option")
+
+// OptionError 報告オプション（"15分" "x2" など）を解釈できなかった
+type OptionError struct {
+	Input  string
+	Reason string
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("invalid option %q: %s", e.Input, e.Reason)
+}
+
+func (e *OptionError) Unwrap() error {
+	return ErrInvalidOption
+}
+
+// TaskQuantity 報告オプションから読み取った量（0は指定なし）
+type TaskQuantity struct {
+	Minutes int
+	Count   int
+}
+
+var (
+	optionCountPattern    = regexp.MustCompile(`^(?:[x×*](\d+)|(\d+)(?:回|個|枚|本|x|×))$`)
+	optionDurationPattern = regexp.MustCompile(`^(?:(\d+(?:\.\d+)?)(?:時間|h|hr|hour|hours))?(?:(\d+)(?:分|m|min|mins|minutes?))?$`)
+)
+
+// LooksLikeOption 報告オプションとして解釈できる語か（"15分" "x2" "2回"）。
+// "2階トイレ掃除" のように数字で始まるだけのタスク名はオプションとみなさない
+func LooksLikeOption(s string) bool {
+	if strings.TrimSpace(normalizeCategory(s)) == "" {
+		return false
+	}
+	_, err := parseTaskOption(s)
+	return err == nil
+}
+
+// parseTaskOption "15分" "1時間" "1時間30分" "x2" "×3" "2回" を解釈する
+func parseTaskOption(input string) (TaskQuantity, error) {
+	s := strings.ToLower(strings.ReplaceAll(normalizeCategory(input), " ", ""))
+	if s == "" {
+		return TaskQuantity{}, nil
+	}
+
+	if m := optionCountPattern.FindStringSubmatch(s); m != nil {
+		digits := m[1] + m[2]
+		n, err := strconv.Atoi(digits)
+		if err != nil || n < 1 || n > maxOptionCount {
+			return TaskQuantity{}, &OptionError{Input: input, Reason: fmt.Sprintf("count must be between 1 and %d", maxOptionCount)}
+		}
+		return TaskQuantity{Count: n}, nil
+	}
+
+	if m := optionDurationPattern.FindStringSubmatch(s); m != nil && (m[1] != "" || m[2] != "") {
+		var minutes float64
+		if m[1] != "" {
+			h, err := strconv.ParseFloat(m[1], 64)
+			if err != nil {
+				return TaskQuantity{}, &OptionError{Input: input, Reason: "invalid hours"}
+			}
+			minutes += h * 60
+		}
+		if m[2] != "" {
+			mins, err := strconv.Atoi(m[2])
+			if err != nil {
+				return TaskQuantity{}, &OptionError{Input: input, Reason: "invalid minutes"}
+			}
+			minutes += float64(mins)
+		}
+		total := int(math.Round(minutes))
+		if total < 1 || total > maxOptionMinutes {
+			return TaskQuantity{}, &OptionError{Input: input, Reason: fmt.Sprintf("duration must be between 1 and %d minutes", maxOptionMinutes)}
+		}
+		return TaskQuantity{Minutes: total}, nil
+	}
+
+	return TaskQuantity{}, &OptionError{Input: input, Reason: "expected duration (15分, 1時間) or count (x2, 2回)"}
+}
+
+// taskPoints タスクの採点ルールに従ってポイントを計算する（小数第1位で丸め）
+func taskPoints(def TaskDefinition, q TaskQuantity, rawOption string) (float64, error) {
+	units := 1.0
+	if q.Count > 0 {
+		units = float64(q.Count)
+	}
+	switch def.Scoring {
+	case ScoringPerMinutes:
+		if q.Minutes > 0 && def.UnitMinutes > 0 {
+			units *= float64(q.Minutes) / float64(def.UnitMinutes)
+		}
+	case ScoringPerUnit:
+		if q.Minutes > 0 {
+			return 0, &OptionError{Input: rawOption, Reason: fmt.Sprintf("task %q is counted in units (x2, 2回)", def.Key)}
+		}
+	}
+	if def.MaxUnits > 0 && units > float64(def.MaxUnits) {
+		units = float64(def.MaxUnits)
+	}
+	return math.Round(def.Points*units*10) / 10, nil
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"chores_contributor/internal/repo"
+)
+
+// job_runs.job の値
+const (
+	JobWeeklyRecap    = "weekly_recap"
+	JobWeeklyChampion = "weekly_champion"
+)
+
+const (
+	// recapGracePeriod 週の区切りからこの時間を過ぎたら送らない（長く止まっていた後に古いまとめを送らない）
+	recapGracePeriod = 24 * time.Hour
+	recapMaxAttempts = 5
+	// recapStaleAfter 送信中のまま残った実行記録を、落ちたインスタンスの分として取り直すまでの時間
+	recapStaleAfter = 10 * time.Minute
+)
+
+type RecapMember struct {
+	UserID        string
+	Name          string
+	Rank          int // 同点は同順位
+	Points        float64
+	PrevPoints    float64 // 前の週のポイント
+	TopTask       string  // いちばんポイントの多かったタスク
+	TopTaskPoints float64
+}
+
+// WeeklyRecap 終わった週のまとめ（Start/Endはハウスの日付、Endも含む）
+type WeeklyRecap struct {
+	GroupID   string
+	Start     time.Time
+	End       time.Time
+	Total     float64
+	PrevTotal float64
+	Members   []RecapMember // 順位順
+}
+
+// RecapPusher まとめを送る。retryKeyは同じハウス・週の再送では同じ値になる
+type RecapPusher func(ctx context.Context, recap WeeklyRecap, retryKey string) error
+
+// recapWeek nowの直前に終わった週の最初の日付。区切りからrecapGracePeriodを過ぎていればok=false
+func recapWeek(cal Calendar, now time.Time) (time.Time, bool) {
+	start := cal.PeriodStartDate(cal.Date(now), GranularityWeek)
+	if now.Sub(cal.At(start)) > recapGracePeriod {
+		return time.Time{}, false
+	}
+	return start.AddDate(0, 0, -7), true
+}
+
+// championWeek nowの直前に終わった週の最初の日付。まとめと違い、区切りから時間が経っていても記録する
+func championWeek(cal Calendar, now time.Time) time.Time {
+	return cal.PeriodStartDate(cal.Date(now), GranularityWeek).AddDate(0, 0, -7)
+}
+
+// WeeklyRecap 日付weekStartから始まる週のまとめ（前の週との比較つき）
+func (s *Service) WeeklyRecap(ctx context.Context, groupID string, weekStart time.Time) (WeeklyRecap, error) {
+	cur, err := s.Summary(ctx, groupID, SummaryQuery{From: weekStart, To: weekStart.AddDate(0, 0, 6), Granularity: GranularityWeek})
+	if err != nil {
+		return WeeklyRecap{}, err
+	}
+	prev, err := s.Summary(ctx, groupID, SummaryQuery{From: weekStart.AddDate(0, 0, -7), To: weekStart.AddDate(0, 0, -1), Granularity: GranularityWeek})
+	if err != nil {
+		return WeeklyRecap{}, err
+	}
+	return buildWeeklyRecap(groupID, cur, prev), nil
+}
+
+func buildWeeklyRecap(groupID string, cur, prev Summary) WeeklyRecap {
+	recap := WeeklyRecap{
+		GroupID:   groupID,
+		Start:     cur.Start,
+		End:       cur.End.AddDate(0, 0, -1),
+		Total:     cur.Total,
+		PrevTotal: prev.Total,
+		Members:   make([]RecapMember, 0, len(cur.Users)),
+	}
+	prevPoints := make(map[string]float64, len(prev.Users))
+	for _, u := range prev.Users {
+		prevPoints[u.UserID] = u.Points
+	}
+	for i, u := range cur.Users {
+		m := RecapMember{
+			UserID:     u.UserID,
+			Name:       u.Name,
+			Rank:       i + 1,
+			Points:     u.Points,
+			PrevPoints: prevPoints[u.UserID],
+		}
+		if i > 0 && u.Points == cur.Users[i-1].Points {
+			m.Rank = recap.Members[i-1].Rank
+		}
+		if len(u.Tasks) > 0 {
+			m.TopTask = u.Tasks[0].TaskKey
+			m.TopTaskPoints = u.Tasks[0].Points
+		}
+		recap.Members = append(recap.Members, m)
+	}
+	return recap
+}
+
+// SendWeeklyRecaps 週の区切りを過ぎたLINEのハウスに、終わった週のまとめを送る。
+// job_runs に実行記録を残すので、複数インスタンスや再起動をまたいでも1ハウス・1週につき1回だけ送る
+func (s *Service) SendWeeklyRecaps(ctx context.Context, now time.Time, push RecapPusher) error {
+	houses, err := s.rp.ListLineHouses(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, h := range houses {
+		if err := s.sendWeeklyRecap(ctx, h, now, push); err != nil {
+			errs = append(errs, fmt.Errorf("group=%s: %w", h.ExtGroupID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Service) sendWeeklyRecap(ctx context.Context, h repo.HouseRow, now time.Time, push RecapPusher) error {
+	cal := calendarFromSettings(houseSettingsFromRepo(h.Settings))
+	week, ok := recapWeek(cal, now)
+	if !ok {
+		return nil
+	}
+	run, ok, err := s.rp.ClaimJobRun(ctx, repo.ClaimJobParams{
+		Job:         JobWeeklyRecap,
+		ExtGroupID:  h.ExtGroupID,
+		PeriodStart: cal.At(week),
+		MaxAttempts: recapMaxAttempts,
+		StaleAfter:  recapStaleAfter,
+	})
+	if err != nil || !ok {
+		return err
+	}
+
+	status := repo.JobDone
+	recap, err := s.WeeklyRecap(ctx, h.ExtGroupID, week)
+	switch {
+	case err != nil:
+		status = repo.JobFailed
+	case len(recap.Members) == 0:
+		// 誰も報告しなかった週は送らない
+		status = repo.JobSkipped
+	default:
+		if err = push(ctx, recap, run.RetryKey); err != nil {
+			status = repo.JobFailed
+		}
+	}
+	if ferr := s.rp.FinishJobRun(ctx, run.ID, status, err); ferr != nil && err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// AwardWeeklyChampions 週の区切りを過ぎたすべてのハウス（LINE以外も含む）で、終わった週の1位を週間チャンピオンに記録する。
+// まとめのプッシュとは別の実行記録にするので、LINEに送れなくても記録される
+func (s *Service) AwardWeeklyChampions(ctx context.Context, now time.Time) error {
+	houses, err := s.rp.ListHouses(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, h := range houses {
+		if err := s.awardHouseChampions(ctx, h, now); err != nil {
+			errs = append(errs, fmt.Errorf("group=%s: %w", h.ExtGroupID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Service) awardHouseChampions(ctx context.Context, h repo.HouseRow, now time.Time) error {
+	cal := calendarFromSettings(houseSettingsFromRepo(h.Settings))
+	week := championWeek(cal, now)
+	run, ok, err := s.rp.ClaimJobRun(ctx, repo.ClaimJobParams{
+		Job:         JobWeeklyChampion,
+		ExtGroupID:  h.ExtGroupID,
+		PeriodStart: cal.At(week),
+		MaxAttempts: recapMaxAttempts,
+		StaleAfter:  recapStaleAfter,
+	})
+	if err != nil || !ok {
+		return err
+	}
+
+	status := repo.JobDone
+	recap, err := s.WeeklyRecap(ctx, h.ExtGroupID, week)
+	switch {
+	case err != nil:
+		status = repo.JobFailed
+	case len(recap.Members) == 0:
+		status = repo.JobSkipped
+	default:
+		if err = s.awardWeeklyChampions(ctx, recap); err != nil {
+			status = repo.JobFailed
+		}
+	}
+	if ferr := s.rp.FinishJobRun(ctx, run.ID, status, err); ferr != nil && err == nil {
+		err = ferr
+	}
+	return err
+}
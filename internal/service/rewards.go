@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"chores_contributor/internal/repo"
+)
+
+const (
+	maxRewardCost         = 1000000
+	maxRewardNameLen      = 50
+	maxRedemptionList     = 50
+	defaultRedemptionList = 20
+)
+
+var (
+	ErrInvalidReward     = errors.New("invalid reward")
+	ErrRewardNotFound    = errors.New("reward not found")
+	ErrSelfApproval      = errors.New("cannot approve own request") // 交換や報告を申請した本人は承認できない
+	ErrInvalidRedemption = errors.New("invalid redemption")
+)
+
+type Reward struct {
+	ID       int64
+	Name     string
+	Cost     float64
+	Archived bool
+}
+
+type RewardInput struct {
+	Name string  `json:"name"`
+	Cost float64 `json:"cost"`
+}
+
+// RewardPatch nilの項目は変更しない
+type RewardPatch struct {
+	Name     *string  `json:"name,omitempty"`
+	Cost     *float64 `json:"cost,omitempty"`
+	Archived *bool    `json:"archived,omitempty"`
+}
+
+// Wallet 使えるポイント。ランキングの合計（Earned）とは別に、交換の分を差し引く
+type Wallet struct {
+	UserID   string
+	Earned   float64
+	Spent    float64 // 承認済みの交換
+	Reserved float64 // 申請中の交換（承認か却下まで使えない）
+	Balance  float64
+}
+
+// Redemption ごほうびとの交換。DecidedByは承認/却下したメンバー
+type Redemption struct {
+	ID            int64
+	RewardID      int64
+	Reward        string
+	Cost          float64
+	UserID        string
+	Name          string
+	Status        string
+	DecidedBy     string
+	DecidedByName string
+	RequestedAt   time.Time
+	DecidedAt     *time.Time
+}
+
+// RedemptionInput Rewardはごほうびの名前
+type RedemptionInput struct {
+	User   string `json:"user"`
+	Reward string `json:"reward"`
+}
+
+func rewardFromRow(row repo.RewardRow) Reward {
+	return Reward{ID: row.ID, Name: row.Name, Cost: row.Cost, Archived: row.Archived}
+}
+
+func redemptionFromRow(row repo.RedemptionRow, loc *time.Location) Redemption {
+	rd := Redemption{
+		ID:            row.ID,
+		RewardID:      row.RewardID,
+		Reward:        row.RewardName,
+		Cost:          row.Cost,
+		UserID:        row.ExtUserID,
+		Name:          row.Name,
+		Status:        row.Status,
+		DecidedBy:     row.DecidedBy,
+		DecidedByName: row.DecidedByName,
+		RequestedAt:   row.RequestedAt.In(loc),
+	}
+	if row.DecidedAt != nil {
+		at := row.DecidedAt.In(loc)
+		rd.DecidedAt = &at
+	}
+	return rd
+}
+
+func validateRewardName(name string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" || utf8.RuneCountInString(name) > maxRewardNameLen {
+		return "", fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidReward, maxRewardNameLen)
+	}
+	return name, nil
+}
+
+func validateRewardCost(cost float64) error {
+	if math.IsNaN(cost) || cost <= 0 || cost > maxRewardCost {
+		return fmt.Errorf("%w: cost must be greater than 0 and at most %d", ErrInvalidReward, maxRewardCost)
+	}
+	return nil
+}
+
+// ListRewards ハウスのごほうびを安い順に返す
+func (s *Service) ListRewards(ctx context.Context, groupID string, includeArchived bool) ([]Reward, error) {
+	rows, err := s.rp.ListRewards(ctx, groupID, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Reward, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, rewardFromRow(row))
+	}
+	return out, nil
+}
+
+// FindReward 名前（表記ゆれは正規化して比べる）でごほうびを探す
+func (s *Service) FindReward(ctx context.Context, groupID, name string) (Reward, error) {
+	rewards, err := s.ListRewards(ctx, groupID, false)
+	if err != nil {
+		return Reward{}, err
+	}
+	want := normalizeCategory(name)
+	for _, rw := range rewards {
+		if normalizeCategory(rw.Name) == want {
+			return rw, nil
+		}
+	}
+	return Reward{}, ErrRewardNotFound
+}
+
+// CreateReward ごほうびを追加する
+func (s *Service) CreateReward(ctx context.Context, groupID string, in RewardInput) (Reward, error) {
+	name, err := validateRewardName(in.Name)
+	if err != nil {
+		return Reward{}, err
+	}
+	if err := validateRewardCost(in.Cost); err != nil {
+		return Reward{}, err
+	}
+	id, err := s.rp.InsertReward(ctx, groupID, name, in.Cost)
+	if err != nil {
+		return Reward{}, err
+	}
+	return Reward{ID: id, Name: name, Cost: in.Cost}, nil
+}
+
+// UpdateReward ごほうびの名前・ポイント・アーカイブ状態を変更する
+func (s *Service) UpdateReward(ctx context.Context, groupID string, id int64, patch RewardPatch) (Reward, error) {
+	p := repo.UpdateRewardParams{ExtGroupID: groupID, ID: id, Cost: patch.Cost, Archived: patch.Archived}
+	if patch.Name != nil {
+		name, err := validateRewardName(*patch.Name)
+		if err != nil {
+			return Reward{}, err
+		}
+		p.Name = &name
+	}
+	if patch.Cost != nil {
+		if err := validateRewardCost(*patch.Cost); err != nil {
+			return Reward{}, err
+		}
+	}
+	if err := s.rp.UpdateReward(ctx, p); err != nil {
+		return Reward{}, err
+	}
+	rewards, err := s.ListRewards(ctx, groupID, true)
+	if err != nil {
+		return Reward{}, err
+	}
+	for _, rw := range rewards {
+		if rw.ID == id {
+			return rw, nil
+		}
+	}
+	return Reward{}, repo.ErrNoRewardFound
+}
+
+// UserWallet メンバーの使えるポイント（メンバーでなければ repo.ErrNoMemberFound）
+func (s *Service) UserWallet(ctx context.Context, groupID, userID string) (Wallet, error) {
+	row, err := s.rp.Wallet(ctx, groupID, userID)
+	if err != nil {
+		return Wallet{}, err
+	}
+	return Wallet{
+		UserID:   userID,
+		Earned:   row.Earned,
+		Spent:    row.Spent,
+		Reserved: row.Reserved,
+		Balance:  row.Earned - row.Spent - row.Reserved,
+	}, nil
+}
+
+// RequestRedemption ごほうびとの交換を申請する。ほかのメンバーが承認するまでポイントを確保しておく
+func (s *Service) RequestRedemption(ctx context.Context, groupID string, in RedemptionInput) (Redemption, error) {
+	user := strings.TrimSpace(in.User)
+	if user == "" || strings.TrimSpace(in.Reward) == "" {
+		return Redemption{}, fmt.Errorf("%w: user and reward are required", ErrInvalidRedemption)
+	}
+	rw, err := s.FindReward(ctx, groupID, in.Reward)
+	if err != nil {
+		return Redemption{}, err
+	}
+	id, err := s.rp.CreateRedemption(ctx, groupID, user, rw.ID)
+	if err != nil {
+		return Redemption{}, err
+	}
+	return s.GetRedemption(ctx, groupID, id)
+}
+
+// GetRedemption ハウスの交換をIDで取得する
+func (s *Service) GetRedemption(ctx context.Context, groupID string, id int64) (Redemption, error) {
+	cal, err := s.HouseCalendar(ctx, groupID)
+	if err != nil {
+		return Redemption{}, err
+	}
+	row, err := s.rp.GetRedemption(ctx, groupID, id)
+	if err != nil {
+		return Redemption{}, err
+	}
+	return redemptionFromRow(row, cal.Loc), nil
+}
+
+// ListRedemptions ハウスの交換を新しい順に返す（status/userIDが空なら絞り込まない）
+func (s *Service) ListRedemptions(ctx context.Context, groupID, status, userID string, limit int) ([]Redemption, error) {
+	switch status {
+	case "", repo.RedemptionPending, repo.RedemptionApproved, repo.RedemptionRejected:
+	default:
+		return nil, fmt.Errorf("%w: status must be one of %s, %s, %s", ErrInvalidRedemption, repo.RedemptionPending, repo.RedemptionApproved, repo.RedemptionRejected)
+	}
+	if limit <= 0 {
+		limit = defaultRedemptionList
+	}
+	if limit > maxRedemptionList {
+		limit = maxRedemptionList
+	}
+	cal, err := s.HouseCalendar(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.rp.ListRedemptions(ctx, groupID, status, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Redemption, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, redemptionFromRow(row, cal.Loc))
+	}
+	return out, nil
+}
+
+// DecideRedemption 申請中の交換を承認/却下する。承認は申請した本人以外のメンバーのみ、
+// 却下は本人（取り下げ）もできる
+func (s *Service) DecideRedemption(ctx context.Context, groupID string, id int64, deciderID string, approve bool) (Redemption, error) {
+	deciderID = strings.TrimSpace(deciderID)
+	if deciderID == "" {
+		return Redemption{}, fmt.Errorf("%w: user is required", ErrInvalidRedemption)
+	}
+	if _, err := s.rp.MemberRole(ctx, groupID, deciderID); err != nil {
+		return Redemption{}, err
+	}
+	rd, err := s.GetRedemption(ctx, groupID, id)
+	if err != nil {
+		return Redemption{}, err
+	}
+	status := repo.RedemptionRejected
+	if approve {
+		if rd.UserID == deciderID {
+			return Redemption{}, ErrSelfApproval
+		}
+		status = repo.RedemptionApproved
+	}
+	if err := s.rp.DecideRedemption(ctx, groupID, id, deciderID, status); err != nil {
+		return Redemption{}, err
+	}
+	return s.GetRedemption(ctx, groupID, id)
+}
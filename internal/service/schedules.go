@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"chores_contributor/internal/repo"
+)
+
+// 定期家事の繰り返し
+const (
+	ScheduleDaily  = "daily"  // 毎日
+	ScheduleEvery  = "every"  // N日ごと（最後にやった日から数える）
+	ScheduleWeekly = "weekly" // 毎週決まった曜日
+)
+
+const maxScheduleIntervalDays = 365
+
+// reminderStaleAfter 期限切れ通知の送信権を、送っている途中で落ちたインスタンスの分として取り直すまでの時間
+const reminderStaleAfter = 10 * time.Minute
+
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// Schedule 定期家事。NextDue/Overdueは取得時点のハウスの日付で計算する
+type Schedule struct {
+	ID           int64
+	TaskKey      string
+	Rule         string
+	IntervalDays int
+	Weekday      string // weekly のみ（monday など）
+	AssigneeID   string
+	AssigneeName string
+	StartDate    time.Time
+	LastDoneAt   *time.Time
+	NextDue      time.Time
+	DaysLeft     int // 期日までの日数（今日が期日なら0、期限切れなら負）
+	Overdue      bool
+}
+
+// ScheduleInput StartDateはYYYY-MM-DD（省略時は今日）
+type ScheduleInput struct {
+	Task         string `json:"task"`
+	Rule         string `json:"rule"`
+	IntervalDays int    `json:"interval_days,omitempty"`
+	Weekday      string `json:"weekday,omitempty"`
+	Assignee     string `json:"assignee,omitempty"`
+	StartDate    string `json:"start_date,omitempty"`
+}
+
+// schedulePeriod 1回やってから次の期日を探し始めるまでの日数
+// （毎週の家事は翌日以降で最初のその曜日）
+func schedulePeriod(rule string, intervalDays int) int {
+	if rule == ScheduleEvery {
+		return intervalDays
+	}
+	return 1
+}
+
+// dueOnOrAfter 日付from以降で最初に期日になりうる日
+func dueOnOrAfter(rule string, weekday time.Weekday, from time.Time) time.Time {
+	if rule != ScheduleWeekly {
+		return from
+	}
+	return from.AddDate(0, 0, (int(weekday)-int(from.Weekday())+7)%7)
+}
+
+// nextDue 次の期日（日付）。最後の記録から1周期あとで、開始日より前にはしない。
+// 期限を過ぎてからやった分は過ぎた期日の分として数える（次の期日は飛ばさない）
+func nextDue(rule string, intervalDays int, weekday time.Weekday, start time.Time, lastDone *time.Time) time.Time {
+	due := dueOnOrAfter(rule, weekday, start)
+	if lastDone != nil {
+		if d := dueOnOrAfter(rule, weekday, lastDone.AddDate(0, 0, schedulePeriod(rule, intervalDays))); d.After(due) {
+			due = d
+		}
+	}
+	return due
+}
+
+func scheduleFromRow(row repo.ScheduleRow, cal Calendar, today time.Time) Schedule {
+	sc := Schedule{
+		ID:           row.ID,
+		TaskKey:      row.TaskKey,
+		Rule:         row.Rule,
+		IntervalDays: row.IntervalDays,
+		AssigneeID:   row.AssigneeID,
+		AssigneeName: row.AssigneeName,
+		StartDate:    dateIn(row.StartDate, cal.Loc),
+	}
+	var weekday time.Weekday
+	if row.Weekday != nil {
+		weekday = time.Weekday(*row.Weekday)
+		sc.Weekday = weekdayName(weekday)
+	}
+	var lastDone *time.Time
+	if row.LastDoneAt != nil {
+		at := row.LastDoneAt.In(cal.Loc)
+		done := cal.Date(at)
+		sc.LastDoneAt, lastDone = &at, &done
+	}
+	sc.NextDue = nextDue(row.Rule, row.IntervalDays, weekday, sc.StartDate, lastDone)
+	sc.DaysLeft = int(math.Round(sc.NextDue.Sub(today).Hours() / 24))
+	sc.Overdue = sc.DaysLeft < 0
+	return sc
+}
+
+// schedulesAt 取得した定期家事を期日の近い順に並べる
+func schedulesAt(rows []repo.ScheduleRow, cal Calendar, now time.Time) []Schedule {
+	today := cal.Date(now)
+	out := make([]Schedule, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, scheduleFromRow(row, cal, today))
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].NextDue.Before(out[j].NextDue)
+	})
+	return out
+}
+
+// ListSchedules ハウスの定期家事を期日の近い順に返す
+func (s *Service) ListSchedules(ctx context.Context, groupID string) ([]Schedule, error) {
+	cal, err := s.HouseCalendar(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.rp.ListSchedules(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return schedulesAt(rows, cal, time.Now()), nil
+}
+
+// validateScheduleRule 繰り返しの指定を検証し、保存する間隔と曜日を返す
+func validateScheduleRule(in ScheduleInput) (int, *int, error) {
+	switch in.Rule {
+	case ScheduleDaily:
+		if in.Weekday != "" {
+			return 0, nil, fmt.Errorf("%w: weekday is only for weekly", ErrInvalidSchedule)
+		}
+		return 1, nil, nil
+	case ScheduleEvery:
+		if in.Weekday != "" {
+			return 0, nil, fmt.Errorf("%w: weekday is only for weekly", ErrInvalidSchedule)
+		}
+		if in.IntervalDays < 1 || in.IntervalDays > maxScheduleIntervalDays {
+			return 0, nil, fmt.Errorf("%w: interval_days must be between 1 and %d", ErrInvalidSchedule, maxScheduleIntervalDays)
+		}
+		return in.IntervalDays, nil, nil
+	case ScheduleWeekly:
+		wd, ok := parseWeekday(strings.ToLower(strings.TrimSpace(in.Weekday)))
+		if !ok {
+			return 0, nil, fmt.Errorf("%w: weekday must be a weekday name such as monday", ErrInvalidSchedule)
+		}
+		n := int(wd)
+		return 1, &n, nil
+	}
+	return 0, nil, fmt.Errorf("%w: rule must be one of %s, %s, %s", ErrInvalidSchedule, ScheduleDaily, ScheduleEvery, ScheduleWeekly)
+}
+
+// CreateSchedule 定期家事を登録する
+func (s *Service) CreateSchedule(ctx context.Context, groupID string, in ScheduleInput) (Schedule, error) {
+	interval, weekday, err := validateScheduleRule(in)
+	if err != nil {
+		return Schedule{}, err
+	}
+	cal, err := s.HouseCalendar(ctx, groupID)
+	if err != nil {
+		return Schedule{}, err
+	}
+	start := cal.Date(time.Now())
+	if in.StartDate != "" {
+		d, err := time.Parse("2006-01-02", in.StartDate)
+		if err != nil {
+			return Schedule{}, fmt.Errorf("%w: start_date must be YYYY-MM-DD", ErrInvalidSchedule)
+		}
+		start = dateIn(d, cal.Loc)
+	}
+	def, err := s.FindTask(ctx, groupID, in.Task)
+	if err != nil {
+		return Schedule{}, err
+	}
+	assignee := strings.TrimSpace(in.Assignee)
+	if assignee != "" {
+		if _, err := s.rp.MemberRole(ctx, groupID, assignee); err != nil {
+			if errors.Is(err, repo.ErrNoMemberFound) {
+				return Schedule{}, fmt.Errorf("%w: assignee is not a member of the house", ErrInvalidSchedule)
+			}
+			return Schedule{}, err
+		}
+	}
+
+	id, err := s.rp.InsertSchedule(ctx, repo.InsertScheduleParams{
+		ExtGroupID:        groupID,
+		TaskID:            def.ID,
+		Rule:              in.Rule,
+		IntervalDays:      interval,
+		Weekday:           weekday,
+		AssigneeExtUserID: assignee,
+		StartDate:         start,
+	})
+	if err != nil {
+		return Schedule{}, err
+	}
+	row, err := s.rp.GetSchedule(ctx, groupID, id)
+	if err != nil {
+		return Schedule{}, err
+	}
+	return scheduleFromRow(row, cal, cal.Date(time.Now())), nil
+}
+
+// DeleteSchedule 定期家事を削除する
+func (s *Service) DeleteSchedule(ctx context.Context, groupID string, id int64) error {
+	return s.rp.DeleteSchedule(ctx, groupID, id)
+}
+
+// OverdueReminder ハウスで新たに期限切れになった定期家事
+type OverdueReminder struct {
+	GroupID   string
+	Schedules []Schedule
+}
+
+// ReminderPusher 期限切れの通知を送る。retryKeyは同じ通知の再送では同じ値になる
+type ReminderPusher func(ctx context.Context, reminder OverdueReminder, retryKey string) error
+
+// RetryKeyFor partsから決まるUUID形式のキー（LINEの X-Line-Retry-Key 用）
+func RetryKeyFor(parts ...string) string {
+	sum := sha1.Sum([]byte(strings.Join(parts, "\x00")))
+	sum[6] = sum[6]&0x0f | 0x50 // version 5
+	sum[8] = sum[8]&0x3f | 0x80 // variant RFC 4122
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// SendOverdueReminders 期限切れになった定期家事をハウスごとにまとめて通知する。
+// 期日ごとに1回だけ送る（送信権を取ってから送り、送れたら reminded_due に記録するので複数インスタンス間でも重複しない。
+// 送っている途中で落ちた分は reminderStaleAfter を過ぎたら送り直す。対象が同じならretryKeyも同じなので二重には届かない）
+func (s *Service) SendOverdueReminders(ctx context.Context, now time.Time, push ReminderPusher) error {
+	houses, err := s.rp.ListLineHouses(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, h := range houses {
+		if err := s.sendOverdueReminder(ctx, h, now, push); err != nil {
+			errs = append(errs, fmt.Errorf("group=%s: %w", h.ExtGroupID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Service) sendOverdueReminder(ctx context.Context, h repo.HouseRow, now time.Time, push ReminderPusher) error {
+	cal := calendarFromSettings(houseSettingsFromRepo(h.Settings))
+	rows, err := s.rp.ListSchedules(ctx, h.ExtGroupID)
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+
+	reminder := OverdueReminder{GroupID: h.ExtGroupID}
+	keyParts := []string{h.ExtGroupID}
+	for _, sc := range schedulesAt(rows, cal, now) {
+		if !sc.Overdue {
+			continue
+		}
+		ok, err := s.rp.ClaimScheduleReminder(ctx, sc.ID, sc.NextDue, reminderStaleAfter)
+		if err != nil {
+			return s.releaseReminders(ctx, reminder.Schedules, err)
+		}
+		if ok {
+			reminder.Schedules = append(reminder.Schedules, sc)
+			keyParts = append(keyParts, fmt.Sprintf("%d@%s", sc.ID, sc.NextDue.Format("2006-01-02")))
+		}
+	}
+	if len(reminder.Schedules) == 0 {
+		return nil
+	}
+	if err := push(ctx, reminder, RetryKeyFor(keyParts...)); err != nil {
+		return s.releaseReminders(ctx, reminder.Schedules, err)
+	}
+	var errs []error
+	for _, sc := range reminder.Schedules {
+		// 記録に失敗した分は送信権が古くなってから送り直す（同じretryKeyならLINEが重複を除く）
+		if err := s.rp.CompleteScheduleReminder(ctx, sc.ID, sc.NextDue); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// releaseReminders 送れなかった通知の送信権を返して次回送り直せるようにし、causeを返す
+func (s *Service) releaseReminders(ctx context.Context, schedules []Schedule, cause error) error {
+	for _, sc := range schedules {
+		if err := s.rp.ReleaseScheduleReminder(ctx, sc.ID, sc.NextDue); err != nil {
+			cause = errors.Join(cause, err)
+		}
+	}
+	return cause
+}
@@ -2,7 +2,10 @@ package service
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -11,23 +14,16 @@ import (
 	"golang.org/x/text/unicode/norm"
 )
 
-var jst = must(time.LoadLocation("Asia/Tokyo"))
-
-func must(loc *time.Location, err error) *time.Location {
-	if err != nil {
-		panic(err)
-	}
-	return loc
+type Service struct {
+	rp       *repo.Repo
+	catalogs *taskCatalogCache
 }
 
-func nowJST() time.Time {
-	return time.Now().In(jst)
+func New(rp *repo.Repo) *Service {
+	rp.SetDefaultTasks(defaultTaskSeeds())
+	return &Service{rp: rp, catalogs: newTaskCatalogCache()}
 }
 
-type Service struct{ rp *repo.Repo }
-
-func New(rp *repo.Repo) *Service { return &Service{rp: rp} }
-
 // normalizeCategory カテゴリ名を正規化（全角/半角・NFKC・trim・連続空白圧縮）
 func normalizeCategory(s string) string {
 	s = strings.TrimSpace(s)
@@ -39,24 +35,16 @@ func normalizeCategory(s string) string {
 }
 
 type ReportPayload struct {
-	GroupID     string  `json:"group_id"` // ext_group_id
-	UserID      string  `json:"user_id"`  // ext_user_id
-	DisplayName *string `json:"display_name,omitempty"`
-	Task        string  `json:"task"`
-	Option      *string `json:"option,omitempty"`
-	Type        *string `json:"type,omitempty"`
-	SourceMsgID *string `json:"source_msg_id,omitempty"`
-	Note        *string `json:"note,omitempty"`
-}
-
-type WeeklyTaskSummary struct {
-	TaskKey string
-	Points  float64
-}
-
-type WeeklyUserSummary struct {
-	Total    float64
-	TaskList []WeeklyTaskSummary
+	GroupID     string     `json:"group_id"` // ext_group_id
+	UserID      string     `json:"user_id"`  // ext_user_id
+	DisplayName *string    `json:"display_name,omitempty"`
+	Task        string     `json:"task"`
+	Option      *string    `json:"option,omitempty"`
+	Type        *string    `json:"type,omitempty"`
+	SourceMsgID *string    `json:"source_msg_id,omitempty"`
+	Note        *string    `json:"note,omitempty"`
+	PerformedAt *time.Time `json:"performed_at,omitempty"` // 省略時は報告日時
+	Source      string     `json:"-"`                      // 操作履歴の経路（空ならHTTP）
 }
 
 type CancelResult struct {
@@ -69,7 +57,63 @@ type GroupRankingRow struct {
 	Points float64
 }
 
-func (s *Service) Report(ctx context.Context, p ReportPayload) error {
+// WeeklyStanding 週のユーザーの合計と順位（同点は同順位）。
+// Startはその週の初日、Pastは今週より前の週かどうか
+type WeeklyStanding struct {
+	Total   float64
+	Rank    int
+	Members int
+	Start   time.Time
+	Past    bool
+}
+
+// ReportResult 記録されたタスク（正規化済みキー）とポイント。
+// Correctedは入力が名前/別名に完全一致せず、タイプミス補正で解決されたことを示す
+type ReportResult struct {
+	Input       string
+	TaskKey     string
+	Points      float64
+	Corrected   bool
+	PerformedAt time.Time
+	Backdated   bool // 実施日が報告日より前
+	Seq         int64
+	Pending     bool // ハウスが承認制で、ほかのメンバーの承認待ち
+}
+
+// ReportItem 1通のメッセージに含まれる個々の報告
+type ReportItem struct {
+	Task   string
+	Option *string
+}
+
+// ReportItemError 複数報告のうちIndex番目（0始まり）の解決に失敗した
+type ReportItemError struct {
+	Index int
+	Task  string
+	Err   error
+}
+
+func (e *ReportItemError) Error() string {
+	return fmt.Sprintf("item %d (%q): %v", e.Index+1, e.Task, e.Err)
+}
+
+func (e *ReportItemError) Unwrap() error {
+	return e.Err
+}
+
+const maxSourceMsgIDLen = 64
+
+// itemSourceMsgID 複数報告のn件目の冪等キー（例: msgid#2）。64文字を超える場合は元IDをハッシュ化する
+func itemSourceMsgID(base string, n int) string {
+	suffix := fmt.Sprintf("#%d", n)
+	if len(base)+len(suffix) > maxSourceMsgIDLen {
+		sum := sha1.Sum([]byte(base))
+		base = hex.EncodeToString(sum[:])
+	}
+	return base + suffix
+}
+
+func validateReportPayload(p ReportPayload) error {
 	if p.GroupID == "" || p.UserID == "" {
 		return errors.New("missing required fields")
 	}
@@ -79,19 +123,68 @@ func (s *Service) Report(ctx context.Context, p ReportPayload) error {
 	if p.Type != nil && *p.Type != "" && *p.Type != string(repo.KindChore) {
 		return errors.New("type must be 'chore' when provided")
 	}
-	if strings.TrimSpace(p.Task) == "" {
-		return errors.New("task is required")
+	return nil
+}
+
+// performedAt 報告の実施日時を決め、ハウスのさかのぼり上限を確認する。
+// 2つ目の戻り値は実施日がハウスの暦で今日より前かどうか
+func (s *Service) performedAt(ctx context.Context, p ReportPayload, now time.Time) (time.Time, bool, error) {
+	if p.PerformedAt == nil || p.PerformedAt.IsZero() {
+		return now, false, nil
+	}
+	settings, err := s.HouseSettings(ctx, p.GroupID)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	cal := calendarFromSettings(settings)
+	performed := p.PerformedAt.In(cal.Loc)
+	if err := checkPerformedAt(performed, now, settings.BackdateLimitDays, cal); err != nil {
+		return time.Time{}, false, err
+	}
+	return performed, cal.Date(performed).Before(cal.Date(now)), nil
+}
+
+// scoreTask タスクを解決し、オプションからポイントを計算する。
+// 戻り値は正規化済みキー・ポイント・入力が名前/別名に完全一致したか
+func scoreTask(idx taskAliasIndex, input string, option *string) (string, float64, bool, error) {
+	def, err := resolveTask(idx, input)
+	if err != nil {
+		return "", 0, false, err
+	}
+	_, exact := idx.exact[normalizeCategory(input)]
+
+	canonical, points, err := scoreDefinition(def, option)
+	if err != nil {
+		return "", 0, false, err
+	}
+	return canonical, points, exact, nil
+}
+
+// scoreDefinition 解決済みのタスクについて、オプションからポイントを計算する
+func scoreDefinition(def TaskDefinition, option *string) (string, float64, error) {
+	var quantity TaskQuantity
+	if option != nil {
+		var err error
+		if quantity, err = parseTaskOption(*option); err != nil {
+			return "", 0, err
+		}
 	}
-	now := nowJST()
+	points, err := taskPoints(def, quantity, optionText(option))
+	if err != nil {
+		return "", 0, err
+	}
+	return normalizeCategory(def.Key), points, nil
+}
 
-	def, err := resolveTask(strings.TrimSpace(p.Task))
+// buildEvent タスクを解決し、オプションからポイントを計算する
+func buildEvent(idx taskAliasIndex, p ReportPayload, now, performedAt time.Time, backdated bool) (repo.InsertEventParams, ReportResult, error) {
+	input := strings.TrimSpace(p.Task)
+	canonical, points, exact, err := scoreTask(idx, input, p.Option)
 	if err != nil {
-		return err
+		return repo.InsertEventParams{}, ReportResult{}, err
 	}
-	canonical := normalizeCategory(def.Key)
-	points := def.Points
 
-	return s.rp.InsertEvent(ctx, repo.InsertEventParams{
+	return repo.InsertEventParams{
 		ExtGroupID:  p.GroupID,
 		ExtUserID:   p.UserID,
 		DisplayName: p.DisplayName,
@@ -100,40 +193,110 @@ func (s *Service) Report(ctx context.Context, p ReportPayload) error {
 		Points:      points,
 		SourceMsgID: p.SourceMsgID,
 		Now:         now,
+		PerformedAt: performedAt,
 		Note:        p.Note,
-	})
+		Source:      p.Source,
+	}, ReportResult{
+		Input:       input,
+		TaskKey:     canonical,
+		Points:      points,
+		Corrected:   !exact,
+		PerformedAt: performedAt,
+		Backdated:   backdated,
+	}, nil
 }
 
-func (s *Service) Rp() *repo.Repo {
-	return s.rp
+func (s *Service) Report(ctx context.Context, p ReportPayload) (ReportResult, error) {
+	if err := validateReportPayload(p); err != nil {
+		return ReportResult{}, err
+	}
+	if strings.TrimSpace(p.Task) == "" {
+		return ReportResult{}, errors.New("task is required")
+	}
+	now := time.Now()
+
+	performed, backdated, err := s.performedAt(ctx, p, now)
+	if err != nil {
+		return ReportResult{}, err
+	}
+	idx, err := s.taskIndex(ctx, p.GroupID)
+	if err != nil {
+		return ReportResult{}, err
+	}
+	params, result, err := buildEvent(idx, p, now, performed, backdated)
+	if err != nil {
+		return ReportResult{}, err
+	}
+	inserted, err := s.rp.InsertEvent(ctx, params)
+	if err != nil {
+		return ReportResult{}, err
+	}
+	result.Seq, result.Pending = inserted.Seq, inserted.Pending
+	return result, nil
 }
 
-func (s *Service) WeeklyUserSummary(ctx context.Context, groupID, userID string, ref time.Time) (WeeklyUserSummary, error) {
-	wd := int(ref.Weekday())
-	if wd == 0 {
-		wd = 7
+// ReportMany 複数のタスクを1件ずつのイベントとしてまとめて記録する（全件成功か全件失敗）。
+// p.Task/p.Optionは使わず、冪等キーはp.SourceMsgIDから件数に応じて派生させる
+func (s *Service) ReportMany(ctx context.Context, p ReportPayload, items []ReportItem) ([]ReportResult, error) {
+	if err := validateReportPayload(p); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, errors.New("task is required")
+	}
+	now := time.Now()
+
+	performed, backdated, err := s.performedAt(ctx, p, now)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := s.taskIndex(ctx, p.GroupID)
+	if err != nil {
+		return nil, err
 	}
-	start := time.Date(ref.Year(), ref.Month(), ref.Day(), 0, 0, 0, 0, ref.Location()).AddDate(0, 0, -(wd - 1))
-	end := start.AddDate(0, 0, 7)
 
-	rows, err := s.rp.WeeklyUserTaskPoints(ctx, groupID, userID, start, end)
+	params := make([]repo.InsertEventParams, 0, len(items))
+	results := make([]ReportResult, 0, len(items))
+	for i, item := range items {
+		itemPayload := p
+		itemPayload.Task = item.Task
+		itemPayload.Option = item.Option
+		if len(items) > 1 {
+			msgID := itemSourceMsgID(*p.SourceMsgID, i+1)
+			itemPayload.SourceMsgID = &msgID
+		}
+		ev, result, err := buildEvent(idx, itemPayload, now, performed, backdated)
+		if err != nil {
+			return nil, &ReportItemError{Index: i, Task: item.Task, Err: err}
+		}
+		params = append(params, ev)
+		results = append(results, result)
+	}
+
+	inserted, err := s.rp.InsertEvents(ctx, params)
 	if err != nil {
-		return WeeklyUserSummary{}, err
+		return nil, err
+	}
+	for i := range results {
+		results[i].Seq, results[i].Pending = inserted[i].Seq, inserted[i].Pending
 	}
+	return results, nil
+}
 
-	summary := WeeklyUserSummary{TaskList: make([]WeeklyTaskSummary, 0, len(rows))}
-	for _, row := range rows {
-		summary.Total += row.Points
-		summary.TaskList = append(summary.TaskList, WeeklyTaskSummary{
-			TaskKey: row.TaskKey,
-			Points:  row.Points,
-		})
+func optionText(opt *string) string {
+	if opt == nil {
+		return ""
 	}
-	return summary, nil
+	return *opt
 }
 
-func (s *Service) CancelLatestEvent(ctx context.Context, groupID, userID string) (CancelResult, error) {
-	deleted, err := s.rp.DeleteLatestEvent(ctx, groupID, userID)
+func (s *Service) Rp() *repo.Repo {
+	return s.rp
+}
+
+// CancelLatestEvent ユーザーの直前の記録を取り消す（本人の操作として履歴に残す）
+func (s *Service) CancelLatestEvent(ctx context.Context, groupID, userID, source string) (CancelResult, error) {
+	deleted, err := s.rp.DeleteLatestEvent(ctx, groupID, userID, repo.Actor{ExtUserID: userID, Source: source})
 	if err != nil {
 		return CancelResult{}, err
 	}
@@ -141,12 +304,11 @@ func (s *Service) CancelLatestEvent(ctx context.Context, groupID, userID string)
 }
 
 func (s *Service) WeeklyGroupRanking(ctx context.Context, groupID string, ref time.Time) ([]GroupRankingRow, error) {
-	wd := int(ref.Weekday())
-	if wd == 0 {
-		wd = 7
+	cal, err := s.HouseCalendar(ctx, groupID)
+	if err != nil {
+		return nil, err
 	}
-	start := time.Date(ref.Year(), ref.Month(), ref.Day(), 0, 0, 0, 0, ref.Location()).AddDate(0, 0, -(wd - 1))
-	end := start.AddDate(0, 0, 7)
+	start, end := cal.WeekRange(ref)
 
 	rows, err := s.rp.WeeklyPoints(ctx, groupID, start, end)
 	if err != nil {
@@ -162,8 +324,53 @@ func (s *Service) WeeklyGroupRanking(ctx context.Context, groupID string, ref ti
 	return out, nil
 }
 
-func (s *Service) TaskDefinitions() []TaskDefinition {
-	out := make([]TaskDefinition, len(taskDefinitions))
-	copy(out, taskDefinitions)
-	return out
+// TaskDefinitions ハウスのタスク辞書を返す
+func (s *Service) TaskDefinitions(ctx context.Context, groupID string) ([]TaskDefinition, error) {
+	idx, err := s.taskIndex(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]TaskDefinition, len(idx.defs))
+	copy(out, idx.defs)
+	return out, nil
+}
+
+// WeeklyStanding refを含む週のユーザーの合計ポイントと順位を返す（未報告ならRank=0）
+func (s *Service) WeeklyStanding(ctx context.Context, groupID, userID string, ref time.Time) (WeeklyStanding, error) {
+	cal, err := s.HouseCalendar(ctx, groupID)
+	if err != nil {
+		return WeeklyStanding{}, err
+	}
+	start, end := cal.WeekRange(ref)
+
+	rows, err := s.rp.WeeklyPoints(ctx, groupID, start, end)
+	if err != nil {
+		return WeeklyStanding{}, err
+	}
+	st := standingOf(rows, userID)
+	current, _ := cal.WeekRange(cal.Now())
+	st.Start = cal.Date(start)
+	st.Past = start.Before(current)
+	return st, nil
+}
+
+func standingOf(rows []repo.WeeklyRow, userID string) WeeklyStanding {
+	st := WeeklyStanding{Members: len(rows)}
+	found := false
+	for _, row := range rows {
+		if row.ExtUserID == userID {
+			st.Total = row.Points
+			found = true
+		}
+	}
+	if !found {
+		return st
+	}
+	st.Rank = 1
+	for _, row := range rows {
+		if row.Points > st.Total {
+			st.Rank++
+		}
+	}
+	return st
 }
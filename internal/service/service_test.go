@@ -1,8 +1,13 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"strings"
 	"testing"
+	"time"
+
+	"chores_contributor/internal/repo"
 )
 
 func TestNormalizeCategory(t *testing.T) {
@@ -27,67 +32,698 @@ func TestNormalizeCategory(t *testing.T) {
 	}
 }
 
-func withTaskDefinitions(defs []TaskDefinition, fn func()) {
-    oldDefs := taskDefinitions
-    oldMemo := taskAliasMemoizer
-    taskDefinitions = defs
-    taskAliasMemoizer = buildTaskAliasIndex(defs)
-    defer func() {
-        taskDefinitions = oldDefs
-        taskAliasMemoizer = oldMemo
-    }()
-    fn()
-}
-
 func TestResolveTaskExact(t *testing.T) {
-    withTaskDefinitions([]TaskDefinition{{Key: "皿洗い", Aliases: []string{"さらあらい"}, Points: 123}}, func() {
-        def, err := resolveTask("皿洗い")
-        if err != nil {
-            t.Fatalf("resolveTask returned error: %v", err)
-        }
-        if def.Key != "皿洗い" || def.Points != 123 {
-            t.Fatalf("unexpected definition: %+v", def)
-        }
-    })
+	idx := buildTaskAliasIndex([]TaskDefinition{{Key: "皿洗い", Aliases: []string{"さらあらい"}, Points: 123}})
+	def, err := resolveTask(idx, "皿洗い")
+	if err != nil {
+		t.Fatalf("resolveTask returned error: %v", err)
+	}
+	if def.Key != "皿洗い" || def.Points != 123 {
+		t.Fatalf("unexpected definition: %+v", def)
+	}
 }
 
 func TestResolveTaskFuzzy(t *testing.T) {
-    withTaskDefinitions([]TaskDefinition{{Key: "皿洗い", Aliases: []string{"さらあらい"}, Points: 50}}, func() {
-        def, err := resolveTask("皿洗")
-        if err != nil {
-            t.Fatalf("resolveTask returned error: %v", err)
-        }
-        if def.Key != "皿洗い" {
-            t.Fatalf("expected 皿洗い, got %s", def.Key)
-        }
-    })
+	idx := buildTaskAliasIndex([]TaskDefinition{{Key: "皿洗い", Aliases: []string{"さらあらい"}, Points: 50}})
+	def, err := resolveTask(idx, "皿洗")
+	if err != nil {
+		t.Fatalf("resolveTask returned error: %v", err)
+	}
+	if def.Key != "皿洗い" {
+		t.Fatalf("expected 皿洗い, got %s", def.Key)
+	}
 }
 
 func TestResolveTaskAmbiguous(t *testing.T) {
-    withTaskDefinitions([]TaskDefinition{
-        {Key: "aaaa", Points: 10},
-        {Key: "aaab", Points: 8},
-    }, func() {
-        _, err := resolveTask("aaaf")
-        if err == nil {
-            t.Fatalf("expected ambiguity error")
-        }
-        var amb *TaskAmbiguousError
-        if !errors.As(err, &amb) {
-            t.Fatalf("expected TaskAmbiguousError, got %v", err)
-        }
-        if len(amb.Candidates) != 2 {
-            t.Fatalf("expected 2 candidates, got %v", amb.Candidates)
-        }
-    })
+	idx := buildTaskAliasIndex([]TaskDefinition{
+		{Key: "aaaa", Points: 10},
+		{Key: "aaab", Points: 8},
+	})
+	_, err := resolveTask(idx, "aaaf")
+	if err == nil {
+		t.Fatalf("expected ambiguity error")
+	}
+	var amb *TaskAmbiguousError
+	if !errors.As(err, &amb) {
+		t.Fatalf("expected TaskAmbiguousError, got %v", err)
+	}
+	if len(amb.Candidates) != 2 {
+		t.Fatalf("expected 2 candidates, got %v", amb.Candidates)
+	}
 }
 
 func TestResolveTaskNotFound(t *testing.T) {
-    withTaskDefinitions([]TaskDefinition{{Key: "皿洗い", Aliases: []string{"さらあらい"}, Points: 10}}, func() {
-        _, err := resolveTask("掃除")
-        if !errors.Is(err, ErrTaskNotFound) {
-            t.Fatalf("expected ErrTaskNotFound, got %v", err)
-        }
-    })
+	idx := buildTaskAliasIndex([]TaskDefinition{{Key: "皿洗い", Aliases: []string{"さらあらい"}, Points: 10}})
+	_, err := resolveTask(idx, "掃除")
+	if !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("expected ErrTaskNotFound, got %v", err)
+	}
+}
+
+func TestTaskCatalogCacheInvalidate(t *testing.T) {
+	c := newTaskCatalogCache()
+	c.put("g1", 3, buildTaskAliasIndex([]TaskDefinition{{Key: "皿洗い", Points: 10}}))
+	if _, ok := c.get("g1", 3); !ok {
+		t.Fatalf("expected cached index for g1")
+	}
+	if _, ok := c.get("g2", 0); ok {
+		t.Fatalf("expected no cached index for g2")
+	}
+	// ほかのインスタンスが辞書を変えて版が上がったら使わない
+	if _, ok := c.get("g1", 4); ok {
+		t.Fatalf("expected index for an old catalog version to be ignored")
+	}
+	c.invalidate("g1")
+	if _, ok := c.get("g1", 3); ok {
+		t.Fatalf("expected index for g1 to be invalidated")
+	}
+}
+
+func TestCheckTaskNamesConflict(t *testing.T) {
+	defs := []TaskDefinition{
+		{ID: 1, Key: "皿洗い", Aliases: []string{"洗い物"}},
+		{ID: 2, Key: "トイレ掃除", Aliases: []string{"トイレ"}},
+	}
+
+	if err := checkTaskNames(defs, 0, normalizeCategory("窓拭き"), normalizeCategory("まど")); err != nil {
+		t.Fatalf("expected no conflict, got %v", err)
+	}
+
+	err := checkTaskNames(defs, 0, normalizeCategory("ﾄｲﾚ"))
+	var conflict *TaskConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected TaskConflictError, got %v", err)
+	}
+	if conflict.TaskKey != "トイレ掃除" {
+		t.Fatalf("expected conflict with トイレ掃除, got %+v", conflict)
+	}
+
+	// 自分自身の別名への改名は許可
+	if err := checkTaskNames(defs, 1, "洗い物"); err != nil {
+		t.Fatalf("expected rename to own alias to pass, got %v", err)
+	}
+	if err := checkTaskNames(defs, 1, "トイレ"); !errors.Is(err, ErrTaskConflict) {
+		t.Fatalf("expected ErrTaskConflict, got %v", err)
+	}
+}
+
+func TestValidateTaskPoints(t *testing.T) {
+	for _, pt := range []float64{0, 150, maxTaskPoints} {
+		if err := validateTaskPoints(pt); err != nil {
+			t.Fatalf("validateTaskPoints(%v) returned error: %v", pt, err)
+		}
+	}
+	for _, pt := range []float64{-1, maxTaskPoints + 1} {
+		if err := validateTaskPoints(pt); !errors.Is(err, ErrInvalidTask) {
+			t.Fatalf("validateTaskPoints(%v) = %v, want ErrInvalidTask", pt, err)
+		}
+	}
+}
+
+func TestParseTaskOption(t *testing.T) {
+	tests := []struct {
+		input string
+		want  TaskQuantity
+	}{
+		{"15分", TaskQuantity{Minutes: 15}},
+		{"１５分", TaskQuantity{Minutes: 15}},
+		{"1時間", TaskQuantity{Minutes: 60}},
+		{"1時間30分", TaskQuantity{Minutes: 90}},
+		{"1.5h", TaskQuantity{Minutes: 90}},
+		{"x2", TaskQuantity{Count: 2}},
+		{"×3", TaskQuantity{Count: 3}},
+		{"ｘ４", TaskQuantity{Count: 4}},
+		{"2回", TaskQuantity{Count: 2}},
+		{"  ", TaskQuantity{}},
+	}
+	for _, tt := range tests {
+		got, err := parseTaskOption(tt.input)
+		if err != nil {
+			t.Fatalf("parseTaskOption(%q) returned error: %v", tt.input, err)
+		}
+		if got != tt.want {
+			t.Fatalf("parseTaskOption(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"たくさん", "0分", "x0", "x1000", "25時間", "15ふん"} {
+		_, err := parseTaskOption(input)
+		var optErr *OptionError
+		if !errors.As(err, &optErr) || !errors.Is(err, ErrInvalidOption) {
+			t.Fatalf("parseTaskOption(%q) = %v, want OptionError", input, err)
+		}
+	}
+}
+
+func TestTaskPoints(t *testing.T) {
+	fixed := TaskDefinition{Key: "皿洗い", Points: 180, Scoring: ScoringFixed}
+	perTen := TaskDefinition{Key: "散歩", Points: 50, Scoring: ScoringPerMinutes, UnitMinutes: 10, MaxUnits: 6}
+	perUnit := TaskDefinition{Key: "アイロン", Points: 30, Scoring: ScoringPerUnit, MaxUnits: 5}
+
+	tests := []struct {
+		name string
+		def  TaskDefinition
+		q    TaskQuantity
+		want float64
+	}{
+		{"fixed without option", fixed, TaskQuantity{}, 180},
+		{"fixed ignores duration", fixed, TaskQuantity{Minutes: 15}, 180},
+		{"fixed multiplies count", fixed, TaskQuantity{Count: 2}, 360},
+		{"per minutes", perTen, TaskQuantity{Minutes: 15}, 75},
+		{"per minutes default one unit", perTen, TaskQuantity{}, 50},
+		{"per minutes capped", perTen, TaskQuantity{Minutes: 120}, 300},
+		{"per unit", perUnit, TaskQuantity{Count: 3}, 90},
+		{"per unit capped", perUnit, TaskQuantity{Count: 9}, 150},
+		{"empty scoring behaves as fixed", TaskDefinition{Points: 100}, TaskQuantity{Count: 2}, 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := taskPoints(tt.def, tt.q, "")
+			if err != nil {
+				t.Fatalf("taskPoints returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("taskPoints = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := taskPoints(perUnit, TaskQuantity{Minutes: 10}, "10分"); !errors.Is(err, ErrInvalidOption) {
+		t.Fatalf("expected ErrInvalidOption for duration on per-unit task, got %v", err)
+	}
+}
+
+func TestItemSourceMsgID(t *testing.T) {
+	if got := itemSourceMsgID("1234567890", 2); got != "1234567890#2" {
+		t.Fatalf("itemSourceMsgID = %q", got)
+	}
+	long := strings.Repeat("a", 63)
+	got := itemSourceMsgID(long, 12)
+	if len(got) > maxSourceMsgIDLen {
+		t.Fatalf("itemSourceMsgID exceeded %d chars: %q", maxSourceMsgIDLen, got)
+	}
+	if !strings.HasSuffix(got, "#12") {
+		t.Fatalf("expected #12 suffix, got %q", got)
+	}
+	if got == itemSourceMsgID(long, 11) {
+		t.Fatalf("expected distinct keys per item")
+	}
+}
+
+func TestLooksLikeOption(t *testing.T) {
+	for _, s := range []string{"15分", "１時間", "x2", "×3", "2回"} {
+		if !LooksLikeOption(s) {
+			t.Fatalf("LooksLikeOption(%q) = false", s)
+		}
+	}
+	for _, s := range []string{"皿洗い", "x", "ゴミ出し", "", "2階トイレ掃除", "3F", "x0"} {
+		if LooksLikeOption(s) {
+			t.Fatalf("LooksLikeOption(%q) = true", s)
+		}
+	}
+}
+
+func TestShouldConfirmReport(t *testing.T) {
+	plain := []ReportResult{{TaskKey: "皿洗い"}}
+	corrected := []ReportResult{{TaskKey: "皿洗い"}, {TaskKey: "ゴミ出し", Corrected: true}}
+
+	tests := []struct {
+		mode    string
+		results []ReportResult
+		want    bool
+	}{
+		{ReportReplyAlways, plain, true},
+		{ReportReplyCorrected, plain, false},
+		{ReportReplyCorrected, corrected, true},
+		{ReportReplySilent, corrected, false},
+		{"", plain, true},
+	}
+	for _, tt := range tests {
+		if got := ShouldConfirmReport(tt.mode, tt.results); got != tt.want {
+			t.Fatalf("ShouldConfirmReport(%q, %+v) = %v, want %v", tt.mode, tt.results, got, tt.want)
+		}
+	}
+}
+
+func TestStandingOf(t *testing.T) {
+	rows := []repo.WeeklyRow{
+		{ExtUserID: "u1", Points: 500},
+		{ExtUserID: "u2", Points: 300},
+		{ExtUserID: "u3", Points: 300},
+	}
+	if got := standingOf(rows, "u3"); got != (WeeklyStanding{Total: 300, Rank: 2, Members: 3}) {
+		t.Fatalf("unexpected standing for u3: %+v", got)
+	}
+	if got := standingOf(rows, "u1"); got.Rank != 1 {
+		t.Fatalf("expected u1 to rank first, got %+v", got)
+	}
+	if got := standingOf(rows, "u9"); got.Rank != 0 || got.Members != 3 {
+		t.Fatalf("expected unranked standing for unknown user, got %+v", got)
+	}
+}
+
+func TestParseDateToken(t *testing.T) {
+	now := time.Date(2025, 1, 5, 7, 30, 0, 0, time.UTC)
+	cases := []struct {
+		in   string
+		want time.Time
+		ok   bool
+	}{
+		{"昨日", now.AddDate(0, 0, -1), true},
+		{"おととい", now.AddDate(0, 0, -2), true},
+		{"今朝", now, true}, // 8時より前なら現在時刻に丸める
+		{"3日前", now.AddDate(0, 0, -3), true},
+		{"1/3", time.Date(2025, 1, 3, 12, 0, 0, 0, time.UTC), true},
+		{"１月５日", now, true},
+		{"12/30", time.Date(2024, 12, 30, 12, 0, 0, 0, time.UTC), true},
+		{"2/30", time.Time{}, false},
+		{"15分", time.Time{}, false},
+		{"皿洗い", time.Time{}, false},
+	}
+	for _, c := range cases {
+		got, ok := ParseDateToken(c.in, now)
+		if ok != c.ok || !got.Equal(c.want) {
+			t.Fatalf("ParseDateToken(%q) = %v, %v; want %v, %v", c.in, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestCheckPerformedAt(t *testing.T) {
+	now := time.Date(2025, 11, 5, 20, 0, 0, 0, time.UTC)
+	cal := Calendar{Loc: time.UTC, WeekStart: time.Monday}
+	if err := checkPerformedAt(time.Date(2025, 10, 29, 0, 0, 0, 0, time.UTC), now, 7, cal); err != nil {
+		t.Fatalf("expected start of the 7th day back to be allowed, got %v", err)
+	}
+	err := checkPerformedAt(time.Date(2025, 10, 28, 23, 0, 0, 0, time.UTC), now, 7, cal)
+	var dateErr *PerformedAtError
+	if !errors.As(err, &dateErr) || dateErr.Future || !errors.Is(err, ErrInvalidPerformedAt) {
+		t.Fatalf("expected too-old error, got %v", err)
+	}
+	err = checkPerformedAt(now.Add(time.Hour), now, 7, cal)
+	if !errors.As(err, &dateErr) || !dateErr.Future {
+		t.Fatalf("expected future error, got %v", err)
+	}
+	if err := checkPerformedAt(now.Add(-time.Hour), now, 0, cal); err != nil {
+		t.Fatalf("expected today to be allowed with limit 0, got %v", err)
+	}
+}
+
+func TestEditEventRequiresPatch(t *testing.T) {
+	s := &Service{}
+	blank := " "
+	for _, patch := range []EventPatch{{}, {Task: &blank}} {
+		if _, err := s.EditEvent(context.Background(), 1, patch, repo.Actor{Source: repo.SourceHTTP}); !errors.Is(err, ErrInvalidEventPatch) {
+			t.Fatalf("expected ErrInvalidEventPatch for %+v, got %v", patch, err)
+		}
+	}
+}
+
+func TestScoreArchivedTaskByKey(t *testing.T) {
+	defs := []TaskDefinition{
+		{ID: 1, Key: "皿洗い", Points: 180, Scoring: ScoringFixed},
+		{ID: 2, Key: "アイロン", Points: 30, Scoring: ScoringPerUnit, MaxUnits: 5, Archived: true},
+	}
+	def, ok := findTaskByKey(defs, "アイロン")
+	if !ok || def.ID != 2 {
+		t.Fatalf("expected archived task to be found, got %+v ok=%v", def, ok)
+	}
+	opt := "x3"
+	key, points, err := scoreDefinition(def, &opt)
+	if err != nil {
+		t.Fatalf("scoreDefinition returned error: %v", err)
+	}
+	if key != "アイロン" || points != 90 {
+		t.Fatalf("scoreDefinition = %q %v, want アイロン 90", key, points)
+	}
+	if _, ok := findTaskByKey(defs, "散歩"); ok {
+		t.Fatalf("expected unknown key to be missing")
+	}
+}
+
+func TestCalendarRange(t *testing.T) {
+	ref := time.Date(2025, 11, 12, 23, 30, 0, 0, defaultLoc) // 水曜
+	cases := map[string][2]string{
+		GranularityDay:   {"2025-11-12", "2025-11-13"},
+		GranularityWeek:  {"2025-11-10", "2025-11-17"},
+		GranularityMonth: {"2025-11-01", "2025-12-01"},
+		PeriodYear:       {"2025-01-01", "2026-01-01"},
+	}
+	for unit, want := range cases {
+		start, end := DefaultCalendar().Range(ref, unit)
+		if start.Format("2006-01-02") != want[0] || end.Format("2006-01-02") != want[1] {
+			t.Fatalf("Range(%s) = %s..%s, want %s..%s", unit, start, end, want[0], want[1])
+		}
+	}
 }
 
+func TestCalendarWeekStartAndRollover(t *testing.T) {
+	cal := Calendar{Loc: defaultLoc, WeekStart: time.Sunday, RolloverHour: 4}
+
+	// 日曜1時は土曜の夜として扱うので、前の週に入る
+	start, end := cal.WeekRange(time.Date(2025, 11, 16, 1, 0, 0, 0, defaultLoc))
+	if want := time.Date(2025, 11, 9, 4, 0, 0, 0, defaultLoc); !start.Equal(want) {
+		t.Fatalf("week start = %s, want %s", start, want)
+	}
+	if want := time.Date(2025, 11, 16, 4, 0, 0, 0, defaultLoc); !end.Equal(want) {
+		t.Fatalf("week end = %s, want %s", end, want)
+	}
+
+	if got := cal.Date(time.Date(2025, 11, 16, 4, 0, 0, 0, defaultLoc)); got.Day() != 16 {
+		t.Fatalf("expected 4:00 to start the new day, got %s", got)
+	}
+	first, last := cal.DateRange(time.Date(2025, 12, 1, 2, 0, 0, 0, defaultLoc), GranularityMonth)
+	if first.Format("2006-01-02") != "2025-11-01" || last.Format("2006-01-02") != "2025-11-30" {
+		t.Fatalf("unexpected month range %s..%s", first, last)
+	}
+}
+
+func TestCalendarTimezone(t *testing.T) {
+	cal := calendarFromSettings(HouseSettings{WeekStart: "monday", Timezone: "America/New_York"})
+	if cal.Loc.String() != "America/New_York" {
+		t.Fatalf("unexpected location %s", cal.Loc)
+	}
+	// 東京の月曜10時はニューヨークではまだ日曜の夜
+	ref := time.Date(2025, 11, 17, 10, 0, 0, 0, defaultLoc)
+	if got := cal.Date(ref).Format("2006-01-02"); got != "2025-11-16" {
+		t.Fatalf("Date = %s, want 2025-11-16", got)
+	}
+	start, _ := cal.WeekRange(ref)
+	if want := time.Date(2025, 11, 10, 0, 0, 0, 0, cal.Loc); !start.Equal(want) {
+		t.Fatalf("week start = %s, want %s", start, want)
+	}
+
+	if got := calendarFromSettings(HouseSettings{Timezone: "Nowhere/Invalid"}).Loc; got != defaultLoc {
+		t.Fatalf("expected fallback to default location, got %s", got)
+	}
+}
+
+func TestUpdateHouseSettingsRejectsTimezone(t *testing.T) {
+	s := &Service{}
+	for _, name := range []string{"", "Local", "Tokyo", "Asia/Nowhere"} {
+		if _, err := s.UpdateHouseSettings(context.Background(), "g1", HouseSettingsPatch{Timezone: &name}); !errors.Is(err, ErrInvalidSettings) {
+			t.Fatalf("expected ErrInvalidSettings for %q, got %v", name, err)
+		}
+	}
+}
+
+func TestUpdateHouseSettingsRejectsApprovalTimeout(t *testing.T) {
+	s := &Service{}
+	for _, hours := range []int{0, -1, maxApprovalTimeout + 1} {
+		if _, err := s.UpdateHouseSettings(context.Background(), "g1", HouseSettingsPatch{ApprovalTimeout: &hours}); !errors.Is(err, ErrInvalidSettings) {
+			t.Fatalf("expected ErrInvalidSettings for %d, got %v", hours, err)
+		}
+	}
+}
+
+func TestRecapWeek(t *testing.T) {
+	cal := DefaultCalendar()
+	// 月曜0時の区切り直後は先週分を送る
+	week, ok := recapWeek(cal, time.Date(2025, 11, 17, 0, 5, 0, 0, defaultLoc))
+	if !ok || week.Format("2006-01-02") != "2025-11-10" {
+		t.Fatalf("recapWeek = %s, %v", week, ok)
+	}
+	// 区切りから1日以上たったら送らない
+	if _, ok := recapWeek(cal, time.Date(2025, 11, 18, 1, 0, 0, 0, defaultLoc)); ok {
+		t.Fatal("expected recap window to be closed")
+	}
+}
+
+func TestChampionWeek(t *testing.T) {
+	cal := DefaultCalendar()
+	// まとめを送らなくなった後も、先週のチャンピオンは記録する
+	for _, now := range []time.Time{
+		time.Date(2025, 11, 17, 0, 5, 0, 0, defaultLoc),
+		time.Date(2025, 11, 20, 12, 0, 0, 0, defaultLoc),
+	} {
+		if week := championWeek(cal, now); week.Format("2006-01-02") != "2025-11-10" {
+			t.Fatalf("championWeek(%s) = %s", now, week)
+		}
+	}
+}
+
+func TestBuildWeeklyRecap(t *testing.T) {
+	start := time.Date(2025, 11, 10, 0, 0, 0, 0, defaultLoc)
+	cur := Summary{Start: start, End: start.AddDate(0, 0, 7), Total: 500, Users: []SummaryUser{
+		{UserID: "u2", Name: "Bob", Points: 200, Tasks: []SummaryTask{{TaskKey: "皿洗い", Points: 200}}},
+		{UserID: "u1", Name: "Alice", Points: 200, Tasks: []SummaryTask{{TaskKey: "洗濯", Points: 150}, {TaskKey: "ゴミ出し", Points: 50}}},
+		{UserID: "u3", Name: "Carol", Points: 100},
+	}}
+	prev := Summary{Total: 300, Users: []SummaryUser{{UserID: "u1", Points: 300}}}
+
+	recap := buildWeeklyRecap("g1", cur, prev)
+	if recap.End.Format("2006-01-02") != "2025-11-16" || recap.PrevTotal != 300 {
+		t.Fatalf("unexpected recap: %+v", recap)
+	}
+	ranks := []int{recap.Members[0].Rank, recap.Members[1].Rank, recap.Members[2].Rank}
+	if ranks[0] != 1 || ranks[1] != 1 || ranks[2] != 3 {
+		t.Fatalf("unexpected ranks %v", ranks)
+	}
+	if m := recap.Members[1]; m.TopTask != "洗濯" || m.PrevPoints != 300 {
+		t.Fatalf("unexpected member %+v", m)
+	}
+}
+
+func TestNextDue(t *testing.T) {
+	d := func(day int) time.Time { return time.Date(2025, 11, day, 0, 0, 0, 0, defaultLoc) }
+	ptr := func(t time.Time) *time.Time { return &t }
+	cases := []struct {
+		name     string
+		rule     string
+		interval int
+		start    time.Time
+		lastDone *time.Time
+		want     time.Time
+	}{
+		{"weekly before first done", ScheduleWeekly, 1, d(12), nil, d(16)},
+		{"weekly done before the day", ScheduleWeekly, 1, d(3), ptr(d(14)), d(16)},
+		{"weekly done on the day", ScheduleWeekly, 1, d(3), ptr(d(16)), d(23)},
+		{"weekly done late", ScheduleWeekly, 1, d(3), ptr(d(18)), d(23)},
+		{"every 3 days", ScheduleEvery, 3, d(1), ptr(d(13)), d(16)},
+		{"daily never done", ScheduleDaily, 1, d(10), nil, d(10)},
+		{"start date wins over old record", ScheduleDaily, 1, d(20), ptr(d(2)), d(20)},
+	}
+	for _, tc := range cases {
+		if got := nextDue(tc.rule, tc.interval, time.Sunday, tc.start, tc.lastDone); !got.Equal(tc.want) {
+			t.Fatalf("%s: nextDue = %s, want %s", tc.name, got.Format("01-02"), tc.want.Format("01-02"))
+		}
+	}
+}
+
+func TestValidateScheduleRule(t *testing.T) {
+	bad := []ScheduleInput{
+		{Rule: "monthly"},
+		{Rule: ScheduleEvery},
+		{Rule: ScheduleWeekly, Weekday: "someday"},
+		{Rule: ScheduleDaily, Weekday: "monday"},
+	}
+	for _, in := range bad {
+		if _, _, err := validateScheduleRule(in); !errors.Is(err, ErrInvalidSchedule) {
+			t.Fatalf("expected ErrInvalidSchedule for %+v, got %v", in, err)
+		}
+	}
+	interval, weekday, err := validateScheduleRule(ScheduleInput{Rule: ScheduleWeekly, Weekday: "Tuesday"})
+	if err != nil || interval != 1 || weekday == nil || *weekday != int(time.Tuesday) {
+		t.Fatalf("unexpected weekly rule: %d %v %v", interval, weekday, err)
+	}
+}
+
+func TestRetryKeyFor(t *testing.T) {
+	key := RetryKeyFor("g1", "3@2025-11-16")
+	if len(key) != 36 || key[14] != '5' || key != RetryKeyFor("g1", "3@2025-11-16") {
+		t.Fatalf("unexpected retry key %q", key)
+	}
+	if key == RetryKeyFor("g1", "3@2025-11-23") {
+		t.Fatal("expected different keys for different due dates")
+	}
+}
+
+func TestPlanAssignments(t *testing.T) {
+	at := func(day int) time.Time { return time.Date(2025, 11, day, 20, 0, 0, 0, defaultLoc) }
+	members := []repo.MemberPointsRow{
+		{ExtUserID: "Ua", Name: "Alice", Points: 600},
+		{ExtUserID: "Ub", Name: "Bob", Points: 200},
+		{ExtUserID: "Uc", Name: "Carol", Points: 400},
+	}
+	lastDone := []repo.TaskLastDoneRow{
+		{TaskKey: "皿洗い", ExtUserID: "Ua", LastDoneAt: at(10)},
+		{TaskKey: "皿洗い", ExtUserID: "Ub", LastDoneAt: at(12)},
+	}
+	defs := []TaskDefinition{
+		{Key: "洗濯", Rotation: RotationLeastPoints},
+		{Key: "皿洗い", Rotation: RotationRoundRobin},
+		{Key: "ゴミ出し", Rotation: RotationFixed, Owner: "Ua"},
+		{Key: "風呂掃除", Rotation: RotationFixed, Owner: "Ux"},
+	}
+	plan := planAssignments(defs, members, lastDone, at(1), defaultLoc)
+	if plan.FairShare != 400 {
+		t.Fatalf("fair share = %v, want 400", plan.FairShare)
+	}
+	want := []struct {
+		user     string
+		fallback bool
+	}{
+		{"Ub", false}, // 目安より最も少ない
+		{"Uc", false}, // 皿洗いをまだやったことがない
+		{"Ua", false}, // 担当者
+		{"Ub", true},  // 担当者がいないので least_points
+	}
+	for i, w := range want {
+		a := plan.Assignments[i]
+		if a.UserID != w.user || a.Fallback != w.fallback || len(a.Candidates) != len(members) {
+			t.Fatalf("%s: got %s fallback=%v (%d candidates), want %s fallback=%v", a.TaskKey, a.UserID, a.Fallback, len(a.Candidates), w.user, w.fallback)
+		}
+	}
+	if rr := plan.Assignments[1].Candidates; rr[1].UserID != "Ua" || rr[2].UserID != "Ub" {
+		t.Fatalf("unexpected round robin order: %s, %s", rr[1].UserID, rr[2].UserID)
+	}
+}
+
+func TestValidateRotation(t *testing.T) {
+	for _, r := range []string{RotationLeastPoints, RotationRoundRobin, RotationFixed} {
+		if err := validateRotation(r); err != nil {
+			t.Fatalf("validateRotation(%q) = %v", r, err)
+		}
+	}
+	if err := validateRotation("random"); !errors.Is(err, ErrInvalidTask) {
+		t.Fatalf("expected ErrInvalidTask, got %v", err)
+	}
+}
+
+func TestBuildBalance(t *testing.T) {
+	d := func(day int) time.Time { return time.Date(2025, 11, day, 0, 0, 0, 0, defaultLoc) }
+	ptr := func(t time.Time) *time.Time { return &t }
+	sum := Summary{
+		Start: d(3), End: d(17), Total: 2000,
+		Users: []SummaryUser{{UserID: "Ua", Name: "Alice", Points: 1100}, {UserID: "Ub", Name: "Bob", Points: 900}},
+		Buckets: []SummaryBucket{
+			{Start: d(3), End: d(10), Points: 1000, Users: []SummaryBucketUser{{UserID: "Ua", Points: 700}, {UserID: "Ub", Points: 300}}},
+			{Start: d(10), End: d(17), Points: 1000, Users: []SummaryBucketUser{{UserID: "Ua", Points: 400}, {UserID: "Ub", Points: 600}}},
+		},
+	}
+	members := []repo.MemberPointsRow{{ExtUserID: "Ua", Name: "Alice"}, {ExtUserID: "Ub", Name: "Bob"}}
+	// 1週目は目標なし（均等）、2週目から 60:40
+	shares := []MemberShare{
+		{UserID: "Ua", Share: 60, ValidFrom: ptr(d(10))},
+		{UserID: "Ub", Share: 40, ValidFrom: ptr(d(10))},
+	}
+	bal := buildBalance(sum, members, shares)
+	if !bal.Targeted || len(bal.Weeks) != 2 || len(bal.Members) != 2 {
+		t.Fatalf("unexpected balance: %+v", bal)
+	}
+	// Bob: 1週目 500-300=200 不足、2週目 400-600=-200 で相殺
+	if w := bal.Weeks[0].Members[1]; w.TargetShare != 0.5 || w.Debt != 200 {
+		t.Fatalf("unexpected first week for Bob: %+v", w)
+	}
+	alice, bob := bal.Members[0], bal.Members[1]
+	if bob.Debt != 0 || bob.Expected != 900 || alice.Debt != 0 {
+		t.Fatalf("unexpected carried debt: alice=%+v bob=%+v", alice, bob)
+	}
+	if alice.TargetShare != 0.55 || bob.Share != 0.45 {
+		t.Fatalf("unexpected shares: alice=%+v bob=%+v", alice, bob)
+	}
+}
+
+func TestMemberShareOverlaps(t *testing.T) {
+	d := func(day int) *time.Time { v := time.Date(2025, 11, day, 0, 0, 0, 0, defaultLoc); return &v }
+	open := MemberShare{}
+	early := MemberShare{ValidTo: d(9)}
+	late := MemberShare{ValidFrom: d(10)}
+	if !open.overlaps(late) || !late.overlaps(open) {
+		t.Fatal("expected an unbounded share to overlap everything")
+	}
+	if early.overlaps(late) || late.overlaps(early) {
+		t.Fatal("expected adjacent shares not to overlap")
+	}
+	if !(MemberShare{ValidTo: d(10)}).overlaps(late) {
+		t.Fatal("expected shares sharing a day to overlap")
+	}
+}
+
+func TestStreaks(t *testing.T) {
+	d := func(day int) time.Time { return time.Date(2025, 11, day, 0, 0, 0, 0, defaultLoc) }
+	cases := []struct {
+		name          string
+		days          []time.Time
+		current, best int
+	}{
+		{"no records", nil, 0, 0},
+		{"through yesterday", []time.Time{d(19), d(18), d(17), d(10), d(9), d(8), d(7)}, 3, 4},
+		{"broken yesterday", []time.Time{d(18), d(17)}, 0, 2},
+		{"today only", []time.Time{d(20), d(18)}, 1, 1},
+	}
+	for _, tc := range cases {
+		current, best := streaks(tc.days, d(20))
+		if current != tc.current || best != tc.best {
+			t.Fatalf("%s: streaks = %d/%d, want %d/%d", tc.name, current, best, tc.current, tc.best)
+		}
+	}
+}
+
+func TestEarnedAchievements(t *testing.T) {
+	got := earnedAchievements(7, []repo.TaskCountRow{{TaskKey: "皿洗い", Count: 120}, {TaskKey: "洗濯", Count: 3}})
+	var codes []string
+	for _, a := range got {
+		codes = append(codes, a.Code)
+	}
+	want := []string{"first_report", "streak:3", "streak:7", "task:皿洗い:10", "task:皿洗い:50", "task:皿洗い:100"}
+	if strings.Join(codes, ",") != strings.Join(want, ",") {
+		t.Fatalf("earnedAchievements = %v, want %v", codes, want)
+	}
+	if len(earnedAchievements(0, nil)) != 0 {
+		t.Fatal("expected no achievements without records")
+	}
+}
+
+func TestValidateReward(t *testing.T) {
+	if name, err := validateRewardName("  マッサージ券 "); err != nil || name != "マッサージ券" {
+		t.Fatalf("validateRewardName = %q, %v", name, err)
+	}
+	if _, err := validateRewardName(strings.Repeat("券", maxRewardNameLen+1)); !errors.Is(err, ErrInvalidReward) {
+		t.Fatalf("expected ErrInvalidReward for long name, got %v", err)
+	}
+	for _, cost := range []float64{0, -100, maxRewardCost + 1} {
+		if err := validateRewardCost(cost); !errors.Is(err, ErrInvalidReward) {
+			t.Fatalf("expected ErrInvalidReward for cost %v, got %v", cost, err)
+		}
+	}
+}
+
+func TestBuildSummary(t *testing.T) {
+	start := time.Date(2025, 11, 5, 0, 0, 0, 0, defaultLoc) // 水曜
+	end := time.Date(2025, 11, 18, 0, 0, 0, 0, defaultLoc)
+	day := func(d int) time.Time { return time.Date(2025, 11, d, 0, 0, 0, 0, time.UTC) }
+	rows := []repo.DailyPointsRow{
+		{ExtUserID: "u1", Name: "Alice", TaskKey: "皿洗い", Day: day(5), Points: 180, Count: 1},
+		{ExtUserID: "u2", Name: "Bob", TaskKey: "ゴミ出し", Day: day(9), Points: 100, Count: 1},
+		{ExtUserID: "u2", Name: "Bob", TaskKey: "皿洗い", Day: day(10), Points: 360, Count: 2},
+		{ExtUserID: "u1", Name: "Alice", TaskKey: "ゴミ出し", Day: day(17), Points: 100, Count: 1},
+	}
+	sum := buildSummary(rows, start, end, GranularityWeek, DefaultCalendar())
+
+	if sum.Total != 740 {
+		t.Fatalf("total = %v, want 740", sum.Total)
+	}
+	if len(sum.Users) != 2 || sum.Users[0].UserID != "u2" || sum.Users[0].Points != 460 || sum.Users[0].Count != 3 {
+		t.Fatalf("unexpected users: %+v", sum.Users)
+	}
+	if sum.Users[0].Tasks[0].TaskKey != "皿洗い" {
+		t.Fatalf("expected user tasks sorted by points, got %+v", sum.Users[0].Tasks)
+	}
+	if len(sum.Tasks) != 2 || sum.Tasks[0].TaskKey != "皿洗い" || sum.Tasks[0].Points != 540 {
+		t.Fatalf("unexpected tasks: %+v", sum.Tasks)
+	}
+
+	// 11/5(水)〜11/9(日) / 11/10〜11/16 / 11/17(月) の3区間
+	if len(sum.Buckets) != 3 {
+		t.Fatalf("expected 3 buckets, got %+v", sum.Buckets)
+	}
+	if !sum.Buckets[0].Start.Equal(start) || sum.Buckets[0].Points != 280 {
+		t.Fatalf("unexpected first bucket: %+v", sum.Buckets[0])
+	}
+	if sum.Buckets[1].Points != 360 || len(sum.Buckets[1].Users) != 1 || sum.Buckets[1].Users[0].UserID != "u2" {
+		t.Fatalf("unexpected second bucket: %+v", sum.Buckets[1])
+	}
+	if !sum.Buckets[2].End.Equal(end) || sum.Buckets[2].Points != 100 {
+		t.Fatalf("unexpected last bucket: %+v", sum.Buckets[2])
+	}
+}
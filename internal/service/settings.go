@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"chores_contributor/internal/repo"
+)
+
+const (
+	ReportReplyAlways    = "always"    // 報告のたびに確認を返信
+	ReportReplyCorrected = "corrected" // タイプミス補正が効いた時だけ返信
+	ReportReplySilent    = "silent"    // 返信しない（エラーは返す）
+)
+
+var ErrInvalidSettings = errors.New("invalid settings")
+
+const (
+	maxBackdateLimitDays = 365
+	maxDayRolloverHour   = 11
+	maxApprovalTimeout   = 168 // 1週間
+)
+
+type HouseSettings struct {
+	ReportReply       string `json:"report_reply"`
+	BackdateLimitDays int    `json:"backdate_limit_days"`
+	WeekStart         string `json:"week_start"`        // monday など（小文字の英語曜日名）
+	DayRolloverHour   int    `json:"day_rollover_hour"` // この時刻より前は前日として扱う
+	Timezone          string `json:"timezone"`          // IANAのタイムゾーン名（例: Asia/Tokyo）
+	RequireApproval   bool   `json:"require_approval"`  // 報告はほかのメンバーが承認するまで集計しない
+	ApprovalTimeout   int    `json:"approval_timeout_hours"`
+}
+
+// HouseSettingsPatch nilの項目は変更しない
+type HouseSettingsPatch struct {
+	ReportReply       *string `json:"report_reply,omitempty"`
+	BackdateLimitDays *int    `json:"backdate_limit_days,omitempty"`
+	WeekStart         *string `json:"week_start,omitempty"`
+	DayRolloverHour   *int    `json:"day_rollover_hour,omitempty"`
+	Timezone          *string `json:"timezone,omitempty"`
+	RequireApproval   *bool   `json:"require_approval,omitempty"`
+	ApprovalTimeout   *int    `json:"approval_timeout_hours,omitempty"`
+}
+
+func houseSettingsFromRepo(hs repo.HouseSettings) HouseSettings {
+	weekStart := time.Monday
+	if hs.WeekStart >= 0 && hs.WeekStart < len(weekdayNames) {
+		weekStart = time.Weekday(hs.WeekStart)
+	}
+	return HouseSettings{
+		ReportReply:       hs.ReportReply,
+		BackdateLimitDays: hs.BackdateLimitDays,
+		WeekStart:         weekdayName(weekStart),
+		DayRolloverHour:   hs.DayRolloverHour,
+		Timezone:          hs.Timezone,
+		RequireApproval:   hs.RequireApproval,
+		ApprovalTimeout:   hs.ApprovalTimeout,
+	}
+}
+
+func validReportReply(mode string) bool {
+	switch mode {
+	case ReportReplyAlways, ReportReplyCorrected, ReportReplySilent:
+		return true
+	}
+	return false
+}
+
+func (s *Service) HouseSettings(ctx context.Context, groupID string) (HouseSettings, error) {
+	hs, err := s.rp.GetHouseSettings(ctx, groupID)
+	if err != nil {
+		return HouseSettings{}, err
+	}
+	return houseSettingsFromRepo(hs), nil
+}
+
+func (s *Service) UpdateHouseSettings(ctx context.Context, groupID string, patch HouseSettingsPatch) (HouseSettings, error) {
+	if patch.ReportReply != nil && !validReportReply(*patch.ReportReply) {
+		return HouseSettings{}, fmt.Errorf("%w: report_reply must be one of %s, %s, %s", ErrInvalidSettings, ReportReplyAlways, ReportReplyCorrected, ReportReplySilent)
+	}
+	if patch.BackdateLimitDays != nil && (*patch.BackdateLimitDays < 0 || *patch.BackdateLimitDays > maxBackdateLimitDays) {
+		return HouseSettings{}, fmt.Errorf("%w: backdate_limit_days must be between 0 and %d", ErrInvalidSettings, maxBackdateLimitDays)
+	}
+	var weekStart *int
+	if patch.WeekStart != nil {
+		wd, ok := parseWeekday(strings.ToLower(strings.TrimSpace(*patch.WeekStart)))
+		if !ok {
+			return HouseSettings{}, fmt.Errorf("%w: week_start must be a weekday name such as monday", ErrInvalidSettings)
+		}
+		n := int(wd)
+		weekStart = &n
+	}
+	if patch.DayRolloverHour != nil && (*patch.DayRolloverHour < 0 || *patch.DayRolloverHour > maxDayRolloverHour) {
+		return HouseSettings{}, fmt.Errorf("%w: day_rollover_hour must be between 0 and %d", ErrInvalidSettings, maxDayRolloverHour)
+	}
+	var timezone *string
+	if patch.Timezone != nil {
+		name := strings.TrimSpace(*patch.Timezone)
+		// "Local" はサーバーの設定に依存するため受け付けない
+		if _, err := loadLocation(name); err != nil || name == "" || name == "Local" {
+			return HouseSettings{}, fmt.Errorf("%w: timezone must be an IANA time zone name such as Asia/Tokyo", ErrInvalidSettings)
+		}
+		timezone = &name
+	}
+	if patch.ApprovalTimeout != nil && (*patch.ApprovalTimeout < 1 || *patch.ApprovalTimeout > maxApprovalTimeout) {
+		return HouseSettings{}, fmt.Errorf("%w: approval_timeout_hours must be between 1 and %d", ErrInvalidSettings, maxApprovalTimeout)
+	}
+	hs, err := s.rp.UpdateHouseSettings(ctx, repo.UpdateHouseSettingsParams{
+		ExtGroupID:        groupID,
+		ReportReply:       patch.ReportReply,
+		BackdateLimitDays: patch.BackdateLimitDays,
+		WeekStart:         weekStart,
+		DayRolloverHour:   patch.DayRolloverHour,
+		Timezone:          timezone,
+		RequireApproval:   patch.RequireApproval,
+		ApprovalTimeout:   patch.ApprovalTimeout,
+	})
+	if err != nil {
+		return HouseSettings{}, err
+	}
+	return houseSettingsFromRepo(hs), nil
+}
+
+// ShouldConfirmReport 返信設定に従って報告の確認を返すべきか判定する
+func ShouldConfirmReport(mode string, results []ReportResult) bool {
+	switch mode {
+	case ReportReplySilent:
+		return false
+	case ReportReplyCorrected:
+		for _, r := range results {
+			if r.Corrected {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"chores_contributor/internal/repo"
+)
+
+// 集計の粒度（PeriodYearはLINEの「@bot top 年」用の期間指定のみ）
+const (
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+	PeriodYear       = "year"
+)
+
+// maxSummaryDays 1回の集計で指定できる最大日数（約3年）
+const maxSummaryDays = 366 * 3
+
+var ErrInvalidSummary = errors.New("invalid summary query")
+
+// SummaryQuery From/Toはハウスの暦での日付（年月日のみを使い、Toも含む）。
+// 両方ゼロ値なら今月。UserIDを指定するとその人だけを集計する
+type SummaryQuery struct {
+	From        time.Time
+	To          time.Time
+	Granularity string
+	UserID      string
+}
+
+type SummaryTask struct {
+	TaskKey string
+	Points  float64
+	Count   int
+}
+
+type SummaryUser struct {
+	UserID string
+	Name   string
+	Points float64
+	Count  int
+	Tasks  []SummaryTask
+}
+
+type SummaryBucketUser struct {
+	UserID string
+	Points float64
+}
+
+// SummaryBucket 時系列の1区間（記録がなくても0ptで含める）
+type SummaryBucket struct {
+	Start  time.Time
+	End    time.Time
+	Points float64
+	Users  []SummaryBucketUser
+}
+
+// Summary 期間内のユーザー別・タスク別の合計と時系列
+type Summary struct {
+	Start       time.Time
+	End         time.Time // 含まない
+	Granularity string
+	Total       float64
+	Users       []SummaryUser // ポイントの多い順
+	Tasks       []SummaryTask // ポイントの多い順
+	Buckets     []SummaryBucket
+}
+
+func validGranularity(g string) bool {
+	switch g {
+	case GranularityDay, GranularityWeek, GranularityMonth:
+		return true
+	}
+	return false
+}
+
+// Summary 任意期間の集計（HTTPの /summary と LINEの me/top で共通）
+func (s *Service) Summary(ctx context.Context, groupID string, q SummaryQuery) (Summary, error) {
+	if q.Granularity == "" {
+		q.Granularity = GranularityDay
+	}
+	if !validGranularity(q.Granularity) {
+		return Summary{}, fmt.Errorf("%w: granularity must be day, week or month", ErrInvalidSummary)
+	}
+	cal, err := s.HouseCalendar(ctx, groupID)
+	if err != nil {
+		return Summary{}, err
+	}
+	if q.From.IsZero() && q.To.IsZero() {
+		q.From, q.To = cal.DateRange(time.Now(), GranularityMonth)
+	}
+	start := dateIn(q.From, cal.Loc)
+	end := dateIn(q.To, cal.Loc).AddDate(0, 0, 1)
+	if !start.Before(end) {
+		return Summary{}, fmt.Errorf("%w: from must not be after to", ErrInvalidSummary)
+	}
+	if end.Sub(start) > maxSummaryDays*24*time.Hour {
+		return Summary{}, fmt.Errorf("%w: range must be within %d days", ErrInvalidSummary, maxSummaryDays)
+	}
+
+	rows, err := s.rp.DailyPoints(ctx, groupID, q.UserID, cal.At(start), cal.At(end), cal.Loc.String(), cal.RolloverHour)
+	if err != nil {
+		return Summary{}, err
+	}
+	return buildSummary(rows, start, end, q.Granularity, cal), nil
+}
+
+// PeriodSummary refを含む期間（day/week/month/year）の集計。年は月ごと、それ以外は日ごとの時系列
+func (s *Service) PeriodSummary(ctx context.Context, groupID, userID, unit string, ref time.Time) (Summary, error) {
+	cal, err := s.HouseCalendar(ctx, groupID)
+	if err != nil {
+		return Summary{}, err
+	}
+	from, to := cal.DateRange(ref, unit)
+	granularity := GranularityDay
+	if unit == PeriodYear {
+		granularity = GranularityMonth
+	}
+	return s.Summary(ctx, groupID, SummaryQuery{From: from, To: to, Granularity: granularity, UserID: userID})
+}
+
+// buildSummary 日単位の集計行をユーザー別・タスク別・区間別にまとめる（start/endは日付、endは含まない）
+func buildSummary(rows []repo.DailyPointsRow, start, end time.Time, granularity string, cal Calendar) Summary {
+	sum := Summary{Start: start, End: end, Granularity: granularity}
+
+	// 区間は期間の端で切り詰める（例: 水曜からの週次集計の最初の区間は水曜始まり）
+	for b := cal.PeriodStartDate(start, granularity); b.Before(end); b = nextPeriod(b, granularity) {
+		bucket := SummaryBucket{Start: b, End: nextPeriod(b, granularity)}
+		if bucket.Start.Before(start) {
+			bucket.Start = start
+		}
+		if bucket.End.After(end) {
+			bucket.End = end
+		}
+		sum.Buckets = append(sum.Buckets, bucket)
+	}
+	bucketIndex := func(day time.Time) int {
+		for i := range sum.Buckets {
+			if day.Before(sum.Buckets[i].End) {
+				return i
+			}
+		}
+		return len(sum.Buckets) - 1
+	}
+
+	users := map[string]*SummaryUser{}
+	userTasks := map[string]map[string]*SummaryTask{}
+	tasks := map[string]*SummaryTask{}
+	bucketUsers := make([]map[string]float64, len(sum.Buckets))
+	var userOrder, taskOrder []string
+
+	for _, row := range rows {
+		sum.Total += row.Points
+
+		u, ok := users[row.ExtUserID]
+		if !ok {
+			u = &SummaryUser{UserID: row.ExtUserID, Name: row.Name}
+			users[row.ExtUserID] = u
+			userTasks[row.ExtUserID] = map[string]*SummaryTask{}
+			userOrder = append(userOrder, row.ExtUserID)
+		}
+		u.Points += row.Points
+		u.Count += row.Count
+
+		ut, ok := userTasks[row.ExtUserID][row.TaskKey]
+		if !ok {
+			ut = &SummaryTask{TaskKey: row.TaskKey}
+			userTasks[row.ExtUserID][row.TaskKey] = ut
+		}
+		ut.Points += row.Points
+		ut.Count += row.Count
+
+		t, ok := tasks[row.TaskKey]
+		if !ok {
+			t = &SummaryTask{TaskKey: row.TaskKey}
+			tasks[row.TaskKey] = t
+			taskOrder = append(taskOrder, row.TaskKey)
+		}
+		t.Points += row.Points
+		t.Count += row.Count
+
+		i := bucketIndex(dateIn(row.Day, start.Location()))
+		sum.Buckets[i].Points += row.Points
+		if bucketUsers[i] == nil {
+			bucketUsers[i] = map[string]float64{}
+		}
+		bucketUsers[i][row.ExtUserID] += row.Points
+	}
+
+	for _, id := range userOrder {
+		u := users[id]
+		for _, t := range userTasks[id] {
+			u.Tasks = append(u.Tasks, *t)
+		}
+		sortSummaryTasks(u.Tasks)
+		sum.Users = append(sum.Users, *u)
+	}
+	sort.SliceStable(sum.Users, func(i, j int) bool {
+		if sum.Users[i].Points == sum.Users[j].Points {
+			return sum.Users[i].Name < sum.Users[j].Name
+		}
+		return sum.Users[i].Points > sum.Users[j].Points
+	})
+	for _, key := range taskOrder {
+		sum.Tasks = append(sum.Tasks, *tasks[key])
+	}
+	sortSummaryTasks(sum.Tasks)
+
+	for i := range sum.Buckets {
+		for _, u := range sum.Users {
+			if pt, ok := bucketUsers[i][u.UserID]; ok {
+				sum.Buckets[i].Users = append(sum.Buckets[i].Users, SummaryBucketUser{UserID: u.UserID, Points: pt})
+			}
+		}
+	}
+	return sum
+}
+
+func sortSummaryTasks(tasks []SummaryTask) {
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].Points == tasks[j].Points {
+			return tasks[i].TaskKey < tasks[j].TaskKey
+		}
+		return tasks[i].Points > tasks[j].Points
+	})
+}
@@ -1,21 +1,32 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sync"
+
+	"chores_contributor/internal/repo"
 )
 
 var (
-	ErrTaskNotFound   = errors.New("task not found")
-	ErrTaskAmbiguous  = errors.New("task ambiguous")
-	taskDefinitions   = defaultTaskDefinitions()
-	taskAliasMemoizer = buildTaskAliasIndex(taskDefinitions)
+	ErrTaskNotFound  = errors.New("task not found")
+	ErrTaskAmbiguous = errors.New("task ambiguous")
 )
 
+// TaskDefinition Scoringが空ならScoringFixed扱い。UnitMinutes/MaxUnitsは0で未設定。
+// Rotationが空ならRotationLeastPoints扱い
 type TaskDefinition struct {
-	Key     string
-	Aliases []string
-	Points  float64
+	ID          int64
+	Key         string
+	Aliases     []string
+	Points      float64
+	Scoring     string
+	UnitMinutes int
+	MaxUnits    int
+	Archived    bool
+	Rotation    string
+	Owner       string // RotationFixed の担当者（ext_user_id）
 }
 
 type TaskAmbiguousError struct {
@@ -44,6 +55,7 @@ func (e *TaskNotFoundError) Unwrap() error {
 }
 
 type taskAliasIndex struct {
+	defs   []TaskDefinition
 	exact  map[string]string
 	fuzzy  map[string]string
 	defMap map[string]TaskDefinition
@@ -69,24 +81,105 @@ func buildTaskAliasIndex(defs []TaskDefinition) taskAliasIndex {
 	}
 
 	return taskAliasIndex{
+		defs:   defs,
 		exact:  exact,
 		fuzzy:  fuzzy,
 		defMap: defMap,
 	}
 }
 
-func resolveTask(input string) (TaskDefinition, error) {
+// taskCatalogCache ハウスごとの別名インデックスを辞書の版（houses.catalog_version）とともに保持する。
+// ほかのインスタンスが辞書を変えても版が変わるので、次の参照で読み直す
+type taskCatalogCache struct {
+	mu      sync.RWMutex
+	byGroup map[string]cachedCatalog
+}
+
+type cachedCatalog struct {
+	version int64
+	idx     taskAliasIndex
+}
+
+func newTaskCatalogCache() *taskCatalogCache {
+	return &taskCatalogCache{byGroup: make(map[string]cachedCatalog)}
+}
+
+// get 版versionのインデックスがあれば返す
+func (c *taskCatalogCache) get(groupID string, version int64) (taskAliasIndex, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cached, ok := c.byGroup[groupID]
+	if !ok || cached.version != version {
+		return taskAliasIndex{}, false
+	}
+	return cached.idx, true
+}
+
+func (c *taskCatalogCache) put(groupID string, version int64, idx taskAliasIndex) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.byGroup[groupID] = cachedCatalog{version: version, idx: idx}
+}
+
+func (c *taskCatalogCache) invalidate(groupID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.byGroup, groupID)
+}
+
+// taskIndex ハウスの辞書を読み込み別名インデックスを返す（辞書の版が変わっていなければキャッシュを使う）
+func (s *Service) taskIndex(ctx context.Context, groupID string) (taskAliasIndex, error) {
+	// 版は辞書より先に読む（間に書き換えがあっても、古い版で新しい辞書を持つだけで次に読み直す）
+	version, err := s.rp.CatalogVersion(ctx, groupID)
+	if err != nil {
+		return taskAliasIndex{}, err
+	}
+	if idx, ok := s.catalogs.get(groupID, version); ok {
+		return idx, nil
+	}
+	if err := s.rp.EnsureHouse(ctx, groupID); err != nil {
+		return taskAliasIndex{}, err
+	}
+	rows, err := s.rp.ListTasks(ctx, groupID, false)
+	if err != nil {
+		return taskAliasIndex{}, err
+	}
+	idx := buildTaskAliasIndex(taskDefinitionsFromRows(rows))
+	s.catalogs.put(groupID, version, idx)
+	return idx, nil
+}
+
+func taskDefinitionsFromRows(rows []repo.TaskRow) []TaskDefinition {
+	defs := make([]TaskDefinition, 0, len(rows))
+	for _, row := range rows {
+		defs = append(defs, TaskDefinition{
+			ID:          row.ID,
+			Key:         row.Key,
+			Aliases:     row.Aliases,
+			Points:      row.Points,
+			Scoring:     row.Scoring,
+			UnitMinutes: row.UnitMinutes,
+			MaxUnits:    row.MaxUnits,
+			Archived:    row.Archived,
+			Rotation:    row.Rotation,
+			Owner:       row.Owner,
+		})
+	}
+	return defs
+}
+
+func resolveTask(idx taskAliasIndex, input string) (TaskDefinition, error) {
 	normInput := normalizeCategory(input)
 	if normInput == "" {
 		return TaskDefinition{}, &TaskNotFoundError{Input: input}
 	}
 
-	if canonical, ok := taskAliasMemoizer.exact[normInput]; ok {
-		return taskAliasMemoizer.defMap[normalizeCategory(canonical)], nil
+	if canonical, ok := idx.exact[normInput]; ok {
+		return idx.defMap[normalizeCategory(canonical)], nil
 	}
 
 	candidates := make(map[string]struct{})
-	for alias, canonical := range taskAliasMemoizer.fuzzy {
+	for alias, canonical := range idx.fuzzy {
 		if levenshteinDistance(alias, normInput) <= 1 {
 			candidates[canonical] = struct{}{}
 		}
@@ -97,7 +190,7 @@ func resolveTask(input string) (TaskDefinition, error) {
 		return TaskDefinition{}, &TaskNotFoundError{Input: input}
 	case 1:
 		for canonical := range candidates {
-			return taskAliasMemoizer.defMap[normalizeCategory(canonical)], nil
+			return idx.defMap[normalizeCategory(canonical)], nil
 		}
 	default:
 		names := make([]string, 0, len(candidates))
@@ -163,6 +256,16 @@ func min(vals ...int) int {
 
 const BASE_POINT = 100
 
+// defaultTaskSeeds 新規ハウス作成時に投入するタスク辞書
+func defaultTaskSeeds() []repo.TaskSeed {
+	defs := defaultTaskDefinitions()
+	out := make([]repo.TaskSeed, 0, len(defs))
+	for _, def := range defs {
+		out = append(out, repo.TaskSeed{Key: def.Key, Aliases: def.Aliases, Points: def.Points})
+	}
+	return out
+}
+
 func defaultTaskDefinitions() []TaskDefinition {
 	return []TaskDefinition{
 		{